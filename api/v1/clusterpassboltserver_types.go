@@ -0,0 +1,51 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+//+kubebuilder:printcolumn:name="Sync Status",type=string,JSONPath=`.status.syncStatus`
+//+kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSync`
+
+// ClusterPassboltServer is the Schema for the clusterpassboltservers API.
+// It defines a passbolt server that can be referenced by PassboltSecrets in all namespaces.
+type ClusterPassboltServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PassboltServerSpec   `json:"spec,omitempty"`
+	Status PassboltServerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPassboltServerList contains a list of ClusterPassboltServer
+type ClusterPassboltServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPassboltServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPassboltServer{}, &ClusterPassboltServerList{})
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PassboltAccessPolicySpec defines which passbolt resources PassboltSecrets in the selected namespaces may reference.
+// A namespace that is selected by multiple policies may reference the resources allowed by any of them.
+// Namespaces that are not selected by any policy are not restricted.
+type PassboltAccessPolicySpec struct {
+	// Namespaces are the names of the namespaces the policy applies to.
+	// +kubebuilder:validation:Optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces the policy applies to by their labels.
+	// An empty selector selects all namespaces.
+	// +kubebuilder:validation:Optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// FolderIDs are the IDs of the passbolt folders whose resources may be referenced, including the resources of sub folders.
+	// +kubebuilder:validation:Optional
+	FolderIDs []string `json:"folderIDs,omitempty"`
+	// FolderPaths are the paths of the passbolt folders whose resources may be referenced, including the resources of sub folders,
+	// e.g. infra/prod is the folder prod inside the root folder infra.
+	// +kubebuilder:validation:Optional
+	FolderPaths []string `json:"folderPaths,omitempty"`
+	// ResourceIDs are the IDs of the passbolt resources that may be referenced.
+	// +kubebuilder:validation:Optional
+	ResourceIDs []string `json:"resourceIDs,omitempty"`
+	// Tags are the passbolt tags whose resources may be referenced.
+	// A tagSelector of a PassboltSecret is allowed if it contains at least one of these tags.
+	// +kubebuilder:validation:Optional
+	Tags []string `json:"tags,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// PassboltAccessPolicy is the Schema for the passboltaccesspolicies API.
+// It restricts the passbolt resources that PassboltSecrets in the selected namespaces may reference,
+// independent of the permissions of the passbolt user.
+type PassboltAccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PassboltAccessPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PassboltAccessPolicyList contains a list of PassboltAccessPolicy
+type PassboltAccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PassboltAccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PassboltAccessPolicy{}, &PassboltAccessPolicyList{})
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// accessPolicyTimeout is the timeout of the access policy check of the webhooks.
+	accessPolicyTimeout = 5 * time.Second
+)
+
+// AccessPolicyChecker checks the passbolt references of an object against the PassboltAccessPolicies of its namespace.
+// The returned errors wrap ErrReferenceNotAllowed if a reference is not allowed.
+// +kubebuilder:object:generate=false
+type AccessPolicyChecker interface {
+	// CheckPassboltSecret checks the passbolt resources, folders and tags referenced by the PassboltSecret.
+	CheckPassboltSecret(ctx context.Context, secret *PassboltSecret) error
+	// CheckPassboltGeneratedSecret checks the passbolt resource or folder the PassboltGeneratedSecret writes to.
+	CheckPassboltGeneratedSecret(ctx context.Context, genSecret *PassboltGeneratedSecret) error
+	// CheckPassboltPushSecret checks the passbolt resource or folder the PassboltPushSecret writes to.
+	CheckPassboltPushSecret(ctx context.Context, pushSecret *PassboltPushSecret) error
+}
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks.
+// The passbolt references are checked against the access policies of the namespace by the given checker.
+func (r *PassboltGeneratedSecret) SetupWebhookWithManager(mgr ctrl.Manager, policies AccessPolicyChecker) error {
+	if policies == nil {
+		return ErrAccessPolicyCheckerIsRequired
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&PassboltValidator{Policies: policies}).
+		Complete()
+}
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks.
+// The passbolt references are checked against the access policies of the namespace by the given checker.
+func (r *PassboltPushSecret) SetupWebhookWithManager(mgr ctrl.Manager, policies AccessPolicyChecker) error {
+	if policies == nil {
+		return ErrAccessPolicyCheckerIsRequired
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&PassboltValidator{Policies: policies}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-passbolt-tagesspiegel-de-v1-passboltgeneratedsecret,mutating=false,failurePolicy=fail,sideEffects=None,groups=passbolt.tagesspiegel.de,resources=passboltgeneratedsecrets,verbs=create;update,versions=v1,name=vpassboltgeneratedsecret.tagesspiegel.de,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-passbolt-tagesspiegel-de-v1-passboltpushsecret,mutating=false,failurePolicy=fail,sideEffects=None,groups=passbolt.tagesspiegel.de,resources=passboltpushsecrets,verbs=create;update,versions=v1,name=vpassboltpushsecret.tagesspiegel.de,admissionReviewVersions=v1
+
+// PassboltValidator validates PassboltSecrets, PassboltGeneratedSecrets and PassboltPushSecrets.
+// PassboltSecrets are validated by their own rules first. Afterwards, the passbolt references of all kinds are checked
+// against the PassboltAccessPolicies of their namespace.
+// +kubebuilder:object:generate=false
+type PassboltValidator struct {
+	// Policies checks the passbolt references against the access policies.
+	Policies AccessPolicyChecker
+}
+
+var _ webhook.CustomValidator = &PassboltValidator{}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *PassboltValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if secret, ok := obj.(*PassboltSecret); ok {
+		if warnings, err := secret.ValidateCreate(); err != nil {
+			return warnings, err
+		}
+	}
+	return nil, v.checkAccessPolicies(ctx, obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *PassboltValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if secret, ok := newObj.(*PassboltSecret); ok {
+		if warnings, err := secret.ValidateUpdate(oldObj); err != nil {
+			return warnings, err
+		}
+	}
+	return nil, v.checkAccessPolicies(ctx, newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator.
+func (v *PassboltValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if secret, ok := obj.(*PassboltSecret); ok {
+		return secret.ValidateDelete()
+	}
+	return nil, nil
+}
+
+// checkAccessPolicies checks the passbolt references of the object against the access policies of its namespace.
+func (v *PassboltValidator) checkAccessPolicies(ctx context.Context, obj runtime.Object) error {
+	if v.Policies == nil {
+		return ErrAccessPolicyCheckerIsRequired
+	}
+	ctx, cancel := context.WithTimeout(ctx, accessPolicyTimeout)
+	defer cancel()
+	switch o := obj.(type) {
+	case *PassboltSecret:
+		return v.Policies.CheckPassboltSecret(ctx, o)
+	case *PassboltGeneratedSecret:
+		return v.Policies.CheckPassboltGeneratedSecret(ctx, o)
+	case *PassboltPushSecret:
+		return v.Policies.CheckPassboltPushSecret(ctx, o)
+	default:
+		return fmt.Errorf("unexpected object of type %T", obj)
+	}
+}
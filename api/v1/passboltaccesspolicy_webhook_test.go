@@ -0,0 +1,116 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// fakeAccessPolicyChecker denies all objects named "denied".
+type fakeAccessPolicyChecker struct{}
+
+func (fakeAccessPolicyChecker) check(name string) error {
+	if name == "denied" {
+		return ErrReferenceNotAllowed
+	}
+	return nil
+}
+
+func (f fakeAccessPolicyChecker) CheckPassboltSecret(_ context.Context, secret *PassboltSecret) error {
+	return f.check(secret.Name)
+}
+
+func (f fakeAccessPolicyChecker) CheckPassboltGeneratedSecret(_ context.Context, genSecret *PassboltGeneratedSecret) error {
+	return f.check(genSecret.Name)
+}
+
+func (f fakeAccessPolicyChecker) CheckPassboltPushSecret(_ context.Context, pushSecret *PassboltPushSecret) error {
+	return f.check(pushSecret.Name)
+}
+
+func TestPassboltValidator(t *testing.T) {
+	validSpec := PassboltSecretSpec{
+		SecretType: corev1.SecretTypeOpaque,
+		PassboltSecrets: map[string]PassboltSecretRef{
+			"password": {ID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", Field: FieldNamePassword},
+		},
+	}
+	tests := []struct {
+		name    string
+		obj     runtime.Object
+		wantErr error
+	}{
+		{
+			name: "allowed passbolt secret",
+			obj:  &PassboltSecret{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}, Spec: validSpec},
+		},
+		{
+			name:    "denied passbolt secret",
+			obj:     &PassboltSecret{ObjectMeta: metav1.ObjectMeta{Name: "denied"}, Spec: validSpec},
+			wantErr: ErrReferenceNotAllowed,
+		},
+		{
+			name:    "invalid passbolt secret",
+			obj:     &PassboltSecret{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}, Spec: PassboltSecretSpec{SecretType: corev1.SecretTypeOpaque}},
+			wantErr: ErrSecretsAreRequired,
+		},
+		{
+			name: "allowed generated secret",
+			obj:  &PassboltGeneratedSecret{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}},
+		},
+		{
+			name:    "denied generated secret",
+			obj:     &PassboltGeneratedSecret{ObjectMeta: metav1.ObjectMeta{Name: "denied"}},
+			wantErr: ErrReferenceNotAllowed,
+		},
+		{
+			name: "allowed push secret",
+			obj:  &PassboltPushSecret{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}},
+		},
+		{
+			name:    "denied push secret",
+			obj:     &PassboltPushSecret{ObjectMeta: metav1.ObjectMeta{Name: "denied"}},
+			wantErr: ErrReferenceNotAllowed,
+		},
+	}
+	v := &PassboltValidator{Policies: fakeAccessPolicyChecker{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.ValidateCreate(context.Background(), tt.obj); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PassboltValidator.ValidateCreate() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := v.ValidateUpdate(context.Background(), tt.obj, tt.obj); !errors.Is(err, tt.wantErr) {
+				t.Errorf("PassboltValidator.ValidateUpdate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPassboltValidator_WithoutChecker(t *testing.T) {
+	// a missing checker must not silently allow every reference
+	v := &PassboltValidator{}
+	_, err := v.ValidateCreate(context.Background(), &PassboltPushSecret{ObjectMeta: metav1.ObjectMeta{Name: "allowed"}})
+	if !errors.Is(err, ErrAccessPolicyCheckerIsRequired) {
+		t.Errorf("PassboltValidator.ValidateCreate() error = %v, want %v", err, ErrAccessPolicyCheckerIsRequired)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PassboltGeneratedSecretSpec defines the desired state of PassboltGeneratedSecret
+type PassboltGeneratedSecretSpec struct {
+	// LeaveOnDelete defines if the secret should be deleted from Kubernetes when the PassboltGeneratedSecret is deleted.
+	// The passbolt resource is never deleted.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	LeaveOnDelete bool `json:"leaveOnDelete"`
+	// Resource defines the passbolt resource that is created with the generated password.
+	// +kubebuilder:validation:Required
+	Resource PassboltGeneratedResource `json:"resource"`
+	// PasswordPolicy defines how the password is generated.
+	// +kubebuilder:validation:Optional
+	PasswordPolicy PasswordPolicy `json:"passwordPolicy,omitempty"`
+	// Shares defines the passbolt users and groups the resource is shared with.
+	// +kubebuilder:validation:Optional
+	Shares []PassboltShare `json:"shares,omitempty"`
+	// AdoptExisting allows to use an existing passbolt resource with the name in the folder instead of creating a new one.
+	// The password policy is not applied to an adopted resource, but the shares are.
+	// If not set, the sync fails if a resource with the name already exists in the folder.
+	// +kubebuilder:validation:Optional
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+	// SecretKeys is a map of string (key in K8s secret) and struct that defines which field of the generated
+	// passbolt resource is used as value. If not set, the password is stored with the key "password".
+	// +kubebuilder:validation:Optional
+	SecretKeys map[string]PassboltGeneratedSecretKey `json:"secretKeys,omitempty"`
+	// PlainTextFields is a map of string (key in K8s secret) and string (value in K8s secret).
+	// +kubebuilder:validation:Optional
+	PlainTextFields map[string]string `json:"plainTextFields,omitempty"`
+	// ServerRef references the passbolt server the resource is created in.
+	// If not set, the passbolt server configured for the operator is used.
+	// +kubebuilder:validation:Optional
+	ServerRef *PassboltServerRef `json:"serverRef,omitempty"`
+	// CredentialsRef references a secret in the namespace of the PassboltGeneratedSecret holding the credentials of a passbolt user.
+	// If set, the operator logs in as this user instead of its own user, so that the permissions of the user in passbolt
+	// define which resources can be created and read.
+	// +kubebuilder:validation:Optional
+	CredentialsRef *PassboltCredentialsRef `json:"credentialsRef,omitempty"`
+}
+
+// PassboltGeneratedResource defines the static fields of a generated passbolt resource.
+type PassboltGeneratedResource struct {
+	// Name is the name of the passbolt resource.
+	// If a resource with this name already exists in the folder, it is only used if AdoptExisting is set.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// FolderID is the ID of the passbolt folder the resource is created in.
+	// If not set, the resource is created in the root folder.
+	// +kubebuilder:validation:Optional
+	FolderID string `json:"folderID,omitempty"`
+	// Username is the username of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	Username string `json:"username,omitempty"`
+	// URI is the URI of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	URI string `json:"uri,omitempty"`
+	// Description is the description of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	Description string `json:"description,omitempty"`
+}
+
+// PasswordPolicy defines how a password is generated.
+type PasswordPolicy struct {
+	// Length is the number of characters of the password.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=8
+	// +kubebuilder:validation:Maximum=4096
+	// +kubebuilder:default=32
+	Length int `json:"length,omitempty"`
+	// Charset is the set of characters the password is generated from.
+	// If not set, upper and lower case letters and digits are used.
+	// +kubebuilder:validation:Optional
+	Charset string `json:"charset,omitempty"`
+	// Symbols adds special characters to the charset.
+	// +kubebuilder:validation:Optional
+	Symbols bool `json:"symbols,omitempty"`
+}
+
+// PassboltGeneratedSecretKey defines the value of a key in the Kubernetes secret.
+// Either Field or Value must be set.
+type PassboltGeneratedSecretKey struct {
+	// Field is the field in the passbolt resource to be read.
+	// +kubebuilder:validation:Optional
+	Field FieldName `json:"field,omitempty"`
+	// Value is a go template that is rendered with the passbolt resource, see PassboltSecretRef.
+	// +kubebuilder:validation:Optional
+	Value *string `json:"value,omitempty"`
+}
+
+// PassboltGeneratedSecretStatus defines the observed state of PassboltGeneratedSecret
+type PassboltGeneratedSecretStatus struct {
+	// ResourceID is the ID of the passbolt resource that holds the generated password.
+	// +kubebuilder:validation:Optional
+	ResourceID string `json:"resourceID,omitempty"`
+	// CreatePending is set before the passbolt resource is created and cleared once its ID is recorded.
+	// If it is set without a resource ID, the ID of a created resource may have been lost,
+	// so the resource with the name in the folder is used instead of creating another one.
+	// +kubebuilder:validation:Optional
+	CreatePending bool `json:"createPending,omitempty"`
+	// Shares are the shares that were applied to the passbolt resource.
+	// They are revoked once they are removed from the spec.
+	// +kubebuilder:validation:Optional
+	Shares []PassboltShare `json:"shares,omitempty"`
+	// SyncStatus is the status of the last sync.
+	// +kubebuilder:validation:Enum=Success;Error;Unknown
+	// +kubebuilder:default=Unknown
+	SyncStatus SyncStatus `json:"syncStatus"`
+	// LastSync is the last time the secret was synced from passbolt.
+	// +kubebuilder:validation:Optional
+	LastSync metav1.Time `json:"lastSync"`
+	// SyncErrors is a list of errors that occurred during the last sync.
+	SyncErrors []SyncError `json:"syncErrors,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Resource ID",type=string,JSONPath=`.status.resourceID`
+//+kubebuilder:printcolumn:name="Sync Status",type=string,JSONPath=`.status.syncStatus`
+//+kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSync`
+
+// PassboltGeneratedSecret is the Schema for the passboltgeneratedsecrets API.
+// It generates a password, stores it in passbolt and syncs it to a Kubernetes secret.
+type PassboltGeneratedSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PassboltGeneratedSecretSpec   `json:"spec,omitempty"`
+	Status PassboltGeneratedSecretStatus `json:"status,omitempty"`
+}
+
+// PassboltSecretSpec returns the spec of a PassboltSecret that syncs the generated passbolt resource.
+func (r *PassboltGeneratedSecret) PassboltSecretSpec() PassboltSecretSpec {
+	keys := r.Spec.SecretKeys
+	if len(keys) == 0 {
+		keys = map[string]PassboltGeneratedSecretKey{
+			"password": {Field: FieldNamePassword},
+		}
+	}
+	refs := make(map[string]PassboltSecretRef, len(keys))
+	for key, ref := range keys {
+		refs[key] = PassboltSecretRef{
+			ID:    r.Status.ResourceID,
+			Field: ref.Field,
+			Value: ref.Value,
+		}
+	}
+	return PassboltSecretSpec{
+		LeaveOnDelete:   r.Spec.LeaveOnDelete,
+		SecretType:      corev1.SecretTypeOpaque,
+		PassboltSecrets: refs,
+		PlainTextFields: r.Spec.PlainTextFields,
+	}
+}
+
+//+kubebuilder:object:root=true
+
+// PassboltGeneratedSecretList contains a list of PassboltGeneratedSecret
+type PassboltGeneratedSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PassboltGeneratedSecret `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PassboltGeneratedSecret{}, &PassboltGeneratedSecretList{})
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PassboltPushSecretSpec defines the desired state of PassboltPushSecret
+type PassboltPushSecretSpec struct {
+	// SecretName is the name of the Kubernetes secret whose data is pushed to passbolt.
+	// The secret must be in the same namespace as the PassboltPushSecret.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+	// Resource defines the passbolt resource that is created or updated.
+	// +kubebuilder:validation:Required
+	Resource PassboltPushResource `json:"resource"`
+	// Shares defines the passbolt users and groups the resource is shared with.
+	// +kubebuilder:validation:Optional
+	Shares []PassboltShare `json:"shares,omitempty"`
+	// ServerRef references the passbolt server the resource is pushed to.
+	// If not set, the passbolt server configured for the operator is used.
+	// +kubebuilder:validation:Optional
+	ServerRef *PassboltServerRef `json:"serverRef,omitempty"`
+	// CredentialsRef references a secret in the namespace of the PassboltPushSecret holding the credentials of a passbolt user.
+	// If set, the operator logs in as this user instead of its own user, so that the permissions of the user in passbolt
+	// define which resources can be created and updated.
+	// +kubebuilder:validation:Optional
+	CredentialsRef *PassboltCredentialsRef `json:"credentialsRef,omitempty"`
+}
+
+// PassboltPushResource defines the fields of a passbolt resource.
+type PassboltPushResource struct {
+	// Name is the name of the passbolt resource.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// FolderID is the ID of the passbolt folder the resource is created in.
+	// The folder is only applied when the resource is created, the resource is not moved afterwards.
+	// If not set, the resource is created in the root folder.
+	// +kubebuilder:validation:Optional
+	FolderID string `json:"folderID,omitempty"`
+	// Password is the password of the passbolt resource.
+	// +kubebuilder:validation:Required
+	Password PassboltPushValue `json:"password"`
+	// Username is the username of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	Username *PassboltPushValue `json:"username,omitempty"`
+	// URI is the URI of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	URI *PassboltPushValue `json:"uri,omitempty"`
+	// Description is the description of the passbolt resource.
+	// +kubebuilder:validation:Optional
+	Description *PassboltPushValue `json:"description,omitempty"`
+}
+
+// PassboltPushValue defines the value of a field of a passbolt resource.
+// Either SecretKey or Value must be set.
+// +kubebuilder:validation:XValidation:rule="(has(self.secretKey) && size(self.secretKey) > 0) != (has(self.value) && size(self.value) > 0)",message="exactly one of secretKey and value must be set"
+type PassboltPushValue struct {
+	// SecretKey is the key in the Kubernetes secret whose value is used.
+	// +kubebuilder:validation:Optional
+	SecretKey string `json:"secretKey,omitempty"`
+	// Value is a static value.
+	// +kubebuilder:validation:Optional
+	Value string `json:"value,omitempty"`
+}
+
+// PassboltPushSecretStatus defines the observed state of PassboltPushSecret
+type PassboltPushSecretStatus struct {
+	// ResourceID is the ID of the passbolt resource managed by the PassboltPushSecret.
+	// It is set once the resource was created.
+	// +kubebuilder:validation:Optional
+	ResourceID string `json:"resourceID,omitempty"`
+	// CreatePending is set before the passbolt resource is created and cleared once its ID is recorded.
+	// If it is set without a resource ID, the ID of a created resource may have been lost,
+	// so the resource with the name in the folder is used instead of creating another one.
+	// +kubebuilder:validation:Optional
+	CreatePending bool `json:"createPending,omitempty"`
+	// Shares are the shares that were applied to the passbolt resource.
+	// They are revoked once they are removed from the spec.
+	// +kubebuilder:validation:Optional
+	Shares []PassboltShare `json:"shares,omitempty"`
+	// SyncStatus is the status of the last sync.
+	// +kubebuilder:validation:Enum=Success;Error;Unknown
+	// +kubebuilder:default=Unknown
+	SyncStatus SyncStatus `json:"syncStatus"`
+	// LastSync is the last time the secret was pushed to passbolt.
+	// +kubebuilder:validation:Optional
+	LastSync metav1.Time `json:"lastSync"`
+	// SyncErrors is a list of errors that occurred during the last sync.
+	SyncErrors []SyncError `json:"syncErrors,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Resource ID",type=string,JSONPath=`.status.resourceID`
+//+kubebuilder:printcolumn:name="Sync Status",type=string,JSONPath=`.status.syncStatus`
+//+kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSync`
+
+// PassboltPushSecret is the Schema for the passboltpushsecrets API.
+// It pushes the data of a Kubernetes secret to a passbolt resource.
+type PassboltPushSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PassboltPushSecretSpec   `json:"spec,omitempty"`
+	Status PassboltPushSecretStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PassboltPushSecretList contains a list of PassboltPushSecret
+type PassboltPushSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PassboltPushSecret `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PassboltPushSecret{}, &PassboltPushSecretList{})
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionsAnnotation is the annotation that preserves the conditions and the observed generation of a PassboltSecret
+// in versions of the API without conditions, so that they survive a round trip through these versions.
+const ConditionsAnnotation = "passbolt.tagesspiegel.de/conditions"
+
+// preservedConditions is the content of ConditionsAnnotation.
+type preservedConditions struct {
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+}
+
+// PreserveConditions stores the conditions and the observed generation of the status in ConditionsAnnotation of the given
+// object meta, which is converted from the PassboltSecret. The annotations are copied, so that the PassboltSecret is not modified.
+func (s PassboltSecretStatus) PreserveConditions(meta *metav1.ObjectMeta) error {
+	if len(s.Conditions) == 0 && s.ObservedGeneration == 0 {
+		return nil
+	}
+	data, err := json.Marshal(preservedConditions{
+		Conditions:         s.Conditions,
+		ObservedGeneration: s.ObservedGeneration,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to preserve conditions: %w", err)
+	}
+	annotations := maps.Clone(meta.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ConditionsAnnotation] = string(data)
+	meta.Annotations = annotations
+	return nil
+}
+
+// RestoreConditions restores the conditions and the observed generation preserved by PreserveConditions
+// and removes ConditionsAnnotation from the object meta of the PassboltSecret.
+func (r *PassboltSecret) RestoreConditions() error {
+	data, ok := r.Annotations[ConditionsAnnotation]
+	if !ok {
+		return nil
+	}
+	preserved := preservedConditions{}
+	if err := json.Unmarshal([]byte(data), &preserved); err != nil {
+		return fmt.Errorf("failed to restore conditions: %w", err)
+	}
+	r.Status.Conditions = preserved.Conditions
+	r.Status.ObservedGeneration = preserved.ObservedGeneration
+	annotations := maps.Clone(r.Annotations)
+	delete(annotations, ConditionsAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	r.Annotations = annotations
+	return nil
+}
+
+// FieldsAnnotation is the annotation that preserves the fields of a PassboltSecret that do not exist in older versions
+// of the API, so that they survive a round trip through these versions.
+const FieldsAnnotation = "passbolt.tagesspiegel.de/preserved-fields"
+
+// preservedFields is the content of FieldsAnnotation.
+type preservedFields struct {
+	// Spec contains the references by name or folder path, the plain text fields and the fields that only exist in v1.
+	Spec     PassboltSecretSpec `json:"spec"`
+	DataHash string             `json:"dataHash,omitempty"`
+}
+
+// PreserveFields stores the fields of the PassboltSecret that do not exist in older versions of the API in FieldsAnnotation
+// of the given object meta, which is converted from the PassboltSecret. References by name or folder path are preserved
+// as well, since older versions only reference resources by ID or name. The annotations are copied, so that the
+// PassboltSecret is not modified.
+func (r *PassboltSecret) PreserveFields(meta *metav1.ObjectMeta) error {
+	preserved := preservedFields{
+		Spec: PassboltSecretSpec{
+			PlainTextFields: r.Spec.PlainTextFields,
+			PassboltFolder:  r.Spec.PassboltFolder,
+			TagSelector:     r.Spec.TagSelector,
+			ServerRef:       r.Spec.ServerRef,
+			CredentialsRef:  r.Spec.CredentialsRef,
+			RefreshInterval: r.Spec.RefreshInterval,
+			OnMissing:       r.Spec.OnMissing,
+			RolloutTargets:  r.Spec.RolloutTargets,
+		},
+		DataHash: r.Status.DataHash,
+	}
+	for key, ref := range r.Spec.PassboltSecrets {
+		if ref.ID != "" {
+			continue
+		}
+		if preserved.Spec.PassboltSecrets == nil {
+			preserved.Spec.PassboltSecrets = map[string]PassboltSecretRef{}
+		}
+		preserved.Spec.PassboltSecrets[key] = ref
+	}
+	if reflect.DeepEqual(preserved, preservedFields{}) {
+		return nil
+	}
+	data, err := json.Marshal(preserved)
+	if err != nil {
+		return fmt.Errorf("failed to preserve fields: %w", err)
+	}
+	annotations := maps.Clone(meta.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[FieldsAnnotation] = string(data)
+	meta.Annotations = annotations
+	return nil
+}
+
+// PreservedSpec returns the spec preserved by PreserveFields in the given object meta.
+// Older versions use it to tell references by name or folder path and plain text fields apart from references by name.
+func PreservedSpec(meta metav1.ObjectMeta) (PassboltSecretSpec, error) {
+	preserved, _, err := preservedFieldsOf(meta)
+	return preserved.Spec, err
+}
+
+// RestoreFields restores the fields preserved by PreserveFields and removes FieldsAnnotation from the object meta
+// of the PassboltSecret. References by name or folder path are only restored if the converted reference has no ID,
+// so that references changed in an older version are kept. Plain text fields are left to the conversion,
+// since the versions represent them differently.
+func (r *PassboltSecret) RestoreFields() error {
+	preserved, ok, err := preservedFieldsOf(r.ObjectMeta)
+	if err != nil || !ok {
+		return err
+	}
+	for key, ref := range preserved.Spec.PassboltSecrets {
+		current, ok := r.Spec.PassboltSecrets[key]
+		if !ok || current.ID != "" {
+			continue
+		}
+		current.Name = ref.Name
+		current.FolderPath = ref.FolderPath
+		r.Spec.PassboltSecrets[key] = current
+	}
+	r.Spec.PassboltFolder = preserved.Spec.PassboltFolder
+	r.Spec.TagSelector = preserved.Spec.TagSelector
+	r.Spec.ServerRef = preserved.Spec.ServerRef
+	r.Spec.CredentialsRef = preserved.Spec.CredentialsRef
+	r.Spec.RefreshInterval = preserved.Spec.RefreshInterval
+	r.Spec.OnMissing = preserved.Spec.OnMissing
+	r.Spec.RolloutTargets = preserved.Spec.RolloutTargets
+	r.Status.DataHash = preserved.DataHash
+	annotations := maps.Clone(r.Annotations)
+	delete(annotations, FieldsAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	r.Annotations = annotations
+	return nil
+}
+
+// preservedFieldsOf returns the content of FieldsAnnotation of the given object meta and whether it is set.
+func preservedFieldsOf(meta metav1.ObjectMeta) (preservedFields, bool, error) {
+	preserved := preservedFields{}
+	data, ok := meta.Annotations[FieldsAnnotation]
+	if !ok {
+		return preserved, false, nil
+	}
+	if err := json.Unmarshal([]byte(data), &preserved); err != nil {
+		return preserved, false, fmt.Errorf("failed to restore fields: %w", err)
+	}
+	return preserved, true, nil
+}
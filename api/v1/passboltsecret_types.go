@@ -18,6 +18,7 @@ package v1
 
 import (
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -47,26 +48,140 @@ type PassboltSecretSpec struct {
 	// +kubebuilder:validation:Optional
 	PassboltSecrets map[string]PassboltSecretRef `json:"passboltSecrets,omitempty"`
 
+	// PassboltFolder syncs every resource inside a passbolt folder to the secret.
+	// It is only allowed for secrets of type Opaque and can be combined with PassboltSecrets and PlainTextFields.
+	// +kubebuilder:validation:Optional
+	PassboltFolder *PassboltFolderRef `json:"passboltFolder,omitempty"`
+
+	// TagSelector syncs every resource in passbolt that carries all of the given tags to the secret.
+	// It is only allowed for secrets of type Opaque and can be combined with PassboltSecrets and PlainTextFields.
+	// +kubebuilder:validation:Optional
+	TagSelector *PassboltTagSelector `json:"tagSelector,omitempty"`
+
 	// PlainTextFields is a map of string (key in K8s secret) and string (value in K8s secret).
 	// +kubebuilder:validation:Optional
 	PlainTextFields map[string]string `json:"plainTextFields,omitempty"`
+
+	// ServerRef references the passbolt server the secret is synced from.
+	// If not set, the passbolt server configured for the operator is used.
+	// +kubebuilder:validation:Optional
+	ServerRef *PassboltServerRef `json:"serverRef,omitempty"`
+
+	// CredentialsRef references a secret in the namespace of the PassboltSecret holding the credentials of a passbolt user.
+	// If set, the operator logs in as this user instead of its own user, so that the permissions of the user in passbolt
+	// define which resources can be synced.
+	// +kubebuilder:validation:Optional
+	CredentialsRef *PassboltCredentialsRef `json:"credentialsRef,omitempty"`
+
+	// RefreshInterval defines how often the secret is re-synced from passbolt.
+	// If not set, the operator-wide default is used. A value of 0 disables the periodic re-sync.
+	// +kubebuilder:validation:Optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	// OnMissing defines how the secret is synced if a passbolt resource referenced by its ID in passboltSecrets or passboltSecretID
+	// does not exist (anymore), e.g. because it was deleted in passbolt.
+	// Resources deleted from a synced folder or tag selection are always removed from the secret.
+	// References by name or folder path that cannot be resolved always fail the sync.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Fail;Keep;DeleteKey;DeleteSecret
+	// +kubebuilder:default=Fail
+	OnMissing OnMissingPolicy `json:"onMissing,omitempty"`
+
+	// RolloutTargets are workloads in the namespace of the PassboltSecret that are restarted when the data of the secret changes.
+	// Workloads are also restarted if they carry the label passbolt.tagesspiegel.de/rollout with the name of the PassboltSecret.
+	// +kubebuilder:validation:Optional
+	RolloutTargets []RolloutTarget `json:"rolloutTargets,omitempty"`
 }
 
+// RolloutLabel is the label of a Deployment, StatefulSet or DaemonSet whose value is the name of the PassboltSecret
+// in the same namespace the workload is restarted for when the data of the secret changes.
+const RolloutLabel = "passbolt.tagesspiegel.de/rollout"
+
+const (
+	// RolloutTargetKindDeployment is the kind of a Deployment rollout target.
+	RolloutTargetKindDeployment = "Deployment"
+	// RolloutTargetKindStatefulSet is the kind of a StatefulSet rollout target.
+	RolloutTargetKindStatefulSet = "StatefulSet"
+	// RolloutTargetKindDaemonSet is the kind of a DaemonSet rollout target.
+	RolloutTargetKindDaemonSet = "DaemonSet"
+)
+
+// RolloutTarget references a workload that is restarted when the data of the secret changes.
+type RolloutTarget struct {
+	// Kind is the kind of the workload.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	Kind string `json:"kind"`
+	// Name is the name of the workload in the namespace of the PassboltSecret.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// OnMissingPolicy defines how a secret is synced if a referenced passbolt resource does not exist.
+type OnMissingPolicy string
+
+const (
+	// OnMissingFail fails the sync and leaves the secret unchanged. This is the default.
+	OnMissingFail OnMissingPolicy = "Fail"
+	// OnMissingKeep keeps the last synced value of the keys of the missing resource.
+	OnMissingKeep OnMissingPolicy = "Keep"
+	// OnMissingDeleteKey removes the keys of the missing resource from the secret.
+	// Secrets of type kubernetes.io/dockerconfigjson consist of a single key, so they are deleted instead.
+	OnMissingDeleteKey OnMissingPolicy = "DeleteKey"
+	// OnMissingDeleteSecret deletes the secret.
+	OnMissingDeleteSecret OnMissingPolicy = "DeleteSecret"
+)
+
 type FieldName string
 
 const (
 	FieldNameUsername FieldName = "username"
 	FieldNamePassword FieldName = "password"
 	FieldNameUri      FieldName = "uri"
+	// FieldNameName is the name of the resource.
+	FieldNameName FieldName = "name"
+	// FieldNameDescription is the description of the resource, either stored in the metadata or in the secret.
+	FieldNameDescription FieldName = "description"
+	// FieldNameTOTP is the TOTP code, computed at the time of the sync.
+	FieldNameTOTP FieldName = "totp"
+	// FieldNameTOTPSecret is the raw (base32 encoded) TOTP seed.
+	FieldNameTOTPSecret FieldName = "totp_secret"
+	// FieldNameTOTPURI is the otpauth URI of the TOTP seed.
+	FieldNameTOTPURI FieldName = "totp_uri"
 )
 
+// wellKnownFieldNames are the field names that are not resolved as additional fields of the secret.
+var wellKnownFieldNames = []FieldName{
+	FieldNameUsername,
+	FieldNamePassword,
+	FieldNameUri,
+	FieldNameName,
+	FieldNameDescription,
+	FieldNameTOTP,
+	FieldNameTOTPSecret,
+	FieldNameTOTPURI,
+}
+
 type PassboltSecretRef struct {
-	// Name of the secret in passbolt
-	// +kubebuilder:validation:Required
-	ID string `json:"id"`
+	// ID is the ID of the secret in passbolt.
+	// Exactly one of ID, Name and FolderPath must be set.
+	// +kubebuilder:validation:Optional
+	ID string `json:"id,omitempty"`
+	// Name is the name of the secret in passbolt.
+	// The name must be unique across all secrets the operator has access to.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+	// FolderPath is the path of the secret in passbolt, consisting of the folder names and the name of the secret,
+	// e.g. infra/prod/postgres.
+	// +kubebuilder:validation:Optional
+	FolderPath string `json:"folderPath,omitempty"`
 	// Field is the field in the passbolt secret to be read.
+	// Besides username, password, uri, name and description, any additional field of the secret can be read,
+	// e.g. the custom fields of passbolt v5 resources.
+	// For resources with TOTP, totp returns the current code, totp_secret the seed and totp_uri the otpauth URI.
+	// If the current code is read, the secret is re-synced when the code expires.
 	// +kubebuilder:validation:Optional
-	// +kubebuilder:validation:Enum=username;password;uri
 	Field FieldName `json:"field,omitempty"`
 	// Value is the plain text value of the secret.
 	// This field allows to set a static value or using go templating to generate the value.
@@ -74,10 +189,98 @@ type PassboltSecretRef struct {
 	//   - Password
 	//   - Username
 	//   - URI
+	//   - Name
+	//   - Description
+	//   - Fields (map of all additional fields, e.g. {{ index .Fields "api_key" }})
+	//   - TOTPCode, TOTPSecret and TOTPURI
 	// +kubebuilder:validation:Optional
 	Value *string `json:"value,omitempty"`
 }
 
+// Selector returns a human readable representation of the reference to the passbolt secret.
+func (r PassboltSecretRef) Selector() string {
+	switch {
+	case r.Name != "":
+		return "name:" + r.Name
+	case r.FolderPath != "":
+		return "folderPath:" + strings.Trim(r.FolderPath, "/")
+	default:
+		return r.ID
+	}
+}
+
+// DefaultKeyTemplate is the key template used if PassboltResourceMapping.KeyTemplate is not set.
+const DefaultKeyTemplate = `{{ .Name | snakecase }}_{{ .Field }}`
+
+// DefaultMappedFields are the fields used if PassboltResourceMapping.Fields is not set.
+var DefaultMappedFields = []FieldName{FieldNameUsername, FieldNamePassword, FieldNameUri}
+
+// PassboltResourceMapping defines how the fields of a set of passbolt resources are mapped to keys in the K8s secret.
+type PassboltResourceMapping struct {
+	// KeyTemplate is a go template that renders the key in the K8s secret for each field of each resource.
+	// Supported variables are ID, Name (the name of the resource) and Field, sprig functions are available.
+	// Defaults to {{ .Name | snakecase }}_{{ .Field }}.
+	// +kubebuilder:validation:Optional
+	KeyTemplate string `json:"keyTemplate,omitempty"`
+	// Fields are the fields of each resource that are synced to the secret.
+	// Fields that do not exist in a resource are skipped. Defaults to username, password and uri.
+	// +kubebuilder:validation:Optional
+	Fields []FieldName `json:"fields,omitempty"`
+}
+
+// PassboltFolderRef references a passbolt folder whose resources are synced to the secret.
+// Only the resources directly inside the folder are synced, resources of sub folders are ignored.
+type PassboltFolderRef struct {
+	// ID is the ID of the folder in passbolt.
+	// Exactly one of ID and Path must be set.
+	// +kubebuilder:validation:Optional
+	ID string `json:"id,omitempty"`
+	// Path is the path of the folder in passbolt, consisting of the names of the folder and its parents, e.g. infra/prod.
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+
+	PassboltResourceMapping `json:",inline"`
+}
+
+// Selector returns a human readable representation of the reference to the passbolt folder.
+func (r PassboltFolderRef) Selector() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return "path:" + strings.Trim(r.Path, "/")
+}
+
+// PassboltTagSelector selects all passbolt resources that carry all of the given tags.
+type PassboltTagSelector struct {
+	// Tags are the slugs of the tags a resource must carry, e.g. k8s:payments.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Tags []string `json:"tags"`
+
+	PassboltResourceMapping `json:",inline"`
+}
+
+const (
+	// PassboltServerKind is the kind of namespaced passbolt servers.
+	PassboltServerKind = "PassboltServer"
+	// ClusterPassboltServerKind is the kind of cluster wide passbolt servers.
+	ClusterPassboltServerKind = "ClusterPassboltServer"
+)
+
+// PassboltServerRef references a PassboltServer or ClusterPassboltServer.
+type PassboltServerRef struct {
+	// Kind is the kind of the referenced server.
+	// A PassboltServer must be in the namespace of the referencing object.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=PassboltServer;ClusterPassboltServer
+	// +kubebuilder:default=ClusterPassboltServer
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the referenced server.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
 type SyncStatus string
 
 const (
@@ -101,6 +304,50 @@ func (s SyncError) Error() string {
 	return fmt.Sprintf("failed to sync secret %s/%s: %s", s.PassboltSecretID, s.SecretKey, s.Message)
 }
 
+const (
+	// ConditionTypeReady is True if the Kubernetes secret is synced and the PassboltSecret is not degraded.
+	ConditionTypeReady = "Ready"
+	// ConditionTypeSynced is True if the last sync of the Kubernetes secret succeeded.
+	ConditionTypeSynced = "Synced"
+	// ConditionTypePassboltReachable is True if the passbolt server answered the calls of the last sync.
+	ConditionTypePassboltReachable = "PassboltReachable"
+	// ConditionTypeDegraded is True if the Kubernetes secret is served with outdated or incomplete data,
+	// e.g. because a referenced resource does not exist or the last sync failed.
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypeResourceNotFound is True if a passbolt resource referenced by the PassboltSecret does not exist.
+	ConditionTypeResourceNotFound = "ResourceNotFound"
+
+	// ReasonSynced is the reason of the Synced and Ready conditions if the sync succeeded.
+	ReasonSynced = "Synced"
+	// ReasonInvalidSpec is the reason of the Synced condition if the spec of the PassboltSecret cannot be synced.
+	ReasonInvalidSpec = "InvalidSpec"
+	// ReasonPassboltUnavailable is the reason of the Synced and PassboltReachable conditions if passbolt is not available.
+	ReasonPassboltUnavailable = "PassboltUnavailable"
+	// ReasonAccessDenied is the reason of the Synced condition if a reference is not allowed by the access policies.
+	ReasonAccessDenied = "AccessDenied"
+	// ReasonSyncFailed is the reason of the Synced condition if the Kubernetes secret could not be synced.
+	ReasonSyncFailed = "SyncFailed"
+	// ReasonSecretDeleted is the reason of the Synced condition if the Kubernetes secret was deleted
+	// because of the OnMissing policy DeleteSecret.
+	ReasonSecretDeleted = "SecretDeleted"
+	// ReasonReachable is the reason of the PassboltReachable condition if passbolt answered all calls.
+	ReasonReachable = "Reachable"
+	// ReasonStaleData is the reason of the Degraded condition if the Kubernetes secret of a previous sync is served
+	// because the last sync failed.
+	ReasonStaleData = "StaleData"
+	// ReasonHealthy is the reason of the Degraded condition if the PassboltSecret is not degraded.
+	ReasonHealthy = "Healthy"
+	// ReasonDegraded is the reason of the Ready condition if the PassboltSecret is synced but degraded.
+	ReasonDegraded = "Degraded"
+	// ReasonResourceMissing is the reason of the ResourceNotFound and Degraded conditions if a referenced resource does not exist.
+	ReasonResourceMissing = "ResourceMissing"
+	// ReasonResourcesFound is the reason of the ResourceNotFound condition if all referenced resources exist.
+	ReasonResourcesFound = "ResourcesFound"
+	// ReasonRolloutFailed is the reason of the Degraded condition if the workloads could not be restarted
+	// after the data of the secret changed.
+	ReasonRolloutFailed = "RolloutFailed"
+)
+
 // PassboltSecretStatus defines the observed state of PassboltSecret
 type PassboltSecretStatus struct {
 	// SyncStatus is the status of the last sync.
@@ -112,11 +359,25 @@ type PassboltSecretStatus struct {
 	LastSync metav1.Time `json:"lastSync"`
 	// SyncErrors is a list of errors that occurred during the last sync.
 	SyncErrors []SyncError `json:"syncErrors,omitempty"`
+	// Conditions are the latest observations of the state of the PassboltSecret.
+	// The Ready, Synced, PassboltReachable, Degraded and ResourceNotFound conditions are maintained by the operator.
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ObservedGeneration is the generation of the PassboltSecret observed by the last sync.
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// DataHash is the HMAC-SHA256 of the data of the secret synced by the last successful sync, keyed with a key of the operator.
+	// It only changes if the data of the secret changes. Expiring values, e.g. TOTP codes, are left out.
+	// +kubebuilder:validation:Optional
+	DataHash string `json:"dataHash,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 //+kubebuilder:printcolumn:name="Sync Status",type=string,JSONPath=`.status.syncStatus`
 //+kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSync`
 
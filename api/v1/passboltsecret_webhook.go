@@ -19,6 +19,8 @@ package v1
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,15 +38,29 @@ var (
 	ErrFieldOrValueIsRequired         = errors.New("field or value is required")
 	ErrSecretsAreRequired             = errors.New("secrets are required")
 	ErrPassboltSecretNameIsNotAllowed = errors.New("passboltSecretName is not allowed")
+	ErrInvalidRefreshInterval         = errors.New("refreshInterval must not be negative")
+	ErrInvalidFieldName               = errors.New("invalid field name")
+	ErrAmbiguousSecretReference       = errors.New("exactly one of id, name and folderPath is required")
+	ErrInvalidPassboltFolder          = errors.New("exactly one of id and path of passboltFolder is required")
+	ErrPassboltFolderIsNotAllowed     = errors.New("passboltFolder is not allowed")
+	ErrInvalidTagSelector             = errors.New("tagSelector requires at least one non-empty tag")
+	ErrTagSelectorIsNotAllowed        = errors.New("tagSelector is not allowed")
+	ErrReferenceNotAllowed            = errors.New("reference is not allowed by the access policies of the namespace")
+	ErrAccessPolicyCheckerIsRequired  = errors.New("an access policy checker is required")
 )
 
 // log is for logging in this package.
 var passboltsecretlog = logf.Log.WithName("passboltsecret-resource")
 
-// SetupWebhookWithManager will setup the manager to manage the webhooks
-func (r *PassboltSecret) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// SetupWebhookWithManager will setup the manager to manage the webhooks.
+// The references of PassboltSecrets are checked against the access policies of their namespace by the given checker.
+func (r *PassboltSecret) SetupWebhookWithManager(mgr ctrl.Manager, policies AccessPolicyChecker) error {
+	if policies == nil {
+		return ErrAccessPolicyCheckerIsRequired
+	}
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
+		WithValidator(&PassboltValidator{Policies: policies}).
 		Complete()
 }
 
@@ -65,14 +81,33 @@ func (r *PassboltSecret) Default() {
 var _ webhook.Validator = &PassboltSecret{}
 
 func (r *PassboltSecret) validatePassboltSecret() error {
+	if r.Spec.RefreshInterval != nil && r.Spec.RefreshInterval.Duration < 0 {
+		return fmt.Errorf("%w for secret %s.%s: %s", ErrInvalidRefreshInterval, r.GetName(), r.GetNamespace(), r.Spec.RefreshInterval.Duration)
+	}
 	switch r.Spec.SecretType {
 	case corev1.SecretTypeOpaque:
 		if r.Spec.PassboltSecretID != nil {
 			return fmt.Errorf("%w for secret %s.%s type %s", ErrPassboltSecretNameIsNotAllowed, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
 		}
-		if len(r.Spec.PassboltSecrets) == 0 {
+		if len(r.Spec.PassboltSecrets) == 0 && r.Spec.PassboltFolder == nil && r.Spec.TagSelector == nil {
 			return fmt.Errorf("%w for secret %s.%s type %s", ErrSecretsAreRequired, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
 		}
+		if folder := r.Spec.PassboltFolder; folder != nil {
+			if countNonEmpty(folder.ID, strings.Trim(folder.Path, "/")) != 1 {
+				return fmt.Errorf("%w for secret %s.%s", ErrInvalidPassboltFolder, r.GetName(), r.GetNamespace())
+			}
+			if err := validateFieldNames(folder.Fields); err != nil {
+				return fmt.Errorf("%w for secret %s.%s: %w", ErrInvalidFieldName, r.GetName(), r.GetNamespace(), err)
+			}
+		}
+		if selector := r.Spec.TagSelector; selector != nil {
+			if len(selector.Tags) == 0 || slices.Contains(selector.Tags, "") {
+				return fmt.Errorf("%w for secret %s.%s", ErrInvalidTagSelector, r.GetName(), r.GetNamespace())
+			}
+			if err := validateFieldNames(selector.Fields); err != nil {
+				return fmt.Errorf("%w for secret %s.%s: %w", ErrInvalidFieldName, r.GetName(), r.GetNamespace(), err)
+			}
+		}
 		// check if only FieldName or Value is set
 		for _, secret := range r.Spec.PassboltSecrets {
 			if secret.Field == "" && secret.Value == nil {
@@ -81,6 +116,12 @@ func (r *PassboltSecret) validatePassboltSecret() error {
 			if secret.Field != "" && secret.Value != nil {
 				return fmt.Errorf("%w for secret %s.%s and field %v", ErrFieldAndValueAreNotAllowed, r.GetName(), r.GetNamespace(), secret)
 			}
+			if countNonEmpty(secret.ID, secret.Name, secret.FolderPath) != 1 {
+				return fmt.Errorf("%w for secret %s.%s and field %v", ErrAmbiguousSecretReference, r.GetName(), r.GetNamespace(), secret)
+			}
+			if err := validateFieldName(secret.Field); err != nil {
+				return fmt.Errorf("%w for secret %s.%s: %w", ErrInvalidFieldName, r.GetName(), r.GetNamespace(), err)
+			}
 		}
 		return nil
 	case corev1.SecretTypeDockerConfigJson:
@@ -93,12 +134,52 @@ func (r *PassboltSecret) validatePassboltSecret() error {
 		if len(r.Spec.PassboltSecrets) > 0 {
 			return fmt.Errorf("%w for secret %s.%s type %s", ErrSecretsAreNotAllowed, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
 		}
+		if r.Spec.PassboltFolder != nil {
+			return fmt.Errorf("%w for secret %s.%s type %s", ErrPassboltFolderIsNotAllowed, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
+		}
+		if r.Spec.TagSelector != nil {
+			return fmt.Errorf("%w for secret %s.%s type %s", ErrTagSelectorIsNotAllowed, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
+		}
 		return nil
 	default:
 		return fmt.Errorf("%w %s.%s: %s", ErrInvalidSecretType, r.GetName(), r.GetNamespace(), r.Spec.SecretType)
 	}
 }
 
+// validateFieldName checks that a field name does not differ from a well known field name only by case
+// or surrounding whitespace, since it would be resolved as an additional field that most likely does not exist.
+func validateFieldName(field FieldName) error {
+	normalized := FieldName(strings.ToLower(strings.TrimSpace(string(field))))
+	if normalized == field {
+		return nil
+	}
+	if slices.Contains(wellKnownFieldNames, normalized) {
+		return fmt.Errorf("field %q must be written as %q", field, normalized)
+	}
+	return nil
+}
+
+// validateFieldNames validates all given field names, see validateFieldName.
+func validateFieldNames(fields []FieldName) error {
+	for _, field := range fields {
+		if err := validateFieldName(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countNonEmpty returns the number of non-empty values.
+func countNonEmpty(values ...string) int {
+	count := 0
+	for _, v := range values {
+		if v != "" {
+			count++
+		}
+	}
+	return count
+}
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *PassboltSecret) ValidateCreate() (admission.Warnings, error) {
 	passboltsecretlog.Info("validate create", "name", r.Name)
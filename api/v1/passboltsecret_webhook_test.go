@@ -18,6 +18,7 @@ package v1
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	. "github.com/onsi/ginkgo/v2"
@@ -215,7 +216,7 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: "FieldNamePassword",
 						},
 					},
@@ -223,6 +224,86 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid Opaque secret description field",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"tls.crt": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+							Field: FieldNameDescription,
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid Opaque secret well known field name with wrong case",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"tls.crt": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+							Field: "Description",
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid Opaque secret referenced by folder path",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"password": PassboltSecretRef{
+							FolderPath: "infra/prod/postgres",
+							Field:      FieldNamePassword,
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid Opaque secret without id, name or folder path",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"password": PassboltSecretRef{
+							Field: FieldNamePassword,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid Opaque secret referenced by id and name",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"password": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+							Name:  "postgres",
+							Field: FieldNamePassword,
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid Opaque secret value is set",
 			fields: fields{
@@ -231,7 +312,7 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
 					},
@@ -251,7 +332,7 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 					}(),
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: "FieldNamePassword",
 						},
 					},
@@ -299,6 +380,82 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid Opaque secret refresh interval is set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+							Field: "FieldNamePassword",
+						},
+					},
+					RefreshInterval: &metav1.Duration{Duration: 5 * time.Minute},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid Opaque secret refresh interval is negative",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]PassboltSecretRef{
+						"test": PassboltSecretRef{
+							Field: "FieldNamePassword",
+						},
+					},
+					RefreshInterval: &metav1.Duration{Duration: -time.Minute},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid Opaque secret folder is set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltFolder: &PassboltFolderRef{
+						Path: "infra/prod",
+						PassboltResourceMapping: PassboltResourceMapping{
+							Fields: []FieldName{FieldNamePassword},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid Opaque secret folder id and path are set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltFolder: &PassboltFolderRef{
+						ID:   "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+						Path: "infra/prod",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid Opaque secret folder path is empty",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					PassboltFolder: &PassboltFolderRef{
+						Path: "/",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		// dockerconfigjson secret
 		{
 			name: "valid DockerConfigJson secret",
@@ -346,6 +503,54 @@ func TestPassboltSecret_validatePassboltSecret(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid Opaque secret tag selector is set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					TagSelector: &PassboltTagSelector{
+						Tags: []string{"k8s:payments"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid Opaque secret tag selector without tags",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete: true,
+					SecretType:    corev1.SecretTypeOpaque,
+					TagSelector:   &PassboltTagSelector{},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid DockerConfigJson secret tag selector is set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete:    true,
+					SecretType:       corev1.SecretTypeDockerConfigJson,
+					PassboltSecretID: func() *string { s := "test"; return &s }(),
+					TagSelector:      &PassboltTagSelector{Tags: []string{"k8s:payments"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid DockerConfigJson secret folder is set",
+			fields: fields{
+				Spec: PassboltSecretSpec{
+					LeaveOnDelete:    true,
+					SecretType:       corev1.SecretTypeDockerConfigJson,
+					PassboltSecretID: func() *string { s := "test"; return &s }(),
+					PassboltFolder:   &PassboltFolderRef{ID: "test"},
+				},
+			},
+			wantErr: true,
+		},
 		// unsupported secret type
 		{
 			name: "invalid secret type SecretTypeBasicAuth",
@@ -444,7 +649,7 @@ func TestPassboltSecret_ValidateCreate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -460,7 +665,7 @@ func TestPassboltSecret_ValidateCreate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
 					},
@@ -480,7 +685,7 @@ func TestPassboltSecret_ValidateCreate(t *testing.T) {
 					}(),
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
-							ID:    "",
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -520,6 +725,7 @@ func TestPassboltSecret_ValidateCreate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
@@ -677,6 +883,7 @@ func TestPassboltSecret_ValidateUpdate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -692,6 +899,7 @@ func TestPassboltSecret_ValidateUpdate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
 					},
@@ -711,6 +919,7 @@ func TestPassboltSecret_ValidateUpdate(t *testing.T) {
 					}(),
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -750,6 +959,7 @@ func TestPassboltSecret_ValidateUpdate(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
@@ -903,6 +1113,7 @@ func TestPassboltSecret_ValidateDelete(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -918,6 +1129,7 @@ func TestPassboltSecret_ValidateDelete(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
 					},
@@ -937,6 +1149,7 @@ func TestPassboltSecret_ValidateDelete(t *testing.T) {
 					}(),
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 						},
 					},
@@ -976,6 +1189,7 @@ func TestPassboltSecret_ValidateDelete(t *testing.T) {
 					SecretType:    corev1.SecretTypeOpaque,
 					PassboltSecrets: map[string]PassboltSecretRef{
 						"test": PassboltSecretRef{
+							ID:    "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
 							Field: FieldNamePassword,
 							Value: func() *string { s := "host={{.URI}}"; return &s }(),
 						},
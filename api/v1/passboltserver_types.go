@@ -0,0 +1,107 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultPrivateKeyKey is the default key of the private key in the credentials secret.
+	DefaultPrivateKeyKey = "privateKey"
+	// DefaultPasswordKey is the default key of the passphrase of the private key in the credentials secret.
+	DefaultPasswordKey = "password"
+)
+
+// PassboltServerSpec defines the desired state of PassboltServer and ClusterPassboltServer
+type PassboltServerSpec struct {
+	// URL is the URL of the passbolt server, e.g. https://passbolt.example.com.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+	// CredentialsSecretRef references the Kubernetes secret that holds the credentials of the passbolt user.
+	// +kubebuilder:validation:Required
+	CredentialsSecretRef PassboltCredentialsSecretRef `json:"credentialsSecretRef"`
+}
+
+// PassboltCredentialsSecretRef references a Kubernetes secret holding the private key and passphrase of a passbolt user.
+type PassboltCredentialsSecretRef struct {
+	PassboltCredentialsRef `json:",inline"`
+	// Namespace is the namespace of the secret. It is only used by ClusterPassboltServers,
+	// the secret of a PassboltServer must be in the namespace of the PassboltServer.
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PassboltCredentialsRef references a Kubernetes secret in the namespace of the referencing object
+// holding the private key and passphrase of a passbolt user.
+type PassboltCredentialsRef struct {
+	// Name is the name of the secret.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// PrivateKeyKey is the key of the armored private GPG key of the user in the secret.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=privateKey
+	PrivateKeyKey string `json:"privateKeyKey,omitempty"`
+	// PasswordKey is the key of the passphrase of the private key in the secret.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=password
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// PassboltServerStatus defines the observed state of PassboltServer and ClusterPassboltServer
+type PassboltServerStatus struct {
+	// SyncStatus is the status of the last login to the passbolt server.
+	// +kubebuilder:validation:Enum=Success;Error;Unknown
+	// +kubebuilder:default=Unknown
+	SyncStatus SyncStatus `json:"syncStatus"`
+	// LastSync is the last time the operator logged in to the passbolt server successfully.
+	// +kubebuilder:validation:Optional
+	LastSync metav1.Time `json:"lastSync"`
+	// SyncErrors is a list of errors that occurred during the last login.
+	SyncErrors []SyncError `json:"syncErrors,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
+//+kubebuilder:printcolumn:name="Sync Status",type=string,JSONPath=`.status.syncStatus`
+//+kubebuilder:printcolumn:name="Last Sync",type=string,JSONPath=`.status.lastSync`
+
+// PassboltServer is the Schema for the passboltservers API.
+// It defines a passbolt server that can be referenced by PassboltSecrets in the same namespace.
+type PassboltServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PassboltServerSpec   `json:"spec,omitempty"`
+	Status PassboltServerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PassboltServerList contains a list of PassboltServer
+type PassboltServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PassboltServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PassboltServer{}, &PassboltServerList{})
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+// PassboltPermission is the permission that is granted on a passbolt resource.
+type PassboltPermission string
+
+const (
+	PassboltPermissionRead   PassboltPermission = "Read"
+	PassboltPermissionUpdate PassboltPermission = "Update"
+	PassboltPermissionOwner  PassboltPermission = "Owner"
+)
+
+// PassboltShare defines a permission on a passbolt resource that is granted to a passbolt user or group.
+// Exactly one of User and Group must be set.
+type PassboltShare struct {
+	// User is the username (email address) of the passbolt user.
+	// +kubebuilder:validation:Optional
+	User string `json:"user,omitempty"`
+	// Group is the name of the passbolt group.
+	// +kubebuilder:validation:Optional
+	Group string `json:"group,omitempty"`
+	// Permission is the permission that is granted to the user or group.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Read;Update;Owner
+	// +kubebuilder:default=Read
+	Permission PassboltPermission `json:"permission,omitempty"`
+}
@@ -113,7 +113,7 @@ var _ = BeforeSuite(func() {
 	})
 	Expect(err).NotTo(HaveOccurred())
 
-	err = (&PassboltSecret{}).SetupWebhookWithManager(mgr)
+	err = (&PassboltSecret{}).SetupWebhookWithManager(mgr, fakeAccessPolicyChecker{})
 	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:webhook
@@ -21,9 +21,571 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPassboltServer) DeepCopyInto(out *ClusterPassboltServer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPassboltServer.
+func (in *ClusterPassboltServer) DeepCopy() *ClusterPassboltServer {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPassboltServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterPassboltServer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterPassboltServerList) DeepCopyInto(out *ClusterPassboltServerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterPassboltServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterPassboltServerList.
+func (in *ClusterPassboltServerList) DeepCopy() *ClusterPassboltServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterPassboltServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterPassboltServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltAccessPolicy) DeepCopyInto(out *PassboltAccessPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltAccessPolicy.
+func (in *PassboltAccessPolicy) DeepCopy() *PassboltAccessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltAccessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltAccessPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltAccessPolicyList) DeepCopyInto(out *PassboltAccessPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PassboltAccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltAccessPolicyList.
+func (in *PassboltAccessPolicyList) DeepCopy() *PassboltAccessPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltAccessPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltAccessPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltAccessPolicySpec) DeepCopyInto(out *PassboltAccessPolicySpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FolderIDs != nil {
+		in, out := &in.FolderIDs, &out.FolderIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FolderPaths != nil {
+		in, out := &in.FolderPaths, &out.FolderPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceIDs != nil {
+		in, out := &in.ResourceIDs, &out.ResourceIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltAccessPolicySpec.
+func (in *PassboltAccessPolicySpec) DeepCopy() *PassboltAccessPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltAccessPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltCredentialsRef) DeepCopyInto(out *PassboltCredentialsRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltCredentialsRef.
+func (in *PassboltCredentialsRef) DeepCopy() *PassboltCredentialsRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltCredentialsRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltCredentialsSecretRef) DeepCopyInto(out *PassboltCredentialsSecretRef) {
+	*out = *in
+	out.PassboltCredentialsRef = in.PassboltCredentialsRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltCredentialsSecretRef.
+func (in *PassboltCredentialsSecretRef) DeepCopy() *PassboltCredentialsSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltCredentialsSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltFolderRef) DeepCopyInto(out *PassboltFolderRef) {
+	*out = *in
+	in.PassboltResourceMapping.DeepCopyInto(&out.PassboltResourceMapping)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltFolderRef.
+func (in *PassboltFolderRef) DeepCopy() *PassboltFolderRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltFolderRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedResource) DeepCopyInto(out *PassboltGeneratedResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedResource.
+func (in *PassboltGeneratedResource) DeepCopy() *PassboltGeneratedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedSecret) DeepCopyInto(out *PassboltGeneratedSecret) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedSecret.
+func (in *PassboltGeneratedSecret) DeepCopy() *PassboltGeneratedSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltGeneratedSecret) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedSecretKey) DeepCopyInto(out *PassboltGeneratedSecretKey) {
+	*out = *in
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedSecretKey.
+func (in *PassboltGeneratedSecretKey) DeepCopy() *PassboltGeneratedSecretKey {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedSecretKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedSecretList) DeepCopyInto(out *PassboltGeneratedSecretList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PassboltGeneratedSecret, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedSecretList.
+func (in *PassboltGeneratedSecretList) DeepCopy() *PassboltGeneratedSecretList {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedSecretList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltGeneratedSecretList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedSecretSpec) DeepCopyInto(out *PassboltGeneratedSecretSpec) {
+	*out = *in
+	out.Resource = in.Resource
+	out.PasswordPolicy = in.PasswordPolicy
+	if in.Shares != nil {
+		in, out := &in.Shares, &out.Shares
+		*out = make([]PassboltShare, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretKeys != nil {
+		in, out := &in.SecretKeys, &out.SecretKeys
+		*out = make(map[string]PassboltGeneratedSecretKey, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.PlainTextFields != nil {
+		in, out := &in.PlainTextFields, &out.PlainTextFields
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ServerRef != nil {
+		in, out := &in.ServerRef, &out.ServerRef
+		*out = new(PassboltServerRef)
+		**out = **in
+	}
+	if in.CredentialsRef != nil {
+		in, out := &in.CredentialsRef, &out.CredentialsRef
+		*out = new(PassboltCredentialsRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedSecretSpec.
+func (in *PassboltGeneratedSecretSpec) DeepCopy() *PassboltGeneratedSecretSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedSecretSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltGeneratedSecretStatus) DeepCopyInto(out *PassboltGeneratedSecretStatus) {
+	*out = *in
+	if in.Shares != nil {
+		in, out := &in.Shares, &out.Shares
+		*out = make([]PassboltShare, len(*in))
+		copy(*out, *in)
+	}
+	in.LastSync.DeepCopyInto(&out.LastSync)
+	if in.SyncErrors != nil {
+		in, out := &in.SyncErrors, &out.SyncErrors
+		*out = make([]SyncError, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltGeneratedSecretStatus.
+func (in *PassboltGeneratedSecretStatus) DeepCopy() *PassboltGeneratedSecretStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltGeneratedSecretStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushResource) DeepCopyInto(out *PassboltPushResource) {
+	*out = *in
+	out.Password = in.Password
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(PassboltPushValue)
+		**out = **in
+	}
+	if in.URI != nil {
+		in, out := &in.URI, &out.URI
+		*out = new(PassboltPushValue)
+		**out = **in
+	}
+	if in.Description != nil {
+		in, out := &in.Description, &out.Description
+		*out = new(PassboltPushValue)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushResource.
+func (in *PassboltPushResource) DeepCopy() *PassboltPushResource {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushSecret) DeepCopyInto(out *PassboltPushSecret) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushSecret.
+func (in *PassboltPushSecret) DeepCopy() *PassboltPushSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltPushSecret) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushSecretList) DeepCopyInto(out *PassboltPushSecretList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PassboltPushSecret, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushSecretList.
+func (in *PassboltPushSecretList) DeepCopy() *PassboltPushSecretList {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushSecretList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltPushSecretList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushSecretSpec) DeepCopyInto(out *PassboltPushSecretSpec) {
+	*out = *in
+	in.Resource.DeepCopyInto(&out.Resource)
+	if in.Shares != nil {
+		in, out := &in.Shares, &out.Shares
+		*out = make([]PassboltShare, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServerRef != nil {
+		in, out := &in.ServerRef, &out.ServerRef
+		*out = new(PassboltServerRef)
+		**out = **in
+	}
+	if in.CredentialsRef != nil {
+		in, out := &in.CredentialsRef, &out.CredentialsRef
+		*out = new(PassboltCredentialsRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushSecretSpec.
+func (in *PassboltPushSecretSpec) DeepCopy() *PassboltPushSecretSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushSecretSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushSecretStatus) DeepCopyInto(out *PassboltPushSecretStatus) {
+	*out = *in
+	if in.Shares != nil {
+		in, out := &in.Shares, &out.Shares
+		*out = make([]PassboltShare, len(*in))
+		copy(*out, *in)
+	}
+	in.LastSync.DeepCopyInto(&out.LastSync)
+	if in.SyncErrors != nil {
+		in, out := &in.SyncErrors, &out.SyncErrors
+		*out = make([]SyncError, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushSecretStatus.
+func (in *PassboltPushSecretStatus) DeepCopy() *PassboltPushSecretStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushSecretStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltPushValue) DeepCopyInto(out *PassboltPushValue) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltPushValue.
+func (in *PassboltPushValue) DeepCopy() *PassboltPushValue {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltPushValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltResourceMapping) DeepCopyInto(out *PassboltResourceMapping) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]FieldName, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltResourceMapping.
+func (in *PassboltResourceMapping) DeepCopy() *PassboltResourceMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltResourceMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PassboltSecret) DeepCopyInto(out *PassboltSecret) {
 	*out = *in
@@ -118,6 +680,16 @@ func (in *PassboltSecretSpec) DeepCopyInto(out *PassboltSecretSpec) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.PassboltFolder != nil {
+		in, out := &in.PassboltFolder, &out.PassboltFolder
+		*out = new(PassboltFolderRef)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TagSelector != nil {
+		in, out := &in.TagSelector, &out.TagSelector
+		*out = new(PassboltTagSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.PlainTextFields != nil {
 		in, out := &in.PlainTextFields, &out.PlainTextFields
 		*out = make(map[string]string, len(*in))
@@ -125,6 +697,26 @@ func (in *PassboltSecretSpec) DeepCopyInto(out *PassboltSecretSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ServerRef != nil {
+		in, out := &in.ServerRef, &out.ServerRef
+		*out = new(PassboltServerRef)
+		**out = **in
+	}
+	if in.CredentialsRef != nil {
+		in, out := &in.CredentialsRef, &out.CredentialsRef
+		*out = new(PassboltCredentialsRef)
+		**out = **in
+	}
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.RolloutTargets != nil {
+		in, out := &in.RolloutTargets, &out.RolloutTargets
+		*out = make([]RolloutTarget, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltSecretSpec.
@@ -148,6 +740,13 @@ func (in *PassboltSecretStatus) DeepCopyInto(out *PassboltSecretStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltSecretStatus.
@@ -160,6 +759,185 @@ func (in *PassboltSecretStatus) DeepCopy() *PassboltSecretStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltServer) DeepCopyInto(out *PassboltServer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltServer.
+func (in *PassboltServer) DeepCopy() *PassboltServer {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltServer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltServerList) DeepCopyInto(out *PassboltServerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PassboltServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltServerList.
+func (in *PassboltServerList) DeepCopy() *PassboltServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PassboltServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltServerRef) DeepCopyInto(out *PassboltServerRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltServerRef.
+func (in *PassboltServerRef) DeepCopy() *PassboltServerRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltServerRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltServerSpec) DeepCopyInto(out *PassboltServerSpec) {
+	*out = *in
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltServerSpec.
+func (in *PassboltServerSpec) DeepCopy() *PassboltServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltServerStatus) DeepCopyInto(out *PassboltServerStatus) {
+	*out = *in
+	in.LastSync.DeepCopyInto(&out.LastSync)
+	if in.SyncErrors != nil {
+		in, out := &in.SyncErrors, &out.SyncErrors
+		*out = make([]SyncError, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltServerStatus.
+func (in *PassboltServerStatus) DeepCopy() *PassboltServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltShare) DeepCopyInto(out *PassboltShare) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltShare.
+func (in *PassboltShare) DeepCopy() *PassboltShare {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltShare)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassboltTagSelector) DeepCopyInto(out *PassboltTagSelector) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.PassboltResourceMapping.DeepCopyInto(&out.PassboltResourceMapping)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassboltTagSelector.
+func (in *PassboltTagSelector) DeepCopy() *PassboltTagSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(PassboltTagSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PasswordPolicy) DeepCopyInto(out *PasswordPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PasswordPolicy.
+func (in *PasswordPolicy) DeepCopy() *PasswordPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PasswordPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutTarget) DeepCopyInto(out *RolloutTarget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutTarget.
+func (in *RolloutTarget) DeepCopy() *RolloutTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SyncError) DeepCopyInto(out *SyncError) {
 	*out = *in
@@ -37,22 +37,38 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 	passboltsecretlog.V(100).Info("converting PassboltSecret v1alpha2 to v1")
 	dst := dstRaw.(*v1.PassboltSecret)
 	dst.ObjectMeta = src.ObjectMeta
-	src.Spec.LeaveOnDelete = dst.Spec.LeaveOnDelete
+	dst.Spec.LeaveOnDelete = src.Spec.LeaveOnDelete
 	dst.Spec.SecretType = src.Spec.SecretType
 
 	// migrate secrets of type Opaque
 	if src.Spec.SecretType == corev1.SecretTypeOpaque {
+		preserved, err := v1.PreservedSpec(src.ObjectMeta)
+		if err != nil {
+			return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+		}
 		dst.Spec.PassboltSecrets = make(map[string]v1.PassboltSecretRef)
 		for i, s := range src.Spec.Secrets {
-			pbID, err := GetSecretID(s.PassboltSecret.Name)
-			if err != nil {
-				return fmt.Errorf("error migrating secret %s at index %d: %w", s.PassboltSecret.Name, i, err)
+			key := s.KubernetesSecretKey
+			// plain text fields are represented as secrets named after their key
+			if value, ok := preserved.PlainTextFields[key]; ok && s.PassboltSecret.Name == key && s.PassboltSecret.Value != nil && *s.PassboltSecret.Value == value {
+				if dst.Spec.PlainTextFields == nil {
+					dst.Spec.PlainTextFields = map[string]string{}
+				}
+				dst.Spec.PlainTextFields[key] = value
+				continue
 			}
-			dst.Spec.PassboltSecrets[s.KubernetesSecretKey] = v1.PassboltSecretRef{
-				ID:    pbID,
+			ref := v1.PassboltSecretRef{
 				Field: v1.FieldName(s.PassboltSecret.Field),
 				Value: s.PassboltSecret.Value,
 			}
+			// references by name or folder path are restored by RestoreFields instead of being resolved to an ID
+			if preservedRef, ok := preserved.PassboltSecrets[key]; !ok || preservedRef.Name != s.PassboltSecret.Name {
+				ref.ID, err = GetSecretID(s.PassboltSecret.Name)
+				if err != nil {
+					return fmt.Errorf("error migrating secret %s at index %d: %w", s.PassboltSecret.Name, i, err)
+				}
+			}
+			dst.Spec.PassboltSecrets[key] = ref
 		}
 	}
 
@@ -67,7 +83,7 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 
 	dst.Status.LastSync = src.Status.LastSync
 	dst.Status.SyncStatus = v1.SyncStatus(src.Status.SyncStatus)
-	dst.Status.SyncErrors = make([]v1.SyncError, len(src.Status.SyncErrors))
+	dst.Status.SyncErrors = make([]v1.SyncError, 0, len(src.Status.SyncErrors))
 	for _, se := range src.Status.SyncErrors {
 		dst.Status.SyncErrors = append(dst.Status.SyncErrors, v1.SyncError{
 			Message:          se.Message,
@@ -76,6 +92,12 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 			Time:             se.Time,
 		})
 	}
+	if err := dst.RestoreConditions(); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
+	if err := dst.RestoreFields(); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
 	return nil
 }
 
@@ -90,14 +112,19 @@ func (dst *PassboltSecret) ConvertFrom(srcRaw conversion.Hub) error {
 	if src.Spec.SecretType == corev1.SecretTypeOpaque {
 		dst.Spec.Secrets = []SecretSpec{}
 		for i, s := range src.Spec.PassboltSecrets {
-			id, err := GetSecretName(s.ID)
-			if err != nil {
-				return fmt.Errorf("error migrating secret %s at index %s: %w", s.ID, i, err)
+			// references by name or folder path are preserved by PreserveFields
+			name := s.Name
+			if s.ID != "" {
+				var err error
+				name, err = GetSecretName(s.ID)
+				if err != nil {
+					return fmt.Errorf("error migrating secret %s at index %s: %w", s.ID, i, err)
+				}
 			}
 			dst.Spec.Secrets = append(dst.Spec.Secrets, SecretSpec{
 				KubernetesSecretKey: i,
 				PassboltSecret: PassboltSpec{
-					Name:  id,
+					Name:  name,
 					Field: FieldName(s.Field),
 					Value: s.Value,
 				},
@@ -124,7 +151,7 @@ func (dst *PassboltSecret) ConvertFrom(srcRaw conversion.Hub) error {
 
 	dst.Status.LastSync = src.Status.LastSync
 	dst.Status.SyncStatus = SyncStatus(src.Status.SyncStatus)
-	dst.Status.SyncErrors = make([]SyncError, len(src.Status.SyncErrors))
+	dst.Status.SyncErrors = make([]SyncError, 0, len(src.Status.SyncErrors))
 	for _, se := range src.Status.SyncErrors {
 		dst.Status.SyncErrors = append(dst.Status.SyncErrors, SyncError{
 			Message:    se.Message,
@@ -133,5 +160,11 @@ func (dst *PassboltSecret) ConvertFrom(srcRaw conversion.Hub) error {
 			Time:       se.Time,
 		})
 	}
+	if err := src.Status.PreserveConditions(&dst.ObjectMeta); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
+	if err := src.PreserveFields(&dst.ObjectMeta); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
 	return nil
 }
@@ -18,6 +18,7 @@ package v1alpha2
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
@@ -385,3 +386,108 @@ func TestPassboltSecret_ConvertFrom(t *testing.T) {
 		})
 	}
 }
+
+func TestPassboltSecret_ConditionsRoundTrip(t *testing.T) {
+	now := metav1.Now().Rfc3339Copy()
+	src := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "example-passboltsecret",
+			Namespace:   "default",
+			Generation:  3,
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: passboltv1.PassboltSecretSpec{
+			SecretType:       corev1.SecretTypeDockerConfigJson,
+			PassboltSecretID: func() *string { s := "example-id"; return &s }(),
+		},
+		Status: passboltv1.PassboltSecretStatus{
+			ObservedGeneration: 3,
+			Conditions: []metav1.Condition{
+				{
+					Type:               passboltv1.ConditionTypeReady,
+					Status:             metav1.ConditionTrue,
+					ObservedGeneration: 3,
+					LastTransitionTime: now,
+					Reason:             passboltv1.ReasonSynced,
+					Message:            "secret is synced",
+				},
+			},
+		},
+	}
+
+	converted := &PassboltSecret{}
+	if err := converted.ConvertFrom(src); err != nil {
+		t.Fatalf("PassboltSecret.ConvertFrom() error = %v", err)
+	}
+	if _, ok := converted.Annotations[passboltv1.ConditionsAnnotation]; !ok {
+		t.Fatalf("PassboltSecret.ConvertFrom() annotation %s is missing", passboltv1.ConditionsAnnotation)
+	}
+	if _, ok := src.Annotations[passboltv1.ConditionsAnnotation]; ok {
+		t.Fatalf("PassboltSecret.ConvertFrom() modified the annotations of the source")
+	}
+
+	got := &passboltv1.PassboltSecret{}
+	if err := converted.ConvertTo(got); err != nil {
+		t.Fatalf("PassboltSecret.ConvertTo() error = %v", err)
+	}
+	if diff := cmp.Diff(src.Annotations, got.Annotations); diff != "" {
+		t.Errorf("PassboltSecret.ConvertTo() annotations (-want, +got) = %v", diff)
+	}
+	if diff := cmp.Diff(src.Status.Conditions, got.Status.Conditions); diff != "" {
+		t.Errorf("PassboltSecret.ConvertTo() conditions (-want, +got) = %v", diff)
+	}
+	if got.Status.ObservedGeneration != src.Status.ObservedGeneration {
+		t.Errorf("PassboltSecret.ConvertTo() observedGeneration = %d, want %d", got.Status.ObservedGeneration, src.Status.ObservedGeneration)
+	}
+}
+
+func TestPassboltSecret_FieldsRoundTrip(t *testing.T) {
+	now := metav1.Now().Rfc3339Copy()
+	value := "{{ .Password }}"
+	src := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "example-passboltsecret",
+			Namespace:   "default",
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: passboltv1.PassboltSecretSpec{
+			LeaveOnDelete: true,
+			SecretType:    corev1.SecretTypeOpaque,
+			PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+				"by_id":          {ID: "example-id", Field: passboltv1.FieldNameUsername},
+				"by_name":        {Name: "APP_EXAMPLE", Field: passboltv1.FieldNamePassword},
+				"by_folder_path": {FolderPath: "team/APP_EXAMPLE", Value: &value},
+			},
+			PlainTextFields: map[string]string{"plain": "text"},
+			PassboltFolder:  &passboltv1.PassboltFolderRef{Path: "team/app"},
+			TagSelector:     &passboltv1.PassboltTagSelector{Tags: []string{"app"}},
+			ServerRef:       &passboltv1.PassboltServerRef{Kind: passboltv1.PassboltServerKind, Name: "team"},
+			CredentialsRef:  &passboltv1.PassboltCredentialsRef{Name: "team-credentials"},
+			RefreshInterval: &metav1.Duration{Duration: time.Hour},
+			OnMissing:       passboltv1.OnMissingKeep,
+			RolloutTargets:  []passboltv1.RolloutTarget{{Kind: passboltv1.RolloutTargetKindDeployment, Name: "app"}},
+		},
+		Status: passboltv1.PassboltSecretStatus{
+			SyncStatus: passboltv1.SyncStatusSuccess,
+			LastSync:   now,
+			SyncErrors: []passboltv1.SyncError{{Message: "failed", PassboltSecretID: "example-id", SecretKey: "by_id", Time: now}},
+			DataHash:   "hash",
+		},
+	}
+
+	converted := &PassboltSecret{}
+	if err := converted.ConvertFrom(src); err != nil {
+		t.Fatalf("PassboltSecret.ConvertFrom() error = %v", err)
+	}
+	if _, ok := src.Annotations[passboltv1.FieldsAnnotation]; ok {
+		t.Fatalf("PassboltSecret.ConvertFrom() modified the annotations of the source")
+	}
+
+	got := &passboltv1.PassboltSecret{}
+	if err := converted.ConvertTo(got); err != nil {
+		t.Fatalf("PassboltSecret.ConvertTo() error = %v", err)
+	}
+	if diff := cmp.Diff(src, got); diff != "" {
+		t.Errorf("PassboltSecret round trip (-want, +got) = %v", diff)
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
+
 	v1 "github.com/urbanmedia/passbolt-operator/api/v1"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
@@ -31,7 +33,7 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 	passboltsecretlog.V(100).Info("converting PassboltSecret v1alpha3 to v1")
 	dst := dstRaw.(*v1.PassboltSecret)
 	dst.ObjectMeta = src.ObjectMeta
-	src.Spec.LeaveOnDelete = dst.Spec.LeaveOnDelete
+	dst.Spec.LeaveOnDelete = src.Spec.LeaveOnDelete
 	dst.Spec.SecretType = src.Spec.SecretType
 
 	// migrate secrets of type Opaque
@@ -54,7 +56,7 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 
 	dst.Status.LastSync = src.Status.LastSync
 	dst.Status.SyncStatus = v1.SyncStatus(src.Status.SyncStatus)
-	dst.Status.SyncErrors = make([]v1.SyncError, len(src.Status.SyncErrors))
+	dst.Status.SyncErrors = make([]v1.SyncError, 0, len(src.Status.SyncErrors))
 	for _, v := range src.Status.SyncErrors {
 		dst.Status.SyncErrors = append(dst.Status.SyncErrors, v1.SyncError{
 			Message:          v.Message,
@@ -63,6 +65,12 @@ func (src *PassboltSecret) ConvertTo(dstRaw conversion.Hub) error {
 			Time:             v.Time,
 		})
 	}
+	if err := dst.RestoreConditions(); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
+	if err := dst.RestoreFields(); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
 	return nil
 }
 
@@ -92,7 +100,7 @@ func (dst *PassboltSecret) ConvertFrom(srcRaw conversion.Hub) error {
 
 	dst.Status.LastSync = src.Status.LastSync
 	dst.Status.SyncStatus = SyncStatus(src.Status.SyncStatus)
-	dst.Status.SyncErrors = make([]SyncError, len(src.Status.SyncErrors))
+	dst.Status.SyncErrors = make([]SyncError, 0, len(src.Status.SyncErrors))
 	for _, se := range src.Status.SyncErrors {
 		dst.Status.SyncErrors = append(dst.Status.SyncErrors, SyncError{
 			Message:          se.Message,
@@ -101,5 +109,11 @@ func (dst *PassboltSecret) ConvertFrom(srcRaw conversion.Hub) error {
 			Time:             se.Time,
 		})
 	}
+	if err := src.Status.PreserveConditions(&dst.ObjectMeta); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
+	if err := src.PreserveFields(&dst.ObjectMeta); err != nil {
+		return fmt.Errorf("error migrating secret %s in namespace %s: %w", src.GetName(), src.GetNamespace(), err)
+	}
 	return nil
 }
@@ -19,7 +19,9 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"time"
 
@@ -28,11 +30,15 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -41,13 +47,12 @@ import (
 	passboltv1alpha3 "github.com/urbanmedia/passbolt-operator/api/v1alpha3"
 	"github.com/urbanmedia/passbolt-operator/internal/controller"
 	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
-	"github.com/urbanmedia/passbolt-operator/pkg/util"
 	//+kubebuilder:scaffold:imports
 )
 
 const (
-	// cacheLoadRetries defines how often we retry to load the cache
-	cacheLoadRetries = 3
+	// credentialsWatchInterval defines how often the credential files are checked for changes
+	credentialsWatchInterval = 30 * time.Second
 )
 
 var (
@@ -71,6 +76,17 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var defaultRefreshInterval time.Duration
+	var cacheRefreshInterval time.Duration
+	var requireCredentialsRef bool
+	var cacheMaxStaleness time.Duration
+	var livenessMaxCallAge time.Duration
+	var maxConcurrentReconciles int
+	var eventInterval time.Duration
+	var rolloutAnnotation string
+	var dataHashKeySecret string
+	var limits passbolt.Limits
+	var secretCache passbolt.SecretCacheOptions
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -80,6 +96,38 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.DurationVar(&defaultRefreshInterval, "default-refresh-interval", 0,
+		"The interval after which PassboltSecrets without a refreshInterval are re-synced from passbolt. "+
+			"0 disables the periodic re-sync.")
+	flag.DurationVar(&cacheRefreshInterval, "cache-refresh-interval", 5*time.Minute,
+		"The interval in which the resources and folders are reloaded from passbolt to detect changes, deletions and renames.")
+	flag.DurationVar(&cacheMaxStaleness, "cache-max-staleness", 30*time.Minute,
+		"The duration for which a stale passbolt cache is served while passbolt is unavailable before the cache is reported as degraded "+
+			"by the passbolt_cache_degraded metric. 0 never reports stale caches as degraded.")
+	flag.DurationVar(&livenessMaxCallAge, "liveness-max-call-age", 0,
+		"The time without a successful call to passbolt after which the liveness check fails. 0 disables the check.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The maximum number of concurrent reconciles per controller.")
+	flag.DurationVar(&eventInterval, "event-interval", 10*time.Minute,
+		"The interval in which an event equal to a recorded event of the same object is dropped. 0 records all events.")
+	flag.StringVar(&rolloutAnnotation, "rollout-annotation", "passbolt.tagesspiegel.de/data-hash",
+		"The annotation patched into the pod templates of workloads to restart them when the data of a PassboltSecret changes. "+
+			"A short hash of the name of the PassboltSecret is appended to it. An empty annotation disables the restart of workloads.")
+	flag.StringVar(&dataHashKeySecret, "data-hash-key-secret", "passbolt-operator-data-hash-key",
+		"The name of the Secret in the namespace of the operator (POD_NAMESPACE) holding the key of the data hashes of PassboltSecrets. "+
+			"The Secret is created with a random key if it does not exist.")
+	flag.Float64Var(&limits.QPS, "passbolt-qps", 20,
+		"The maximum number of calls per second to a passbolt server. 0 disables the rate limit.")
+	flag.IntVar(&limits.Burst, "passbolt-burst", 40,
+		"The number of calls to a passbolt server that may exceed --passbolt-qps at once.")
+	flag.IntVar(&limits.MaxInFlight, "passbolt-max-in-flight", 10,
+		"The maximum number of concurrent calls to a passbolt server. 0 disables the concurrency limit.")
+	flag.DurationVar(&secretCache.TTL, "secret-cache-ttl", time.Minute,
+		"The time decrypted passbolt secrets are cached, unless the resource is modified. 0 disables the cache.")
+	flag.IntVar(&secretCache.MaxEntries, "secret-cache-size", 1000,
+		"The maximum number of decrypted passbolt secrets cached per passbolt server. 0 does not limit the number of secrets.")
+	flag.BoolVar(&requireCredentialsRef, "require-credentials-ref", false,
+		"If set, PassboltSecrets, PassboltGeneratedSecrets and PassboltPushSecrets must reference the credentials of their own passbolt user and never access passbolt with the credentials of the operator.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -88,6 +136,20 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if cacheRefreshInterval <= 0 {
+		setupLog.Error(fmt.Errorf("invalid cache refresh interval %s", cacheRefreshInterval), "--cache-refresh-interval must be positive")
+		os.Exit(1)
+	}
+	if cacheMaxStaleness > 0 && cacheRefreshInterval > cacheMaxStaleness {
+		setupLog.Error(fmt.Errorf("cache refresh interval %s exceeds the cache max staleness %s", cacheRefreshInterval, cacheMaxStaleness),
+			"--cache-refresh-interval must not exceed --cache-max-staleness")
+		os.Exit(1)
+	}
+	if err := controller.ValidateRolloutAnnotation(rolloutAnnotation); rolloutAnnotation != "" && err != nil {
+		setupLog.Error(err, "--rollout-annotation must be a valid annotation")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -110,6 +172,9 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Controller: config.Controller{
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+		},
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -140,85 +205,148 @@ func main() {
 	ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cf()
 
-	// create passbolt client
-	clnt, err := passbolt.NewClient(ctx, os.Getenv("PASSBOLT_URL"), os.Getenv("PASSBOLT_GPG"), os.Getenv("PASSBOLT_PASSWORD"))
+	// the key of the data hashes is shared by all replicas and kept across restarts, so that the hashes only change with the data
+	operatorNamespace := os.Getenv("POD_NAMESPACE")
+	if operatorNamespace == "" {
+		operatorNamespace = "default"
+	}
+	uncachedClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes client")
+		os.Exit(1)
+	}
+	dataHashKey, err := controller.EnsureDataHashKey(ctx, uncachedClient, types.NamespacedName{Namespace: operatorNamespace, Name: dataHashKeySecret})
+	if err != nil {
+		setupLog.Error(err, "unable to get the data hash key")
+		os.Exit(1)
+	}
+
+	// the credentials are read from files, if configured, so that they can be rotated without a restart
+	privateKeyFile, passwordFile := os.Getenv("PASSBOLT_GPG_FILE"), os.Getenv("PASSBOLT_PASSWORD_FILE")
+	privateKey, password := os.Getenv("PASSBOLT_GPG"), os.Getenv("PASSBOLT_PASSWORD")
+	credentialsFromFiles := privateKeyFile != "" && passwordFile != ""
+	if (privateKeyFile != "") != (passwordFile != "") {
+		setupLog.Error(errors.New("PASSBOLT_GPG_FILE and PASSBOLT_PASSWORD_FILE must be set together"), "invalid passbolt credentials configuration")
+		os.Exit(1)
+	}
+	if credentialsFromFiles {
+		privateKey, password, err = passbolt.ReadCredentials(privateKeyFile, passwordFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read passbolt credentials")
+			os.Exit(1)
+		}
+	}
+
+	// create passbolt client, it logs in with the initial cache load or, if passbolt is unavailable, with the cache refresher
+	clnt, err := passbolt.NewLazyClient(os.Getenv("PASSBOLT_URL"), privateKey, password)
 	if err != nil {
 		setupLog.Error(err, "unable to create passbolt client")
 		os.Exit(1)
 	}
+	clnt.SetLimits(limits)
+	clnt.SetSecretCache(secretCache)
+	if credentialsFromFiles {
+		watcher := passbolt.NewCredentialsWatcher(clnt, privateKeyFile, passwordFile, credentialsWatchInterval, privateKey, password)
+		if err := mgr.Add(watcher); err != nil {
+			setupLog.Error(err, "unable to watch passbolt credentials")
+			os.Exit(1)
+		}
+	}
 
 	// initialize passbolt client cache hit functions
 	passboltv1alpha2.GetSecretID = clnt.GetSecretID
 	passboltv1alpha2.GetSecretName = clnt.GetSecretName
 
-	// initial cache load
-	err = clnt.LoadCache(ctx)
-	if err != nil {
-		setupLog.Error(err, "unable to load passbolt cache")
-		os.Exit(1)
-	}
-
-	// fill passbolt cache with existing secrets
-	// ticker is set to 5 minutes
-	ticker := time.NewTicker(5 * time.Minute)
-	done := make(chan bool)
-	defer func() {
-		ticker.Stop()
-		done <- true
-	}()
-	go func() {
-		for {
-			select {
-			case <-done:
-				// we exit here, because the ticker is stopped
-				return
-			// refresh cache every X ticks
-			case <-ticker.C:
-				cacheLog.Info("refeshing passbolt cache")
-				ctx, cf := context.WithTimeout(context.Background(), 5*time.Second)
-				defer cf()
-
-				lastErr := false
-				err := util.Retry(ctx, cacheLoadRetries, 10, func(ctx context.Context) error {
-					if lastErr {
-						cacheLog.Info("retrying to authenticate to passbolt")
-						// we already failed once, so we try to re-login
-						if err := clnt.ReLogin(ctx); err != nil {
-							return err
-						}
-					}
-					cacheLog.Info("querying secrets from passbolt...")
-					err := clnt.LoadCache(ctx)
-					if err != nil {
-						lastErr = true
-						return err
-					}
-					lastErr = false
-					return nil
-				})
-				if err != nil {
-					cacheLog.Error(err, "failed to refresh in-memory cache")
-					os.Exit(1)
-					// return is not needed here, because we exit the program, but we keep it for consistency
-					return
-				}
-			}
-		}
-	}()
+	// initial cache load, failures are retried by the cache refresher
+	if err := clnt.LoadCache(ctx); err != nil {
+		setupLog.Error(err, "unable to load passbolt cache, retrying in the background")
+	}
+
+	// refresh the passbolt cache periodically, the stale cache is served while passbolt is unavailable
+	refresher := passbolt.NewCacheRefresher(clnt, cacheRefreshInterval, cacheLog)
+	if err := mgr.Add(refresher); err != nil {
+		setupLog.Error(err, "unable to set up passbolt cache refresh")
+		os.Exit(1)
+	}
+	metrics.Registry.MustRegister(refresher.CacheAgeMetric(), refresher.DegradedMetric(cacheMaxStaleness))
+
+	// clients of the passbolt servers defined by PassboltServers and ClusterPassboltServers
+	servers := passbolt.NewPool(cacheRefreshInterval)
+	servers.SetLimits(limits)
+	servers.SetSecretCache(secretCache)
 
 	if err = (&controller.PassboltSecretReconciler{
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		PassboltClient: clnt,
+		Client:                 mgr.GetClient(),
+		Scheme:                 mgr.GetScheme(),
+		PassboltClient:         clnt,
+		Servers:                servers,
+		DefaultServerURL:       os.Getenv("PASSBOLT_URL"),
+		RequireCredentialsRef:  requireCredentialsRef,
+		DefaultRefreshInterval: defaultRefreshInterval,
+		Recorder:               controller.NewRateLimitedRecorder(mgr.GetEventRecorderFor("passboltsecret-controller"), eventInterval),
+		RolloutAnnotation:      rolloutAnnotation,
+		DataHashKey:            dataHashKey,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PassboltSecret")
 		os.Exit(1)
 	}
+	if err = (&controller.PassboltPushSecretReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		PassboltClient:        clnt,
+		Servers:               servers,
+		DefaultServerURL:      os.Getenv("PASSBOLT_URL"),
+		RequireCredentialsRef: requireCredentialsRef,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PassboltPushSecret")
+		os.Exit(1)
+	}
+	if err = (&controller.PassboltGeneratedSecretReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		PassboltClient:        clnt,
+		Servers:               servers,
+		DefaultServerURL:      os.Getenv("PASSBOLT_URL"),
+		RequireCredentialsRef: requireCredentialsRef,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PassboltGeneratedSecret")
+		os.Exit(1)
+	}
+	if err = (&controller.PassboltServerReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Servers: servers,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PassboltServer")
+		os.Exit(1)
+	}
+	if err = (&controller.ClusterPassboltServerReconciler{
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Servers: servers,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterPassboltServer")
+		os.Exit(1)
+	}
+	// log out of credentials that are no longer referenced
+	if err := mgr.Add(&controller.CredentialsPruner{Client: mgr.GetClient(), Servers: servers}); err != nil {
+		setupLog.Error(err, "unable to set up passbolt credentials prune")
+		os.Exit(1)
+	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = (&passboltv1.PassboltSecret{}).SetupWebhookWithManager(mgr); err != nil {
+		policies := &controller.PolicyChecker{Client: mgr.GetClient(), PassboltClient: clnt, Servers: servers}
+		if err = (&passboltv1.PassboltSecret{}).SetupWebhookWithManager(mgr, policies); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "PassboltSecret")
 			os.Exit(1)
 		}
+		if err = (&passboltv1.PassboltGeneratedSecret{}).SetupWebhookWithManager(mgr, policies); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PassboltGeneratedSecret")
+			os.Exit(1)
+		}
+		if err = (&passboltv1.PassboltPushSecret{}).SetupWebhookWithManager(mgr, policies); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "PassboltPushSecret")
+			os.Exit(1)
+		}
 	}
 	//+kubebuilder:scaffold:builder
 
@@ -230,6 +358,17 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	healthThresholds := passbolt.HealthThresholds{
+		MaxCallAge: livenessMaxCallAge,
+	}
+	if err := mgr.AddHealthzCheck("passbolt", clnt.LivenessChecker(healthThresholds)); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("passbolt", refresher.ReadyChecker()); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -0,0 +1,361 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+// passboltLookup resolves references to passbolt resources and folders from the cache of a passbolt client.
+type passboltLookup interface {
+	ResolveResourceID(name, folderPath string) (string, error)
+	ResolveFolderID(id, path string) (string, error)
+	ResourceFolder(id string) (string, bool)
+	FolderPath(id string) (string, bool)
+	FolderParent(id string) (string, bool)
+	ResourceTags(id string) []string
+}
+
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltaccesspolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// PolicyChecker checks the passbolt references of PassboltSecrets, PassboltGeneratedSecrets and PassboltPushSecrets
+// against the PassboltAccessPolicies of their namespace for the validating webhooks, see passboltv1.AccessPolicyChecker.
+// The references are resolved with the client of the server referenced by the object.
+// Since the object may be synced with other credentials, references that cannot be resolved are left to the reconcilers.
+type PolicyChecker struct {
+	// Client lists the access policies and namespaces.
+	Client client.Reader
+	// PassboltClient is the client of the passbolt server configured for the operator.
+	PassboltClient *passbolt.Client
+	// Servers contains the clients of the PassboltServers and ClusterPassboltServers.
+	Servers *passbolt.Pool
+}
+
+var _ passboltv1.AccessPolicyChecker = &PolicyChecker{}
+
+// CheckPassboltSecret implements passboltv1.AccessPolicyChecker.
+func (p *PolicyChecker) CheckPassboltSecret(ctx context.Context, secret *passboltv1.PassboltSecret) error {
+	lookup, policies, err := p.policies(ctx, secret.Namespace, secret.Spec.ServerRef)
+	if err != nil || lookup == nil {
+		return err
+	}
+	return checkAccessPolicies(lookup, policies, secret.Spec, true)
+}
+
+// CheckPassboltGeneratedSecret implements passboltv1.AccessPolicyChecker.
+func (p *PolicyChecker) CheckPassboltGeneratedSecret(ctx context.Context, genSecret *passboltv1.PassboltGeneratedSecret) error {
+	lookup, policies, err := p.policies(ctx, genSecret.Namespace, genSecret.Spec.ServerRef)
+	if err != nil || lookup == nil {
+		return err
+	}
+	return checkWriteTarget(lookup, policies, genSecret.Status.ResourceID, genSecret.Spec.Resource.FolderID, true)
+}
+
+// CheckPassboltPushSecret implements passboltv1.AccessPolicyChecker.
+func (p *PolicyChecker) CheckPassboltPushSecret(ctx context.Context, pushSecret *passboltv1.PassboltPushSecret) error {
+	lookup, policies, err := p.policies(ctx, pushSecret.Namespace, pushSecret.Spec.ServerRef)
+	if err != nil || lookup == nil {
+		return err
+	}
+	return checkWriteTarget(lookup, policies, pushSecret.Status.ResourceID, pushSecret.Spec.Resource.FolderID, true)
+}
+
+// policies returns the access policies of the namespace and the lookup of the referenced server.
+// The lookup is nil if the server is not ready yet, the reconcilers check the references once it is.
+func (p *PolicyChecker) policies(ctx context.Context, namespace string, serverRef *passboltv1.PassboltServerRef) (passboltLookup, []passboltv1.PassboltAccessPolicySpec, error) {
+	policies, err := accessPolicies(ctx, p.Client, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+	if serverRef == nil {
+		return p.PassboltClient, policies, nil
+	}
+	if p.Servers == nil {
+		return nil, nil, nil
+	}
+	clnt, ok := p.Servers.Get(serverKey(*serverRef, namespace))
+	if !ok {
+		return nil, nil, nil
+	}
+	return clnt, policies, nil
+}
+
+// findObjectsForAccessPolicy returns all objects of the given list type, since a changed access policy may apply to any namespace.
+func findObjectsForAccessPolicy(c client.Client, list client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		objs := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(ctx, objs); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list objects for access policy", "name", obj.GetName())
+			return nil
+		}
+		items, err := apimeta.ExtractList(objs)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			if o, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+			}
+		}
+		return requests
+	}
+}
+
+// accessPolicies returns the specs of all PassboltAccessPolicies that apply to the given namespace.
+func accessPolicies(ctx context.Context, c client.Reader, namespace string) ([]passboltv1.PassboltAccessPolicySpec, error) {
+	policies := &passboltv1.PassboltAccessPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return nil, fmt.Errorf("failed to list access policies: %w", err)
+	}
+	if len(policies.Items) == 0 {
+		return nil, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	specs := []passboltv1.PassboltAccessPolicySpec{}
+	for _, policy := range policies.Items {
+		ok, err := policyAppliesTo(policy.Spec, ns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace selector of access policy %s: %w", policy.Name, err)
+		}
+		if ok {
+			specs = append(specs, policy.Spec)
+		}
+	}
+	return specs, nil
+}
+
+// policyAppliesTo returns true if the access policy selects the given namespace by its name or labels.
+func policyAppliesTo(spec passboltv1.PassboltAccessPolicySpec, ns *corev1.Namespace) (bool, error) {
+	if slices.Contains(spec.Namespaces, ns.Name) {
+		return true, nil
+	}
+	if spec.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// checkAccessPolicies returns an error wrapping passboltv1.ErrReferenceNotAllowed if the spec references passbolt resources,
+// folders or tags that are not allowed by any of the given access policies. If no policy is given, all references are allowed.
+// References that cannot be resolved are not allowed, unless skipUnresolved is set.
+func checkAccessPolicies(lookup passboltLookup, policies []passboltv1.PassboltAccessPolicySpec, spec passboltv1.PassboltSecretSpec, skipUnresolved bool) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	rules := accessRules{lookup: lookup, policies: policies}
+
+	if spec.PassboltSecretID != nil {
+		if err := rules.checkResource(*spec.PassboltSecretID, skipUnresolved); err != nil {
+			return err
+		}
+	}
+	// sort the keys to always report the same reference
+	keys := make([]string, 0, len(spec.PassboltSecrets))
+	for key := range spec.PassboltSecrets {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		ref := spec.PassboltSecrets[key]
+		id := ref.ID
+		if id == "" {
+			var err error
+			id, err = lookup.ResolveResourceID(ref.Name, ref.FolderPath)
+			if err != nil {
+				if skipUnresolved {
+					continue
+				}
+				return fmt.Errorf("%w: passbolt resource %q of key %q: %w", passboltv1.ErrReferenceNotAllowed, ref.Selector(), key, err)
+			}
+		}
+		if err := rules.checkResource(id, skipUnresolved); err != nil {
+			return fmt.Errorf("%w of key %q", err, key)
+		}
+	}
+	if folder := spec.PassboltFolder; folder != nil {
+		id, err := lookup.ResolveFolderID(folder.ID, folder.Path)
+		if err != nil {
+			if !skipUnresolved {
+				return fmt.Errorf("%w: passbolt folder %q: %w", passboltv1.ErrReferenceNotAllowed, folder.Selector(), err)
+			}
+		} else if !rules.folderAllowed(id) {
+			return fmt.Errorf("%w: passbolt folder %q", passboltv1.ErrReferenceNotAllowed, folder.Selector())
+		}
+	}
+	if selector := spec.TagSelector; selector != nil && !rules.tagsAllowed(selector.Tags) {
+		return fmt.Errorf("%w: tag selector %q", passboltv1.ErrReferenceNotAllowed, strings.Join(selector.Tags, ","))
+	}
+	return nil
+}
+
+// checkWriteTarget returns an error wrapping passboltv1.ErrReferenceNotAllowed if a PassboltGeneratedSecret or
+// PassboltPushSecret writes to a passbolt resource or folder that is not allowed by any of the given access policies.
+// Once the resource exists, the resource is checked, otherwise the folder the resource is created in.
+// Resources in the root folder can only be allowed by their ID, so they cannot be created if any policy applies.
+func checkWriteTarget(lookup passboltLookup, policies []passboltv1.PassboltAccessPolicySpec, resourceID, folderID string, skipUnresolved bool) error {
+	if len(policies) == 0 {
+		return nil
+	}
+	rules := accessRules{lookup: lookup, policies: policies}
+	if resourceID != "" {
+		return rules.checkResource(resourceID, skipUnresolved)
+	}
+	if folderID == "" {
+		return fmt.Errorf("%w: resources in the root folder", passboltv1.ErrReferenceNotAllowed)
+	}
+	if rules.folderAllowed(folderID) {
+		return nil
+	}
+	if _, ok := lookup.FolderPath(folderID); !ok && skipUnresolved {
+		return nil
+	}
+	return fmt.Errorf("%w: passbolt folder %q", passboltv1.ErrReferenceNotAllowed, folderID)
+}
+
+// revokeSecret deletes the Kubernetes secret of the given owner, so that data synced before an access policy denied
+// the references of the owner is no longer readable. Secrets that are not controlled by the owner are kept.
+// It returns whether the secret was deleted.
+func revokeSecret(ctx context.Context, c client.Client, owner client.Object) (bool, error) {
+	k8sSecret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(owner), k8sSecret); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(k8sSecret, owner) {
+		return false, nil
+	}
+	if err := c.Delete(ctx, k8sSecret); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// accessRules are the combined rules of all access policies that apply to a namespace.
+type accessRules struct {
+	lookup   passboltLookup
+	policies []passboltv1.PassboltAccessPolicySpec
+}
+
+// checkResource returns an error if the passbolt resource with the given ID is not allowed.
+func (a accessRules) checkResource(id string, skipUnresolved bool) error {
+	if a.resourceAllowed(id) {
+		return nil
+	}
+	if _, ok := a.lookup.ResourceFolder(id); !ok && skipUnresolved {
+		return nil
+	}
+	return fmt.Errorf("%w: passbolt resource %q", passboltv1.ErrReferenceNotAllowed, id)
+}
+
+// resourceAllowed returns true if the resource is allowed by its ID, one of its tags or its folder.
+func (a accessRules) resourceAllowed(id string) bool {
+	tags := a.lookup.ResourceTags(id)
+	for _, policy := range a.policies {
+		if slices.Contains(policy.ResourceIDs, id) {
+			return true
+		}
+		for _, tag := range tags {
+			if slices.Contains(policy.Tags, tag) {
+				return true
+			}
+		}
+	}
+	folderID, ok := a.lookup.ResourceFolder(id)
+	return ok && folderID != "" && a.folderAllowed(folderID)
+}
+
+// folderAllowed returns true if the folder with the given ID or one of its parent folders is allowed.
+// Allowed folder IDs are compared with the IDs of the folder and its parents, since folder names are not unique
+// and another folder with the same path must not be allowed. Only allowed folder paths are matched by path.
+func (a accessRules) folderAllowed(id string) bool {
+	path, ok := a.lookup.FolderPath(id)
+	if !ok {
+		return false
+	}
+	ids := a.folderAndParents(id)
+	for _, policy := range a.policies {
+		for _, allowedID := range policy.FolderIDs {
+			if slices.Contains(ids, allowedID) {
+				return true
+			}
+		}
+		for _, allowedPath := range policy.FolderPaths {
+			if isSubPath(path, strings.Trim(allowedPath, "/")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// folderAndParents returns the IDs of the folder with the given ID and of all its parent folders.
+// Folders that are not accessible end the chain.
+func (a accessRules) folderAndParents(id string) []string {
+	ids := []string{}
+	// stop at cycles, which passbolt does not allow anyway
+	for id != "" && !slices.Contains(ids, id) {
+		parent, ok := a.lookup.FolderParent(id)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+		id = parent
+	}
+	return ids
+}
+
+// tagsAllowed returns true if at least one of the given tags is allowed.
+// Since a tag selector only selects resources carrying all of its tags, all selected resources carry the allowed tag.
+func (a accessRules) tagsAllowed(tags []string) bool {
+	for _, policy := range a.policies {
+		for _, tag := range tags {
+			if slices.Contains(policy.Tags, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSubPath returns true if path is the folder path parent or one of its sub folders.
+func isSubPath(path, parent string) bool {
+	return parent != "" && (path == parent || strings.HasPrefix(path, parent+"/"))
+}
@@ -0,0 +1,482 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+// fakeLookup resolves references from static folders and resources.
+type fakeLookup struct {
+	// folders maps folder IDs to folder paths
+	folders map[string]string
+	// parents maps folder IDs to the ID of their parent folder
+	parents map[string]string
+	// resources maps resource IDs to their folder ID
+	resources map[string]string
+	// names maps resource names to resource IDs
+	names map[string]string
+	// tags maps resource IDs to their tags
+	tags map[string][]string
+}
+
+func (f fakeLookup) ResolveResourceID(name, folderPath string) (string, error) {
+	if id, ok := f.names[name]; ok {
+		return id, nil
+	}
+	return "", passbolt.ErrResourceNotFound
+}
+
+func (f fakeLookup) ResolveFolderID(id, path string) (string, error) {
+	for folderID, folderPath := range f.folders {
+		if folderID == id || (id == "" && folderPath == path) {
+			return folderID, nil
+		}
+	}
+	return "", passbolt.ErrFolderNotFound
+}
+
+func (f fakeLookup) ResourceFolder(id string) (string, bool) {
+	folderID, ok := f.resources[id]
+	return folderID, ok
+}
+
+func (f fakeLookup) FolderPath(id string) (string, bool) {
+	path, ok := f.folders[id]
+	return path, ok
+}
+
+func (f fakeLookup) FolderParent(id string) (string, bool) {
+	if _, ok := f.folders[id]; !ok {
+		return "", false
+	}
+	return f.parents[id], true
+}
+
+func (f fakeLookup) ResourceTags(id string) []string {
+	return f.tags[id]
+}
+
+func TestCheckAccessPolicies(t *testing.T) {
+	lookup := fakeLookup{
+		folders: map[string]string{
+			"apps":     "apps",
+			"payments": "apps/payments",
+			"db":       "apps/payments/db",
+			"orders":   "apps/orders",
+			// folder names are not unique, so another folder may have the same path
+			"other-apps":   "apps",
+			"other-orders": "apps/orders",
+		},
+		parents: map[string]string{
+			"payments":     "apps",
+			"db":           "payments",
+			"orders":       "apps",
+			"other-orders": "other-apps",
+		},
+		resources: map[string]string{
+			"stripe":      "payments",
+			"postgres":    "db",
+			"redis":       "orders",
+			"s3":          "",
+			"other-redis": "other-orders",
+		},
+		names: map[string]string{
+			"postgres": "postgres",
+			"redis":    "redis",
+		},
+		tags: map[string][]string{
+			"redis": {"k8s:payments"},
+		},
+	}
+	s3 := "s3"
+	payments := []passboltv1.PassboltAccessPolicySpec{{FolderPaths: []string{"/apps/payments/"}}}
+
+	tests := []struct {
+		name           string
+		policies       []passboltv1.PassboltAccessPolicySpec
+		spec           passboltv1.PassboltSecretSpec
+		skipUnresolved bool
+		wantErr        bool
+	}{
+		{
+			name: "no policy",
+			spec: passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "redis"}}},
+		},
+		{
+			name:     "resource in allowed folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "stripe"}}},
+		},
+		{
+			name:     "resource in sub folder of allowed folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {Name: "postgres"}}},
+		},
+		{
+			name:     "resource outside of allowed folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "stripe"}, "b": {Name: "redis"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "resource in root folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "s3"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "resource allowed by folder ID",
+			policies: []passboltv1.PassboltAccessPolicySpec{{FolderIDs: []string{"apps"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "redis"}}},
+		},
+		{
+			name:     "resource in sub folder allowed by folder ID",
+			policies: []passboltv1.PassboltAccessPolicySpec{{FolderIDs: []string{"apps"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "postgres"}}},
+		},
+		{
+			name:     "resource in folder with the path of the allowed folder ID",
+			policies: []passboltv1.PassboltAccessPolicySpec{{FolderIDs: []string{"apps"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "other-redis"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "folder with the path of the allowed folder ID",
+			policies: []passboltv1.PassboltAccessPolicySpec{{FolderIDs: []string{"orders"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltFolder: &passboltv1.PassboltFolderRef{ID: "other-orders"}},
+			wantErr:  true,
+		},
+		{
+			name:     "resource allowed by ID",
+			policies: []passboltv1.PassboltAccessPolicySpec{{ResourceIDs: []string{"s3"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecretID: &s3},
+		},
+		{
+			name:     "resource allowed by tag of another policy",
+			policies: append([]passboltv1.PassboltAccessPolicySpec{{Tags: []string{"k8s:payments"}}}, payments...),
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "stripe"}, "b": {Name: "redis"}}},
+		},
+		{
+			name:     "folder with similar name",
+			policies: []passboltv1.PassboltAccessPolicySpec{{FolderPaths: []string{"apps/pay"}}},
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {ID: "stripe"}}},
+			wantErr:  true,
+		},
+		{
+			name:     "unresolved resource",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {Name: "mysql"}, "b": {ID: "unknown"}}},
+			wantErr:  true,
+		},
+		{
+			name:           "skip unresolved resource",
+			policies:       payments,
+			spec:           passboltv1.PassboltSecretSpec{PassboltSecrets: map[string]passboltv1.PassboltSecretRef{"a": {Name: "mysql"}, "b": {ID: "unknown"}}},
+			skipUnresolved: true,
+		},
+		{
+			name:     "allowed folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltFolder: &passboltv1.PassboltFolderRef{Path: "apps/payments/db"}},
+		},
+		{
+			name:     "parent of allowed folder",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{PassboltFolder: &passboltv1.PassboltFolderRef{ID: "apps"}},
+			wantErr:  true,
+		},
+		{
+			name:     "allowed tag",
+			policies: []passboltv1.PassboltAccessPolicySpec{{Tags: []string{"k8s:payments"}}},
+			spec:     passboltv1.PassboltSecretSpec{TagSelector: &passboltv1.PassboltTagSelector{Tags: []string{"prod", "k8s:payments"}}},
+		},
+		{
+			name:     "tag not allowed",
+			policies: payments,
+			spec:     passboltv1.PassboltSecretSpec{TagSelector: &passboltv1.PassboltTagSelector{Tags: []string{"k8s:payments"}}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAccessPolicies(lookup, tt.policies, tt.spec, tt.skipUnresolved)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkAccessPolicies() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !errors.Is(err, passboltv1.ErrReferenceNotAllowed) {
+				t.Errorf("checkAccessPolicies() error = %v, want %v", err, passboltv1.ErrReferenceNotAllowed)
+			}
+		})
+	}
+}
+
+func TestPolicyAppliesTo(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "payments"}}}
+	tests := []struct {
+		name    string
+		spec    passboltv1.PassboltAccessPolicySpec
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "namespace name",
+			spec: passboltv1.PassboltAccessPolicySpec{Namespaces: []string{"team-b", "team-a"}},
+			want: true,
+		},
+		{
+			name: "matching namespace selector",
+			spec: passboltv1.PassboltAccessPolicySpec{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "payments"}}},
+			want: true,
+		},
+		{
+			name: "other namespace selector",
+			spec: passboltv1.PassboltAccessPolicySpec{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "orders"}}},
+		},
+		{
+			name: "empty namespace selector",
+			spec: passboltv1.PassboltAccessPolicySpec{NamespaceSelector: &metav1.LabelSelector{}},
+			want: true,
+		},
+		{
+			name: "no namespaces",
+			spec: passboltv1.PassboltAccessPolicySpec{},
+		},
+		{
+			name: "invalid namespace selector",
+			spec: passboltv1.PassboltAccessPolicySpec{NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "team", Operator: "Unknown"},
+			}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policyAppliesTo(tt.spec, ns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("policyAppliesTo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("policyAppliesTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWriteTarget(t *testing.T) {
+	lookup := fakeLookup{
+		folders: map[string]string{
+			"payments": "apps/payments",
+			"orders":   "apps/orders",
+		},
+		resources: map[string]string{
+			"stripe": "payments",
+			"redis":  "orders",
+		},
+	}
+	payments := []passboltv1.PassboltAccessPolicySpec{{FolderPaths: []string{"apps/payments"}}}
+
+	tests := []struct {
+		name           string
+		policies       []passboltv1.PassboltAccessPolicySpec
+		resourceID     string
+		folderID       string
+		skipUnresolved bool
+		wantErr        bool
+	}{
+		{
+			name:     "no policy",
+			folderID: "orders",
+		},
+		{
+			name:     "allowed folder",
+			policies: payments,
+			folderID: "payments",
+		},
+		{
+			name:     "folder not allowed",
+			policies: payments,
+			folderID: "orders",
+			wantErr:  true,
+		},
+		{
+			name:     "root folder",
+			policies: payments,
+			wantErr:  true,
+		},
+		{
+			name:           "unknown folder is left to the reconciler",
+			policies:       payments,
+			folderID:       "unknown",
+			skipUnresolved: true,
+		},
+		{
+			name:     "unknown folder",
+			policies: payments,
+			folderID: "unknown",
+			wantErr:  true,
+		},
+		{
+			name:       "existing resource is checked instead of the folder",
+			policies:   payments,
+			resourceID: "redis",
+			folderID:   "payments",
+			wantErr:    true,
+		},
+		{
+			name:       "allowed existing resource",
+			policies:   payments,
+			resourceID: "stripe",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWriteTarget(lookup, tt.policies, tt.resourceID, tt.folderID, tt.skipUnresolved)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkWriteTarget() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !errors.Is(err, passboltv1.ErrReferenceNotAllowed) {
+				t.Errorf("checkWriteTarget() error = %v, want %v", err, passboltv1.ErrReferenceNotAllowed)
+			}
+		})
+	}
+}
+
+// ownedSecret returns a Kubernetes secret with synced data that is controlled by the given owner.
+func ownedSecret(owner client.Object, kind string) *corev1.Secret {
+	controller := true
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      owner.GetName(),
+			Namespace: owner.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: passboltv1.GroupVersion.String(),
+				Kind:       kind,
+				Name:       owner.GetName(),
+				UID:        owner.GetUID(),
+				Controller: &controller,
+			}},
+		},
+		Data: map[string][]byte{"password": []byte("secret")},
+	}
+}
+
+func TestAccessDeniedRevokesSecret(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	policy := &passboltv1.PassboltAccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+		Spec: passboltv1.PassboltAccessPolicySpec{
+			Namespaces:  []string{"team-a"},
+			ResourceIDs: []string{"allowed"},
+		},
+	}
+	secret := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a", UID: "secret-uid"},
+		Spec: passboltv1.PassboltSecretSpec{
+			SecretType: corev1.SecretTypeOpaque,
+			PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+				"password": {ID: "denied", Field: passboltv1.FieldNamePassword},
+			},
+		},
+	}
+	genSecret := &passboltv1.PassboltGeneratedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "generated", Namespace: "team-a", UID: "generated-uid"},
+		Spec: passboltv1.PassboltGeneratedSecretSpec{
+			Resource: passboltv1.PassboltGeneratedResource{Name: "generated"},
+		},
+		Status: passboltv1.PassboltGeneratedSecretStatus{ResourceID: "denied"},
+	}
+	// a secret with the same name that is not controlled by the PassboltSecret must be kept
+	foreign := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "team-a"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	foreignSecret := secret.DeepCopy()
+	foreignSecret.Name = "foreign"
+	foreignSecret.UID = "foreign-uid"
+
+	c := fake.NewClientBuilder().
+		WithScheme(newTestScheme(t)).
+		WithObjects(namespace, policy, secret, genSecret, foreignSecret, foreign,
+			ownedSecret(secret, "PassboltSecret"), ownedSecret(genSecret, "PassboltGeneratedSecret")).
+		WithStatusSubresource(secret, genSecret, foreignSecret).
+		Build()
+	pbClient := &passbolt.Client{}
+	secretReconciler := &PassboltSecretReconciler{Client: c, Scheme: c.Scheme(), PassboltClient: pbClient}
+	genReconciler := &PassboltGeneratedSecretReconciler{Client: c, Scheme: c.Scheme(), PassboltClient: pbClient}
+
+	tests := []struct {
+		name        string
+		reconcile   func(ctx context.Context, req ctrl.Request) (ctrl.Result, error)
+		key         types.NamespacedName
+		wantDeleted bool
+	}{
+		{
+			name:        "passbolt secret",
+			reconcile:   secretReconciler.Reconcile,
+			key:         types.NamespacedName{Namespace: "team-a", Name: "app"},
+			wantDeleted: true,
+		},
+		{
+			name:        "generated secret",
+			reconcile:   genReconciler.Reconcile,
+			key:         types.NamespacedName{Namespace: "team-a", Name: "generated"},
+			wantDeleted: true,
+		},
+		{
+			name:      "secret not controlled by the passbolt secret",
+			reconcile: secretReconciler.Reconcile,
+			key:       types.NamespacedName{Namespace: "team-a", Name: "foreign"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _ = tt.reconcile(context.Background(), ctrl.Request{NamespacedName: tt.key})
+			err := c.Get(context.Background(), tt.key, &corev1.Secret{})
+			if deleted := apierrors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("secret deleted = %v (error %v), want %v", deleted, err, tt.wantDeleted)
+			}
+		})
+	}
+
+	// the PassboltSecret reports the denied access
+	got := &passboltv1.PassboltSecret{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(secret), got); err != nil {
+		t.Fatalf("failed to get passbolt secret: %v", err)
+	}
+	synced := apimeta.FindStatusCondition(got.Status.Conditions, passboltv1.ConditionTypeSynced)
+	if synced == nil || synced.Reason != passboltv1.ReasonAccessDenied {
+		t.Errorf("Synced condition = %v, want reason %s", synced, passboltv1.ReasonAccessDenied)
+	}
+}
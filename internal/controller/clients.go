@@ -0,0 +1,206 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+const (
+	// defaultCredentialsPruneInterval is the default interval in which unreferenced credentials are removed from the pool.
+	defaultCredentialsPruneInterval = 5 * time.Minute
+)
+
+// clientResolver resolves the passbolt client of an object from its server and credentials references.
+// It is used by the reconcilers of all kinds accessing passbolt, so that they apply the same tenant isolation.
+type clientResolver struct {
+	client client.Client
+	// defaultClient is the client of the passbolt server configured for the operator.
+	defaultClient *passbolt.Client
+	// servers contains the clients of the passbolt servers and of the referenced credentials.
+	servers *passbolt.Pool
+	// defaultServerURL is the URL of the passbolt server of defaultClient.
+	defaultServerURL string
+	// requireCredentialsRef rejects objects that do not reference their own credentials.
+	requireCredentialsRef bool
+}
+
+// get returns the client of the passbolt server referenced by an object in the given namespace.
+// If the object references credentials, the client of the server logged in with these credentials is returned.
+func (r clientResolver) get(ctx context.Context, namespace string, serverRef *passboltv1.PassboltServerRef, credentialsRef *passboltv1.PassboltCredentialsRef) (*passbolt.Client, error) {
+	if credentialsRef == nil {
+		if r.requireCredentialsRef {
+			return nil, fmt.Errorf("a credentials reference is required to access passbolt")
+		}
+		return r.serverClient(namespace, serverRef)
+	}
+	if r.servers == nil {
+		return nil, fmt.Errorf("credentials references are not supported")
+	}
+
+	url := r.defaultServerURL
+	if serverRef != nil {
+		key := serverKey(*serverRef, namespace)
+		serverConfig, ok := r.servers.Config(key)
+		if !ok {
+			return nil, fmt.Errorf("passbolt server %s is not ready", key)
+		}
+		url = serverConfig.URL
+	}
+	// the credentials are always read from the namespace of the object,
+	// so that a tenant can only log in with the credentials of its own namespace.
+	config, err := credentialsConfig(ctx, r.client, url, namespace, *credentialsRef)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure logs in again if the credentials changed.
+	// The clients of the credentials are removed from the pool by the CredentialsPruner once they are no longer referenced.
+	return r.servers.Ensure(ctx, credentialsKey(namespace, serverRef, *credentialsRef), config)
+}
+
+// serverClient returns the client of the referenced passbolt server using the credentials of the server.
+func (r clientResolver) serverClient(namespace string, serverRef *passboltv1.PassboltServerRef) (*passbolt.Client, error) {
+	if serverRef == nil {
+		return r.defaultClient, nil
+	}
+	key := serverKey(*serverRef, namespace)
+	if r.servers == nil {
+		return nil, fmt.Errorf("passbolt server %s is not supported", key)
+	}
+	clnt, ok := r.servers.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("passbolt server %s is not ready", key)
+	}
+	return clnt, nil
+}
+
+// credentialsKey returns the key of the pool entry that is logged in with the credentials referenced by an object in the given namespace.
+func credentialsKey(namespace string, serverRef *passboltv1.PassboltServerRef, credentialsRef passboltv1.PassboltCredentialsRef) passbolt.ServerKey {
+	key := passbolt.ServerKey{}
+	if serverRef != nil {
+		key = serverKey(*serverRef, namespace)
+	}
+	key.Namespace = namespace
+	key.Credentials = credentialsRef.Name
+	return key
+}
+
+// CredentialsPruner periodically removes the clients of credentials from the pool that are no longer referenced by any
+// PassboltSecret, PassboltGeneratedSecret or PassboltPushSecret, so that their sessions and cache refreshes do not leak.
+type CredentialsPruner struct {
+	Client client.Client
+	// Servers is the pool the clients of the credentials are removed from.
+	Servers credentialsPool
+	// Interval is the interval of the prunes. If not set, the pool is pruned every 5 minutes.
+	Interval time.Duration
+}
+
+// credentialsPool is the part of passbolt.Pool used by the CredentialsPruner.
+type credentialsPool interface {
+	Keys() []passbolt.ServerKey
+	Remove(ctx context.Context, key passbolt.ServerKey)
+}
+
+var _ credentialsPool = &passbolt.Pool{}
+var _ manager.Runnable = &CredentialsPruner{}
+var _ manager.LeaderElectionRunnable = &CredentialsPruner{}
+
+// Start prunes the pool until the context is canceled. It never fails.
+func (p *CredentialsPruner) Start(ctx context.Context) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = defaultCredentialsPruneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.prune(ctx); err != nil {
+				log.FromContext(ctx).Error(err, "failed to prune passbolt credentials")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// Every replica has its own pool, since the webhooks use it as well.
+func (p *CredentialsPruner) NeedLeaderElection() bool {
+	return false
+}
+
+// prune removes the clients of all unreferenced credentials from the pool.
+func (p *CredentialsPruner) prune(ctx context.Context) error {
+	// the keys are collected before the references, so that credentials that are logged in meanwhile are kept
+	keys := p.Servers.Keys()
+	inUse, err := credentialsInUse(ctx, p.Client)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if key.Credentials == "" || inUse[key] {
+			continue
+		}
+		log.FromContext(ctx).Info("removing unreferenced passbolt credentials", "server", key.String())
+		p.Servers.Remove(ctx, key)
+	}
+	return nil
+}
+
+// credentialsInUse returns the pool keys of all credentials referenced by PassboltSecrets, PassboltGeneratedSecrets and PassboltPushSecrets.
+func credentialsInUse(ctx context.Context, c client.Client) (map[passbolt.ServerKey]bool, error) {
+	inUse := map[passbolt.ServerKey]bool{}
+	add := func(namespace string, serverRef *passboltv1.PassboltServerRef, credentialsRef *passboltv1.PassboltCredentialsRef) {
+		if credentialsRef != nil {
+			inUse[credentialsKey(namespace, serverRef, *credentialsRef)] = true
+		}
+	}
+
+	secrets := &passboltv1.PassboltSecretList{}
+	if err := c.List(ctx, secrets); err != nil {
+		return nil, err
+	}
+	for _, item := range secrets.Items {
+		add(item.Namespace, item.Spec.ServerRef, item.Spec.CredentialsRef)
+	}
+	genSecrets := &passboltv1.PassboltGeneratedSecretList{}
+	if err := c.List(ctx, genSecrets); err != nil {
+		return nil, err
+	}
+	for _, item := range genSecrets.Items {
+		add(item.Namespace, item.Spec.ServerRef, item.Spec.CredentialsRef)
+	}
+	pushSecrets := &passboltv1.PassboltPushSecretList{}
+	if err := c.List(ctx, pushSecrets); err != nil {
+		return nil, err
+	}
+	for _, item := range pushSecrets.Items {
+		add(item.Namespace, item.Spec.ServerRef, item.Spec.CredentialsRef)
+	}
+	return inUse, nil
+}
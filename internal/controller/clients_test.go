@@ -0,0 +1,188 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+// newTestScheme returns a scheme with the Kubernetes and passbolt types.
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add Kubernetes types to scheme: %v", err)
+	}
+	if err := passboltv1.AddToScheme(s); err != nil {
+		t.Fatalf("failed to add passbolt types to scheme: %v", err)
+	}
+	return s
+}
+
+func TestRequireCredentialsRef(t *testing.T) {
+	genSecret := &passboltv1.PassboltGeneratedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		Spec: passboltv1.PassboltGeneratedSecretSpec{
+			Resource: passboltv1.PassboltGeneratedResource{Name: "app"},
+		},
+	}
+	pushSecret := &passboltv1.PassboltPushSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		Spec: passboltv1.PassboltPushSecretSpec{
+			SecretName: "app",
+			Resource: passboltv1.PassboltPushResource{
+				Name:     "app",
+				Password: passboltv1.PassboltPushValue{Value: "secret"},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(newTestScheme(t)).
+		WithObjects(genSecret, pushSecret).
+		WithStatusSubresource(genSecret, pushSecret).
+		Build()
+	// the client of the operator must never be used, so it is not set
+	reconcilers := map[string]struct {
+		reconciler interface {
+			Reconcile(context.Context, ctrl.Request) (ctrl.Result, error)
+		}
+		obj client.Object
+	}{
+		"generated secret": {
+			reconciler: &PassboltGeneratedSecretReconciler{Client: c, Servers: passbolt.NewPool(0), RequireCredentialsRef: true},
+			obj:        &passboltv1.PassboltGeneratedSecret{},
+		},
+		"push secret": {
+			reconciler: &PassboltPushSecretReconciler{Client: c, Servers: passbolt.NewPool(0), RequireCredentialsRef: true},
+			obj:        &passboltv1.PassboltPushSecret{},
+		},
+	}
+	for name, tt := range reconcilers {
+		t.Run(name, func(t *testing.T) {
+			key := types.NamespacedName{Namespace: "team-a", Name: "app"}
+			if _, err := tt.reconciler.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err == nil {
+				t.Fatalf("Reconcile() error = nil, want error")
+			}
+			if err := c.Get(context.Background(), key, tt.obj); err != nil {
+				t.Fatalf("failed to get object: %v", err)
+			}
+			var status passboltv1.SyncStatus
+			var resourceID string
+			switch obj := tt.obj.(type) {
+			case *passboltv1.PassboltGeneratedSecret:
+				status, resourceID = obj.Status.SyncStatus, obj.Status.ResourceID
+			case *passboltv1.PassboltPushSecret:
+				status, resourceID = obj.Status.SyncStatus, obj.Status.ResourceID
+			}
+			if status != passboltv1.SyncStatusError {
+				t.Errorf("status.syncStatus = %q, want %q", status, passboltv1.SyncStatusError)
+			}
+			if resourceID != "" {
+				t.Errorf("status.resourceID = %q, want no resource", resourceID)
+			}
+		})
+	}
+}
+
+func TestCredentialsKey(t *testing.T) {
+	ref := passboltv1.PassboltCredentialsRef{Name: "passbolt"}
+	got := credentialsKey("team-a", &passboltv1.PassboltServerRef{Kind: passboltv1.PassboltServerKind, Name: "staging"}, ref)
+	want := passbolt.ServerKey{Kind: passboltv1.PassboltServerKind, Namespace: "team-a", Name: "staging", Credentials: "passbolt"}
+	if got != want {
+		t.Errorf("credentialsKey() = %v, want %v", got, want)
+	}
+	got = credentialsKey("team-a", nil, ref)
+	want = passbolt.ServerKey{Namespace: "team-a", Credentials: "passbolt"}
+	if got != want {
+		t.Errorf("credentialsKey() = %v, want %v", got, want)
+	}
+}
+
+// fakePool records the servers removed from it.
+type fakePool struct {
+	keys    []passbolt.ServerKey
+	removed []passbolt.ServerKey
+}
+
+func (p *fakePool) Keys() []passbolt.ServerKey {
+	return p.keys
+}
+
+func (p *fakePool) Remove(_ context.Context, key passbolt.ServerKey) {
+	p.removed = append(p.removed, key)
+}
+
+func TestCredentialsPruner_prune(t *testing.T) {
+	ref := &passboltv1.PassboltCredentialsRef{Name: "passbolt"}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(
+		&passboltv1.PassboltSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec:       passboltv1.PassboltSecretSpec{CredentialsRef: ref},
+		},
+		&passboltv1.PassboltGeneratedSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-b"},
+			Spec:       passboltv1.PassboltGeneratedSecretSpec{CredentialsRef: ref},
+		},
+		&passboltv1.PassboltPushSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-c"},
+			Spec: passboltv1.PassboltPushSecretSpec{
+				ServerRef:      &passboltv1.PassboltServerRef{Name: "prod"},
+				CredentialsRef: ref,
+			},
+		},
+	).Build()
+	pool := &fakePool{keys: []passbolt.ServerKey{
+		// servers are managed by their own reconcilers
+		{Kind: passboltv1.ClusterPassboltServerKind, Name: "prod"},
+		{Namespace: "team-a", Credentials: "passbolt"},
+		{Namespace: "team-b", Credentials: "passbolt"},
+		{Kind: passboltv1.ClusterPassboltServerKind, Namespace: "team-c", Name: "prod", Credentials: "passbolt"},
+		// the PassboltSecret was deleted
+		{Namespace: "team-d", Credentials: "passbolt"},
+		// the credentials reference was changed to another secret
+		{Namespace: "team-a", Credentials: "rotated"},
+		// the server reference was removed
+		{Kind: passboltv1.ClusterPassboltServerKind, Namespace: "team-a", Name: "prod", Credentials: "passbolt"},
+	}}
+
+	pruner := &CredentialsPruner{Client: c, Servers: pool}
+	if err := pruner.prune(context.Background()); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	want := []passbolt.ServerKey{
+		{Namespace: "team-d", Credentials: "passbolt"},
+		{Namespace: "team-a", Credentials: "rotated"},
+		{Kind: passboltv1.ClusterPassboltServerKind, Namespace: "team-a", Name: "prod", Credentials: "passbolt"},
+	}
+	if diff := cmp.Diff(want, pool.removed, cmpopts.SortSlices(func(a, b passbolt.ServerKey) bool { return a.String() < b.String() })); diff != "" {
+		t.Errorf("prune() removed mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// dataHashKeySecretKey is the key of the HMAC key in the Secret of the data hash key.
+	dataHashKeySecretKey = "key"
+	// dataHashKeyLength is the length of a generated HMAC key in bytes.
+	dataHashKeyLength = 32
+)
+
+// EnsureDataHashKey returns the key of the HMAC of the data hashes of the PassboltSecrets stored in the given Secret.
+// If the Secret does not exist, it is created with a random key, so that all replicas and restarts of the operator
+// use the same key and the hashes do not change.
+func EnsureDataHashKey(ctx context.Context, c client.Client, name types.NamespacedName) ([]byte, error) {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, name, secret)
+	if apierrors.IsNotFound(err) {
+		key := make([]byte, dataHashKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate data hash key: %w", err)
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: name.Namespace, Name: name.Name},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{dataHashKeySecretKey: key},
+		}
+		err = c.Create(ctx, secret)
+		// another replica created the key in the meantime
+		if apierrors.IsAlreadyExists(err) {
+			err = c.Get(ctx, name, secret)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data hash key from secret %s: %w", name, err)
+	}
+	key := secret.Data[dataHashKeySecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("secret %s does not contain a data hash key %q", name, dataHashKeySecretKey)
+	}
+	return key, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEnsureDataHashKey(t *testing.T) {
+	ctx := context.Background()
+	name := types.NamespacedName{Namespace: "passbolt-operator-system", Name: "passbolt-operator-data-hash-key"}
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "passbolt-operator-system", Name: "empty"}},
+	).Build()
+
+	key, err := EnsureDataHashKey(ctx, c, name)
+	if err != nil {
+		t.Fatalf("EnsureDataHashKey() error = %v", err)
+	}
+	if len(key) != dataHashKeyLength {
+		t.Errorf("EnsureDataHashKey() key length = %d, want %d", len(key), dataHashKeyLength)
+	}
+	// the key is kept, so that the hashes do not change after a restart
+	again, err := EnsureDataHashKey(ctx, c, name)
+	if err != nil {
+		t.Fatalf("EnsureDataHashKey() error = %v", err)
+	}
+	if !bytes.Equal(key, again) {
+		t.Error("EnsureDataHashKey() returned a different key for an existing secret")
+	}
+
+	if _, err := EnsureDataHashKey(ctx, c, types.NamespacedName{Namespace: "passbolt-operator-system", Name: "empty"}); err == nil {
+		t.Error("EnsureDataHashKey() error = nil, want an error for a secret without a key")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// eventReasonCreated is the reason of the event recorded when a Kubernetes secret was created.
+	eventReasonCreated = "Created"
+	// eventReasonUpdated is the reason of the event recorded when a Kubernetes secret was updated.
+	eventReasonUpdated = "Updated"
+	// eventReasonSyncFailed is the reason of the events recorded for the sync errors of a PassboltSecret.
+	eventReasonSyncFailed = "SyncFailed"
+	// eventReasonRolledOut is the reason of the event recorded when a workload was restarted for a PassboltSecret.
+	eventReasonRolledOut = "RolledOut"
+	// eventReasonRolloutFailed is the reason of the event recorded when the workloads of a PassboltSecret could not be restarted.
+	eventReasonRolloutFailed = "RolloutFailed"
+)
+
+// eventKey identifies equal events of an object.
+type eventKey struct {
+	object    string
+	eventType string
+	reason    string
+	message   string
+}
+
+// rateLimitedRecorder is an EventRecorder that drops events equal to an event recorded for the same object within interval.
+type rateLimitedRecorder struct {
+	record.EventRecorder
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	recorded  map[eventKey]time.Time
+	nextPrune time.Time
+}
+
+// NewRateLimitedRecorder returns an EventRecorder that records an event at most once per interval for the same object,
+// type, reason and message, so that a PassboltSecret that fails on every requeue does not flood the events.
+// If interval is not positive, the recorder is returned unchanged.
+func NewRateLimitedRecorder(recorder record.EventRecorder, interval time.Duration) record.EventRecorder {
+	if interval <= 0 {
+		return recorder
+	}
+	return &rateLimitedRecorder{
+		EventRecorder: recorder,
+		interval:      interval,
+		now:           time.Now,
+		recorded:      map[eventKey]time.Time{},
+	}
+}
+
+// Event records the event unless an equal event was recorded within the interval.
+func (r *rateLimitedRecorder) Event(object runtime.Object, eventType, reason, message string) {
+	if r.allow(object, eventType, reason, message) {
+		r.EventRecorder.Event(object, eventType, reason, message)
+	}
+}
+
+// Eventf records the event unless an equal event was recorded within the interval.
+func (r *rateLimitedRecorder) Eventf(object runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventType, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf records the event unless an equal event was recorded within the interval.
+func (r *rateLimitedRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventType, reason, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	if r.allow(object, eventType, reason, message) {
+		r.EventRecorder.AnnotatedEventf(object, annotations, eventType, reason, "%s", message)
+	}
+}
+
+// allow returns whether the event is recorded and remembers the time it was recorded.
+func (r *rateLimitedRecorder) allow(object runtime.Object, eventType, reason, message string) bool {
+	key := eventKey{eventType: eventType, reason: reason, message: message}
+	if obj, err := apimeta.Accessor(object); err == nil {
+		key.object = fmt.Sprintf("%s/%s/%s", obj.GetNamespace(), obj.GetName(), obj.GetUID())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	// forget the events that no longer suppress anything, so that deleted objects do not pile up
+	if now.After(r.nextPrune) {
+		for k, recorded := range r.recorded {
+			if now.Sub(recorded) >= r.interval {
+				delete(r.recorded, k)
+			}
+		}
+		r.nextPrune = now.Add(r.interval)
+	}
+	if recorded, ok := r.recorded[key]; ok && now.Sub(recorded) < r.interval {
+		return false
+	}
+	r.recorded[key] = now
+	return true
+}
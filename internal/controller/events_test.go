@@ -0,0 +1,94 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+func TestRateLimitedRecorder(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder := NewRateLimitedRecorder(fake, time.Minute).(*rateLimitedRecorder)
+	recorder.now = func() time.Time { return now }
+	app := &passboltv1.PassboltSecret{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a", UID: "1"}}
+	db := &passboltv1.PassboltSecret{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a", UID: "2"}}
+
+	recorder.Event(app, corev1.EventTypeWarning, eventReasonSyncFailed, "failed")
+	recorder.Eventf(app, corev1.EventTypeWarning, eventReasonSyncFailed, "%s", "failed")
+	recorder.Event(app, corev1.EventTypeWarning, eventReasonSyncFailed, "failed again")
+	recorder.Event(db, corev1.EventTypeWarning, eventReasonSyncFailed, "failed")
+	now = now.Add(time.Minute)
+	recorder.Event(app, corev1.EventTypeWarning, eventReasonSyncFailed, "failed")
+
+	want := []string{
+		"Warning SyncFailed failed",
+		"Warning SyncFailed failed again",
+		"Warning SyncFailed failed",
+		"Warning SyncFailed failed",
+	}
+	if diff := cmp.Diff(want, drainEvents(fake)); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+	if len(recorder.recorded) != 3 {
+		t.Errorf("recorded = %d events, want 3", len(recorder.recorded))
+	}
+
+	if got := NewRateLimitedRecorder(fake, 0); got != record.EventRecorder(fake) {
+		t.Errorf("NewRateLimitedRecorder() with interval 0 = %T, want the recorder", got)
+	}
+}
+
+func TestRecordSecretEvents(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	r := &PassboltSecretReconciler{Recorder: fake}
+	secret := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		Status: passboltv1.PassboltSecretStatus{
+			SyncErrors: []passboltv1.SyncError{
+				{Message: "unsupported secret type"},
+				{Message: "field does not exist", PassboltSecretID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", SecretKey: "password"},
+			},
+		},
+	}
+
+	r.recordSecretChange(secret, controllerutil.OperationResultCreated, []string{"password", "username"})
+	r.recordSecretChange(secret, controllerutil.OperationResultUpdated, []string{"password"})
+	r.recordSecretChange(secret, controllerutil.OperationResultUpdated, nil)
+	r.recordSecretChange(secret, controllerutil.OperationResultNone, nil)
+	r.recordSyncErrors(secret)
+
+	want := []string{
+		"Normal Created created secret app with keys: password, username",
+		"Normal Updated updated secret app, changed keys: password",
+		"Normal Updated updated secret app",
+		"Warning SyncFailed unsupported secret type",
+		"Warning SyncFailed failed to sync secret 184734ea-8be3-4f5a-ba6c-5f4b3c0603e8/password: field does not exist",
+	}
+	if diff := cmp.Diff(want, drainEvents(fake)); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+	"github.com/urbanmedia/passbolt-operator/pkg/util"
+)
+
+// PassboltGeneratedSecretReconciler reconciles a PassboltGeneratedSecret object
+type PassboltGeneratedSecretReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	PassboltClient *passbolt.Client
+	// Servers contains the clients of the PassboltServers and ClusterPassboltServers referenced by PassboltGeneratedSecrets.
+	Servers *passbolt.Pool
+	// DefaultServerURL is the URL of the passbolt server of PassboltClient.
+	// It is used to log in with the credentials referenced by PassboltGeneratedSecrets that do not reference a server.
+	DefaultServerURL string
+	// RequireCredentialsRef rejects PassboltGeneratedSecrets that do not reference their own credentials,
+	// so that no namespace is able to create resources with the credentials of the operator.
+	RequireCredentialsRef bool
+}
+
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltgeneratedsecrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltgeneratedsecrets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltgeneratedsecrets/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;create;update;delete;watch
+
+// Reconcile generates a password and stores it in passbolt, if the resource was not created yet.
+// Afterwards, the shares of the resource are reconciled and the resource is synced to the Kubernetes secret.
+func (r *PassboltGeneratedSecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+	logr.Info("starting reconciliation...", "name", req.NamespacedName)
+	defer logr.Info("finished reconciliation", "name", req.NamespacedName)
+
+	genSecret := &passboltv1.PassboltGeneratedSecret{}
+	if err := r.Client.Get(ctx, req.NamespacedName, genSecret); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// cleanup status
+	genSecret.Status.SyncErrors = []passboltv1.SyncError{}
+
+	pbClient, err := r.clients().get(ctx, genSecret.Namespace, genSecret.Spec.ServerRef, genSecret.Spec.CredentialsRef)
+	if err != nil {
+		return r.syncFailed(ctx, genSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: genSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+
+	// reject resources outside of the access policies of the namespace before anything is written to passbolt
+	policies, err := accessPolicies(ctx, r.Client, genSecret.Namespace)
+	if err == nil {
+		err = checkWriteTarget(pbClient, policies, genSecret.Status.ResourceID, genSecret.Spec.Resource.FolderID, false)
+	}
+	if err != nil {
+		if errors.Is(err, passboltv1.ErrReferenceNotAllowed) {
+			// data synced before the policy denied the resource must not stay readable
+			deleted, revokeErr := revokeSecret(ctx, r.Client, genSecret)
+			if revokeErr != nil {
+				return errResult, revokeErr
+			}
+			if deleted {
+				logr.Info("deleted secret, since its passbolt resource is not allowed by the access policies")
+			}
+		}
+		return r.syncFailed(ctx, genSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: genSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+
+	if genSecret.Status.ResourceID == "" {
+		id, err := r.ensureResource(ctx, pbClient, genSecret)
+		if err != nil {
+			return r.syncFailed(ctx, genSecret, passboltv1.SyncError{
+				Message: err.Error(),
+				Time:    metav1.Now(),
+			})
+		}
+		// persist the resource ID immediately, otherwise another password would be generated
+		genSecret.Status.ResourceID = id
+		genSecret.Status.CreatePending = false
+		if err := r.Client.Status().Update(ctx, genSecret); err != nil {
+			logr.Error(err, "failed to update status", "id", id)
+			return errResult, err
+		}
+	}
+
+	sharesChanged := !equality.Semantic.DeepEqual(genSecret.Spec.Shares, genSecret.Status.Shares)
+	if err := pbClient.ShareResource(ctx, genSecret.Status.ResourceID, passboltShares(genSecret.Spec.Shares), passboltShares(genSecret.Status.Shares)); err != nil {
+		return r.syncFailed(ctx, genSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: genSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+	genSecret.Status.Shares = genSecret.Spec.Shares
+
+	// define Kubernetes secret to be created or updated
+	k8sSecret := &corev1.Secret{
+		ObjectMeta: ctrl.ObjectMeta{
+			Name:        genSecret.Name,
+			Namespace:   genSecret.Namespace,
+			Labels:      genSecret.Labels,
+			Annotations: genSecret.Annotations,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{},
+	}
+
+	opRslt, err := controllerutil.CreateOrUpdate(ctx, r.Client, k8sSecret, util.UpdateSecretForOwner(ctx, pbClient, r.Scheme, genSecret, genSecret.PassboltSecretSpec(), k8sSecret, nil))
+	if err != nil {
+		return r.syncFailed(ctx, genSecret, err)
+	}
+
+	// if the secret was not changed and the status is already success, we can skip the update
+	if opRslt == controllerutil.OperationResultNone && !sharesChanged && genSecret.Status.SyncStatus == passboltv1.SyncStatusSuccess {
+		logr.V(10).Info("secret was not changed! skipping... ")
+		return ctrl.Result{}, nil
+	}
+
+	// update status
+	genSecret.Status.SyncStatus = passboltv1.SyncStatusSuccess
+	genSecret.Status.LastSync = metav1.Now()
+	if err := r.Client.Status().Update(ctx, genSecret); err != nil {
+		// the secret was synced successfully but the status could not be updated
+		return reconcile.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// ensureResource returns the ID of the passbolt resource of the PassboltGeneratedSecret.
+// A new resource with a generated password is created, unless a resource with the same name exists in the folder.
+// An existing resource is only used if it was created by a previous sync or if the spec allows to adopt it,
+// otherwise a tenant could gain access to any resource the user of the operator is able to share.
+func (r *PassboltGeneratedSecretReconciler) ensureResource(ctx context.Context, pbClient *passbolt.Client, genSecret *passboltv1.PassboltGeneratedSecret) (string, error) {
+	logr := log.FromContext(ctx)
+	spec := genSecret.Spec.Resource
+
+	ids, err := pbClient.FindResources(ctx, spec.Name, spec.FolderID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(ids) == 0:
+		// the resource does not exist yet or the previous create failed
+	case len(ids) > 1:
+		// creating another resource with the name would only add to the ambiguity
+		return "", fmt.Errorf("%d passbolt resources named %q exist in the folder, the resource to use cannot be identified", len(ids), spec.Name)
+	case genSecret.Status.CreatePending:
+		logr.Info("using passbolt resource created by a previous sync", "id", ids[0])
+		return ids[0], nil
+	case genSecret.Spec.AdoptExisting:
+		logr.Info("adopting existing passbolt resource", "id", ids[0])
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("passbolt resource named %q already exists in the folder, set spec.adoptExisting to use it", spec.Name)
+	}
+
+	if !genSecret.Status.CreatePending {
+		genSecret.Status.CreatePending = true
+		if err := r.Client.Status().Update(ctx, genSecret); err != nil {
+			return "", fmt.Errorf("failed to record the pending create: %w", err)
+		}
+	}
+	password, err := util.GeneratePassword(genSecret.Spec.PasswordPolicy)
+	if err != nil {
+		return "", err
+	}
+	id, err := pbClient.CreateResource(ctx, passbolt.PassboltSecretDefinition{
+		FolderParentID: spec.FolderID,
+		Name:           spec.Name,
+		Username:       spec.Username,
+		URI:            spec.URI,
+		Password:       password,
+		Description:    spec.Description,
+	})
+	if err != nil {
+		return "", err
+	}
+	logr.Info("created passbolt resource with generated password", "id", id)
+	return id, nil
+}
+
+// clients returns the resolver of the passbolt clients of PassboltGeneratedSecrets.
+func (r *PassboltGeneratedSecretReconciler) clients() clientResolver {
+	return clientResolver{
+		client:                r.Client,
+		defaultClient:         r.PassboltClient,
+		servers:               r.Servers,
+		defaultServerURL:      r.DefaultServerURL,
+		requireCredentialsRef: r.RequireCredentialsRef,
+	}
+}
+
+// syncFailed records the given error in the status of the PassboltGeneratedSecret.
+func (r *PassboltGeneratedSecretReconciler) syncFailed(ctx context.Context, genSecret *passboltv1.PassboltGeneratedSecret, err error) (ctrl.Result, error) {
+	genSecret.Status.SyncStatus = passboltv1.SyncStatusError
+	if snErr, ok := err.(passboltv1.SyncError); ok {
+		genSecret.Status.SyncErrors = append(genSecret.Status.SyncErrors, snErr)
+	}
+	if err := r.Client.Status().Update(ctx, genSecret); err != nil {
+		return errResult, err
+	}
+	return errResult, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PassboltGeneratedSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index PassboltGeneratedSecrets by their passbolt resource,
+	// so that we are able to find them when the resource changes in passbolt.
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltGeneratedSecret{}, passboltResourceIDIndex, func(obj client.Object) []string {
+		genSecret, ok := obj.(*passboltv1.PassboltGeneratedSecret)
+		if !ok || genSecret.Status.ResourceID == "" {
+			return nil
+		}
+		return []string{genSecret.Status.ResourceID}
+	})
+	if err != nil {
+		return err
+	}
+
+	// index PassboltGeneratedSecrets by their credentials secret,
+	// so that we are able to log in again when the secret changes.
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltGeneratedSecret{}, credentialsSecretIndex, func(obj client.Object) []string {
+		genSecret, ok := obj.(*passboltv1.PassboltGeneratedSecret)
+		if !ok || genSecret.Spec.CredentialsRef == nil {
+			return nil
+		}
+		return []string{types.NamespacedName{Namespace: genSecret.Namespace, Name: genSecret.Spec.CredentialsRef.Name}.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := make(chan event.GenericEvent, changeEventBufferSize)
+	listGeneratedSecrets := func() client.ObjectList {
+		return &passboltv1.PassboltGeneratedSecretList{}
+	}
+	changedResources := func(detected passbolt.Changes) []string {
+		return detected.Resources
+	}
+	r.PassboltClient.RegisterChangeHandler(enqueueChangedResources(r.Client, listGeneratedSecrets, changedResources, changes))
+	if r.Servers != nil {
+		r.Servers.RegisterChangeHandler(func(clnt *passbolt.Client) passbolt.ChangeHandler {
+			return enqueueChangedResources(r.Client, listGeneratedSecrets, changedResources, changes)
+		})
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&passboltv1.PassboltGeneratedSecret{}).
+		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(findObjectsForCredentialsSecret(r.Client, &passboltv1.PassboltGeneratedSecretList{}))).
+		Watches(&passboltv1.PassboltAccessPolicy{}, handler.EnqueueRequestsFromMapFunc(findObjectsForAccessPolicy(r.Client, &passboltv1.PassboltGeneratedSecretList{}))).
+		WatchesRawSource(source.Channel(changes, &handler.EnqueueRequestForObject{})).
+		Complete(r)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+func TestPassboltGeneratedSecret_PassboltSecretSpec(t *testing.T) {
+	template := "postgres://{{.Username}}:{{.Password}}@db"
+	tests := []struct {
+		name string
+		spec passboltv1.PassboltGeneratedSecretSpec
+		want passboltv1.PassboltSecretSpec
+	}{
+		{
+			name: "default secret keys",
+			spec: passboltv1.PassboltGeneratedSecretSpec{
+				LeaveOnDelete: true,
+			},
+			want: passboltv1.PassboltSecretSpec{
+				LeaveOnDelete: true,
+				SecretType:    corev1.SecretTypeOpaque,
+				PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+					"password": {ID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", Field: passboltv1.FieldNamePassword},
+				},
+			},
+		},
+		{
+			name: "custom secret keys",
+			spec: passboltv1.PassboltGeneratedSecretSpec{
+				SecretKeys: map[string]passboltv1.PassboltGeneratedSecretKey{
+					"dsn": {Value: &template},
+				},
+				PlainTextFields: map[string]string{"host": "db"},
+			},
+			want: passboltv1.PassboltSecretSpec{
+				SecretType: corev1.SecretTypeOpaque,
+				PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+					"dsn": {ID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", Value: &template},
+				},
+				PlainTextFields: map[string]string{"host": "db"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genSecret := &passboltv1.PassboltGeneratedSecret{
+				Spec: tt.spec,
+				Status: passboltv1.PassboltGeneratedSecretStatus{
+					ResourceID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8",
+				},
+			}
+			if diff := cmp.Diff(tt.want, genSecret.PassboltSecretSpec()); diff != "" {
+				t.Errorf("PassboltSecretSpec() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+const (
+	// pushSecretNameIndex is the field index of the Kubernetes secret referenced by a PassboltPushSecret.
+	pushSecretNameIndex = ".spec.secretName"
+)
+
+// PassboltPushSecretReconciler reconciles a PassboltPushSecret object
+type PassboltPushSecretReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	PassboltClient *passbolt.Client
+	// Servers contains the clients of the PassboltServers and ClusterPassboltServers referenced by PassboltPushSecrets.
+	Servers *passbolt.Pool
+	// DefaultServerURL is the URL of the passbolt server of PassboltClient.
+	// It is used to log in with the credentials referenced by PassboltPushSecrets that do not reference a server.
+	DefaultServerURL string
+	// RequireCredentialsRef rejects PassboltPushSecrets that do not reference their own credentials,
+	// so that no namespace is able to write resources with the credentials of the operator.
+	RequireCredentialsRef bool
+}
+
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltpushsecrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltpushsecrets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltpushsecrets/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile pushes the data of the referenced Kubernetes secret to passbolt.
+// The passbolt resource is created on the first sync and updated whenever it differs from the Kubernetes secret.
+// Afterwards, the shares of the resource are reconciled.
+func (r *PassboltPushSecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+	logr.Info("starting reconciliation...", "name", req.NamespacedName)
+	defer logr.Info("finished reconciliation", "name", req.NamespacedName)
+
+	pushSecret := &passboltv1.PassboltPushSecret{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pushSecret); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// cleanup status
+	pushSecret.Status.SyncErrors = []passboltv1.SyncError{}
+
+	pbClient, err := r.clients().get(ctx, pushSecret.Namespace, pushSecret.Spec.ServerRef, pushSecret.Spec.CredentialsRef)
+	if err != nil {
+		return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: pushSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+
+	// reject resources outside of the access policies of the namespace before anything is written to passbolt
+	policies, err := accessPolicies(ctx, r.Client, pushSecret.Namespace)
+	if err == nil {
+		err = checkWriteTarget(pbClient, policies, pushSecret.Status.ResourceID, pushSecret.Spec.Resource.FolderID, false)
+	}
+	if err != nil {
+		return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: pushSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+
+	k8sSecret := &corev1.Secret{}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: pushSecret.Namespace, Name: pushSecret.Spec.SecretName}, k8sSecret)
+	if err != nil {
+		return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+			Message:          fmt.Sprintf("failed to get secret %q: %s", pushSecret.Spec.SecretName, err),
+			PassboltSecretID: pushSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+
+	desired, err := pushSecretDefinition(pushSecret, k8sSecret)
+	if err != nil {
+		return r.syncFailed(ctx, pushSecret, err)
+	}
+
+	changed := false
+	if pushSecret.Status.ResourceID == "" {
+		id, err := r.createResource(ctx, pbClient, pushSecret, desired)
+		if err != nil {
+			return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+				Message: err.Error(),
+				Time:    metav1.Now(),
+			})
+		}
+		pushSecret.Status.ResourceID = id
+		pushSecret.Status.CreatePending = false
+		changed = true
+	} else {
+		// the resource may have been changed in the passbolt UI, so the cached secret must not be compared
+		current, err := pbClient.GetSecretUncached(ctx, pushSecret.Status.ResourceID)
+		if err != nil {
+			return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+				Message:          err.Error(),
+				PassboltSecretID: pushSecret.Status.ResourceID,
+				Time:             metav1.Now(),
+			})
+		}
+		if resourceDiffers(current, &desired) {
+			if err := pbClient.UpdateResource(ctx, pushSecret.Status.ResourceID, desired); err != nil {
+				return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+					Message:          err.Error(),
+					PassboltSecretID: pushSecret.Status.ResourceID,
+					Time:             metav1.Now(),
+				})
+			}
+			logr.Info("updated passbolt resource", "id", pushSecret.Status.ResourceID)
+			changed = true
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(pushSecret.Spec.Shares, pushSecret.Status.Shares) {
+		changed = true
+	}
+	if err := pbClient.ShareResource(ctx, pushSecret.Status.ResourceID, passboltShares(pushSecret.Spec.Shares), passboltShares(pushSecret.Status.Shares)); err != nil {
+		return r.syncFailed(ctx, pushSecret, passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: pushSecret.Status.ResourceID,
+			Time:             metav1.Now(),
+		})
+	}
+	pushSecret.Status.Shares = pushSecret.Spec.Shares
+
+	if !changed && pushSecret.Status.SyncStatus == passboltv1.SyncStatusSuccess {
+		logr.V(10).Info("passbolt resource was not changed! skipping... ")
+		return ctrl.Result{}, nil
+	}
+
+	// update status
+	pushSecret.Status.SyncStatus = passboltv1.SyncStatusSuccess
+	pushSecret.Status.LastSync = metav1.Now()
+	if err := r.Client.Status().Update(ctx, pushSecret); err != nil {
+		// the resource ID must not get lost, otherwise the resource is created again
+		logr.Error(err, "failed to update status", "id", pushSecret.Status.ResourceID)
+		return reconcile.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// createResource creates the passbolt resource of the PassboltPushSecret and returns its ID.
+// CreatePending is persisted before the resource is created. If it is already set, the ID of a resource created
+// by a previous sync may have been lost, so the resource with the name in the folder is used instead of creating another one.
+func (r *PassboltPushSecretReconciler) createResource(ctx context.Context, pbClient *passbolt.Client, pushSecret *passboltv1.PassboltPushSecret, desired passbolt.PassboltSecretDefinition) (string, error) {
+	logr := log.FromContext(ctx)
+	if pushSecret.Status.CreatePending {
+		ids, err := pbClient.FindResources(ctx, desired.Name, desired.FolderParentID)
+		if err != nil {
+			return "", err
+		}
+		switch len(ids) {
+		case 0:
+			// the previous create failed
+		case 1:
+			logr.Info("using passbolt resource created by a previous sync", "id", ids[0])
+			return ids[0], nil
+		default:
+			return "", fmt.Errorf("%d passbolt resources named %q exist in the folder, the resource created by a previous sync cannot be identified", len(ids), desired.Name)
+		}
+	} else {
+		pushSecret.Status.CreatePending = true
+		if err := r.Client.Status().Update(ctx, pushSecret); err != nil {
+			return "", fmt.Errorf("failed to record the pending create: %w", err)
+		}
+	}
+	id, err := pbClient.CreateResource(ctx, desired)
+	if err != nil {
+		return "", err
+	}
+	logr.Info("created passbolt resource", "id", id)
+	return id, nil
+}
+
+// clients returns the resolver of the passbolt clients of PassboltPushSecrets.
+func (r *PassboltPushSecretReconciler) clients() clientResolver {
+	return clientResolver{
+		client:                r.Client,
+		defaultClient:         r.PassboltClient,
+		servers:               r.Servers,
+		defaultServerURL:      r.DefaultServerURL,
+		requireCredentialsRef: r.RequireCredentialsRef,
+	}
+}
+
+// syncFailed records the given error in the status of the PassboltPushSecret.
+func (r *PassboltPushSecretReconciler) syncFailed(ctx context.Context, pushSecret *passboltv1.PassboltPushSecret, err error) (ctrl.Result, error) {
+	pushSecret.Status.SyncStatus = passboltv1.SyncStatusError
+	if snErr, ok := err.(passboltv1.SyncError); ok {
+		pushSecret.Status.SyncErrors = append(pushSecret.Status.SyncErrors, snErr)
+	}
+	if err := r.Client.Status().Update(ctx, pushSecret); err != nil {
+		return errResult, err
+	}
+	return errResult, err
+}
+
+// pushSecretDefinition builds the passbolt resource from the spec of the PassboltPushSecret and the Kubernetes secret.
+// The returned error is of type SyncError.
+func pushSecretDefinition(pushSecret *passboltv1.PassboltPushSecret, secret *corev1.Secret) (passbolt.PassboltSecretDefinition, error) {
+	spec := pushSecret.Spec.Resource
+	def := passbolt.PassboltSecretDefinition{
+		FolderParentID: spec.FolderID,
+		Name:           spec.Name,
+	}
+	fields := []struct {
+		name   string
+		value  *passboltv1.PassboltPushValue
+		target *string
+	}{
+		{name: "password", value: &spec.Password, target: &def.Password},
+		{name: "username", value: spec.Username, target: &def.Username},
+		{name: "uri", value: spec.URI, target: &def.URI},
+		{name: "description", value: spec.Description, target: &def.Description},
+	}
+	for _, field := range fields {
+		if field.value == nil {
+			continue
+		}
+		value, err := pushValue(field.value, secret)
+		if err != nil {
+			return def, passboltv1.SyncError{
+				Message:          fmt.Sprintf("invalid value for field %q: %s", field.name, err),
+				PassboltSecretID: pushSecret.Status.ResourceID,
+				SecretKey:        field.value.SecretKey,
+				Time:             metav1.Now(),
+			}
+		}
+		*field.target = value
+	}
+	return def, nil
+}
+
+// pushValue resolves the value of a PassboltPushValue.
+func pushValue(value *passboltv1.PassboltPushValue, secret *corev1.Secret) (string, error) {
+	if value.SecretKey != "" && value.Value != "" {
+		return "", fmt.Errorf("secretKey and value are mutually exclusive")
+	}
+	if value.SecretKey == "" && value.Value == "" {
+		return "", fmt.Errorf("either secretKey or value must be set")
+	}
+	if value.SecretKey == "" {
+		return value.Value, nil
+	}
+	data, ok := secret.Data[value.SecretKey]
+	if !ok {
+		return "", fmt.Errorf("key %q does not exist in secret %q", value.SecretKey, secret.Name)
+	}
+	return string(data), nil
+}
+
+// resourceDiffers reports whether the passbolt resource must be updated to match the desired state.
+func resourceDiffers(current, desired *passbolt.PassboltSecretDefinition) bool {
+	return current.Name != desired.Name ||
+		current.Username != desired.Username ||
+		current.URI != desired.URI ||
+		current.Password != desired.Password ||
+		current.Description != desired.Description
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PassboltPushSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index PassboltPushSecrets by the Kubernetes secret they reference,
+	// so that we are able to find them when the secret changes.
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltPushSecret{}, pushSecretNameIndex, func(obj client.Object) []string {
+		pushSecret, ok := obj.(*passboltv1.PassboltPushSecret)
+		if !ok {
+			return nil
+		}
+		return []string{pushSecret.Spec.SecretName}
+	})
+	if err != nil {
+		return err
+	}
+
+	// index PassboltPushSecrets by their passbolt resource,
+	// so that we are able to push the secret again when the resource is modified in passbolt.
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltPushSecret{}, passboltResourceIDIndex, func(obj client.Object) []string {
+		pushSecret, ok := obj.(*passboltv1.PassboltPushSecret)
+		if !ok || pushSecret.Status.ResourceID == "" {
+			return nil
+		}
+		return []string{pushSecret.Status.ResourceID}
+	})
+	if err != nil {
+		return err
+	}
+
+	// index PassboltPushSecrets by their credentials secret,
+	// so that we are able to log in again when the secret changes.
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltPushSecret{}, credentialsSecretIndex, func(obj client.Object) []string {
+		pushSecret, ok := obj.(*passboltv1.PassboltPushSecret)
+		if !ok || pushSecret.Spec.CredentialsRef == nil {
+			return nil
+		}
+		return []string{types.NamespacedName{Namespace: pushSecret.Namespace, Name: pushSecret.Spec.CredentialsRef.Name}.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := make(chan event.GenericEvent, changeEventBufferSize)
+	listPushSecrets := func() client.ObjectList {
+		return &passboltv1.PassboltPushSecretList{}
+	}
+	changedResources := func(detected passbolt.Changes) []string {
+		return detected.Resources
+	}
+	r.PassboltClient.RegisterChangeHandler(enqueueChangedResources(r.Client, listPushSecrets, changedResources, changes))
+	if r.Servers != nil {
+		r.Servers.RegisterChangeHandler(func(clnt *passbolt.Client) passbolt.ChangeHandler {
+			return enqueueChangedResources(r.Client, listPushSecrets, changedResources, changes)
+		})
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&passboltv1.PassboltPushSecret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findPushSecretsForSecret)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(findObjectsForCredentialsSecret(r.Client, &passboltv1.PassboltPushSecretList{}))).
+		Watches(&passboltv1.PassboltAccessPolicy{}, handler.EnqueueRequestsFromMapFunc(findObjectsForAccessPolicy(r.Client, &passboltv1.PassboltPushSecretList{}))).
+		WatchesRawSource(source.Channel(changes, &handler.EnqueueRequestForObject{})).
+		Complete(r)
+}
+
+// findPushSecretsForSecret returns the PassboltPushSecrets referencing the given Kubernetes secret.
+func (r *PassboltPushSecretReconciler) findPushSecretsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &passboltv1.PassboltPushSecretList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(obj.GetNamespace()), client.MatchingFields{pushSecretNameIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list PassboltPushSecrets referencing secret", "name", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&item)})
+	}
+	return requests
+}
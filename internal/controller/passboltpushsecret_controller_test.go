@@ -0,0 +1,154 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+func TestPushSecretDefinition(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-credentials",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"username": []byte("app"),
+			"password": []byte("s3cr3t"),
+		},
+	}
+	tests := []struct {
+		name     string
+		resource passboltv1.PassboltPushResource
+		want     passbolt.PassboltSecretDefinition
+		wantErr  bool
+	}{
+		{
+			name: "secret keys and static values",
+			resource: passboltv1.PassboltPushResource{
+				Name:     "db",
+				FolderID: "b3bd4ba5-8d6e-4d4e-9e1a-cbb48c0bd0a1",
+				Password: passboltv1.PassboltPushValue{SecretKey: "password"},
+				Username: &passboltv1.PassboltPushValue{SecretKey: "username"},
+				URI:      &passboltv1.PassboltPushValue{Value: "postgres.default.svc:5432"},
+			},
+			want: passbolt.PassboltSecretDefinition{
+				FolderParentID: "b3bd4ba5-8d6e-4d4e-9e1a-cbb48c0bd0a1",
+				Name:           "db",
+				Username:       "app",
+				URI:            "postgres.default.svc:5432",
+				Password:       "s3cr3t",
+			},
+		},
+		{
+			name: "missing secret key",
+			resource: passboltv1.PassboltPushResource{
+				Name:     "db",
+				Password: passboltv1.PassboltPushValue{SecretKey: "token"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "neither secret key nor value",
+			resource: passboltv1.PassboltPushResource{
+				Name:     "db",
+				Password: passboltv1.PassboltPushValue{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "secret key and value",
+			resource: passboltv1.PassboltPushResource{
+				Name:     "db",
+				Password: passboltv1.PassboltPushValue{SecretKey: "password", Value: "static"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushSecret := &passboltv1.PassboltPushSecret{
+				Spec: passboltv1.PassboltPushSecretSpec{
+					SecretName: secret.Name,
+					Resource:   tt.resource,
+				},
+			}
+			got, err := pushSecretDefinition(pushSecret, secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("pushSecretDefinition() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if _, ok := err.(passboltv1.SyncError); !ok {
+					t.Errorf("pushSecretDefinition() error = %T, want passboltv1.SyncError", err)
+				}
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("pushSecretDefinition() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResourceDiffers(t *testing.T) {
+	desired := &passbolt.PassboltSecretDefinition{
+		Name:     "db",
+		Username: "app",
+		Password: "s3cr3t",
+	}
+	tests := []struct {
+		name    string
+		current *passbolt.PassboltSecretDefinition
+		want    bool
+	}{
+		{
+			name: "equal",
+			current: &passbolt.PassboltSecretDefinition{
+				FolderParentID: "b3bd4ba5-8d6e-4d4e-9e1a-cbb48c0bd0a1",
+				Name:           "db",
+				Username:       "app",
+				Password:       "s3cr3t",
+				ResourceType:   "password-and-description",
+			},
+			want: false,
+		},
+		{
+			name: "password changed",
+			current: &passbolt.PassboltSecretDefinition{
+				Name:     "db",
+				Username: "app",
+				Password: "old",
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resourceDiffers(tt.current, desired); got != tt.want {
+				t.Errorf("resourceDiffers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -20,16 +20,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
 	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
@@ -41,8 +48,35 @@ type PassboltSecretReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
 	PassboltClient *passbolt.Client
+	// Servers contains the clients of the PassboltServers and ClusterPassboltServers referenced by PassboltSecrets.
+	Servers *passbolt.Pool
+	// DefaultServerURL is the URL of the passbolt server of PassboltClient.
+	// It is used to log in with the credentials referenced by PassboltSecrets that do not reference a server.
+	DefaultServerURL string
+	// RequireCredentialsRef rejects PassboltSecrets that do not reference their own credentials,
+	// so that no namespace is able to sync secrets with the credentials of the operator.
+	RequireCredentialsRef bool
+	// DefaultRefreshInterval is the interval after which a PassboltSecret is re-synced from passbolt
+	// if the PassboltSecret does not define its own refresh interval. 0 disables the periodic re-sync.
+	DefaultRefreshInterval time.Duration
+	// Recorder records events of PassboltSecrets. If nil, no events are recorded.
+	Recorder record.EventRecorder
+	// RolloutAnnotation is the prefix of the annotation patched into the pod templates of the workloads
+	// that are restarted when the data of a secret changes. A short hash of the name of the PassboltSecret is appended to it.
+	// An empty annotation disables the restart of workloads.
+	RolloutAnnotation string
+	// DataHashKey is the key of the HMAC of the data of the secrets published in the status and the rollout annotations.
+	// If it is empty, no hash is published and workloads are not restarted.
+	DataHashKey []byte
 }
 
+const (
+	// passboltResourceIDIndex is the field index of all passbolt resource IDs referenced by a PassboltSecret.
+	passboltResourceIDIndex = ".spec.passboltResourceIDs"
+	// changeEventBufferSize is the number of change events that can be queued before they are dropped.
+	changeEventBufferSize = 1024
+)
+
 var (
 	errResult = ctrl.Result{
 		Requeue:      true,
@@ -54,6 +88,7 @@ var (
 //+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltsecrets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltsecrets/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;create;update;delete;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -86,18 +121,33 @@ func (r *PassboltSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	// cleanup status
 	secret.Status.SyncErrors = []passboltv1.SyncError{}
 
-	if secret.Spec.PassboltSecretID == nil && secret.Spec.PassboltSecrets == nil && secret.Spec.PlainTextFields == nil {
-		return errResult, fmt.Errorf("no passbolt secret id, passbolt secret references or plain text fields defined")
+	if secret.Spec.PassboltSecretID == nil && secret.Spec.PassboltSecrets == nil && secret.Spec.PassboltFolder == nil &&
+		secret.Spec.TagSelector == nil && secret.Spec.PlainTextFields == nil {
+		err := fmt.Errorf("no passbolt secret id, passbolt secret references, folder, tag selector or plain text fields defined")
+		secret.Status.SyncStatus = passboltv1.SyncStatusError
+		secret.Status.SyncErrors = append(secret.Status.SyncErrors, passboltv1.SyncError{
+			Message: err.Error(),
+			Time:    metav1.Now(),
+		})
+		setSyncConditions(secret, syncOutcome{reason: passboltv1.ReasonInvalidSpec, err: err})
+		r.recordSyncErrors(secret)
+		if err := r.Client.Status().Update(ctx, secret); err != nil {
+			return errResult, err
+		}
+		return errResult, err
 	}
 
 	// make sure that the secret type is supported
 	if secret.Spec.SecretType != corev1.SecretTypeOpaque && secret.Spec.SecretType != corev1.SecretTypeDockerConfigJson {
 		logr.Info("unsupported secret type", "type", secret.Spec.SecretType)
+		err := fmt.Errorf("unsupported secret type %q", secret.Spec.SecretType)
 		secret.Status.SyncStatus = passboltv1.SyncStatusError
 		secret.Status.SyncErrors = append(secret.Status.SyncErrors, passboltv1.SyncError{
-			Message: fmt.Sprintf("unsupported secret type %q", secret.Spec.SecretType),
+			Message: err.Error(),
 			Time:    metav1.Now(),
 		})
+		setSyncConditions(secret, syncOutcome{reason: passboltv1.ReasonInvalidSpec, err: err})
+		r.recordSyncErrors(secret)
 		if err := r.Client.Status().Update(ctx, secret); err != nil {
 			return errResult, err
 		}
@@ -116,24 +166,100 @@ func (r *PassboltSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		Data: map[string][]byte{},
 	}
 
-	opRslt, err := controllerutil.CreateOrUpdate(ctx, r.Client, k8sSecret, util.UpdateSecret(ctx, r.PassboltClient, r.Scheme, secret, k8sSecret))
+	pbClient, err := r.passboltClient(ctx, secret)
+	outcome := syncOutcome{reason: passboltv1.ReasonPassboltUnavailable, err: err, passboltErr: err}
+	if err == nil {
+		// reject references outside of the access policies of the namespace before anything is read from passbolt
+		var policies []passboltv1.PassboltAccessPolicySpec
+		policies, err = accessPolicies(ctx, r.Client, secret.Namespace)
+		if err == nil {
+			err = checkAccessPolicies(pbClient, policies, secret.Spec, false)
+		}
+		outcome = syncOutcome{reason: passboltv1.ReasonAccessDenied, err: err}
+	}
 	if err != nil {
+		if errors.Is(err, passboltv1.ErrReferenceNotAllowed) {
+			// data synced before the policy denied the references must not stay readable
+			deleted, revokeErr := revokeSecret(ctx, r.Client, secret)
+			if revokeErr != nil {
+				return errResult, revokeErr
+			}
+			if deleted {
+				logr.Info("deleted secret, since its passbolt references are not allowed by the access policies")
+			}
+		}
+		setSyncConditions(secret, outcome)
+		secret.Status.SyncStatus = passboltv1.SyncStatusError
+		secret.Status.SyncErrors = append(secret.Status.SyncErrors, passboltv1.SyncError{
+			Message: err.Error(),
+			Time:    metav1.Now(),
+		})
+		r.recordSyncErrors(secret)
+		if err := r.Client.Status().Update(ctx, secret); err != nil {
+			return errResult, err
+		}
+		return errResult, nil
+	}
+
+	report := &util.SyncReport{}
+	opRslt, err := controllerutil.CreateOrUpdate(ctx, r.Client, k8sSecret, util.UpdateSecret(ctx, pbClient, r.Scheme, secret, k8sSecret, report))
+	// the condition is only cleared after a complete sync, since a failed sync may not have checked every reference
+	missingChanged := false
+	if err == nil || len(report.MissingResources) > 0 {
+		missingChanged = r.setResourceNotFoundCondition(secret, report.MissingResources)
+	}
+	outcome = syncOutcome{reason: passboltv1.ReasonSyncFailed, err: err, passboltErr: report.PassboltError, passboltContacted: true}
+	if errors.Is(err, util.ErrDeleteSecret) {
+		outcome.reason = passboltv1.ReasonSecretDeleted
+		return r.deleteSecret(ctx, secret, k8sSecret, report.MissingResources, outcome)
+	}
+	if err != nil {
+		if report.PassboltError != nil {
+			outcome.reason = passboltv1.ReasonPassboltUnavailable
+		}
+		setSyncConditions(secret, outcome)
 		if snErr, ok := err.(passboltv1.SyncError); ok {
 			secret.Status.SyncStatus = passboltv1.SyncStatusError
 			secret.Status.SyncErrors = append(secret.Status.SyncErrors, snErr)
+			r.recordSyncErrors(secret)
 			if err := r.Client.Status().Update(ctx, secret); err != nil {
 				return errResult, err
 			}
 			return errResult, err
 		}
+		// the conditions are updated on a best-effort basis, since the Kubernetes API failed already
+		if err := r.Client.Status().Update(ctx, secret); err != nil {
+			logr.Error(err, "failed to update status")
+		}
 		return errResult, err
 	}
+	r.recordSecretChange(secret, opRslt, report.ChangedKeys)
+
+	// the workloads are restarted before the hash is stored, so that a failed restart is retried
+	dataHash := r.dataHash(k8sSecret.Data, report.ExpiringKeys)
+	if err := r.rollout(ctx, secret, dataHash); err != nil {
+		if r.Recorder != nil {
+			r.Recorder.Eventf(secret, corev1.EventTypeWarning, eventReasonRolloutFailed, "failed to restart workloads: %s", err)
+		}
+		// the secret was synced, so that only the previous hash is kept
+		outcome.rolloutErr = err
+		setSyncConditions(secret, outcome)
+		secret.Status.SyncStatus = passboltv1.SyncStatusSuccess
+		secret.Status.LastSync = metav1.Now()
+		if updateErr := r.Client.Status().Update(ctx, secret); updateErr != nil {
+			return errResult, updateErr
+		}
+		return errResult, err
+	}
+	conditionsChanged := setSyncConditions(secret, outcome)
+	hashChanged := secret.Status.DataHash != dataHash
+	secret.Status.DataHash = dataHash
 
 	// if the secret was not changed and the status is already success, we can skip the update
-	if opRslt == controllerutil.OperationResultNone && secret.Status.SyncStatus == passboltv1.SyncStatusSuccess {
+	if opRslt == controllerutil.OperationResultNone && secret.Status.SyncStatus == passboltv1.SyncStatusSuccess && !missingChanged && !conditionsChanged && !hashChanged {
 		// secret was not changed
 		logr.V(10).Info("secret was not changed! skipping... ")
-		return ctrl.Result{}, nil
+		return r.successResult(secret, report), nil
 	}
 
 	// update status
@@ -144,13 +270,372 @@ func (r *PassboltSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		// the secret was synced successfully but the status could not be updated
 		return reconcile.Result{}, err
 	}
-	return ctrl.Result{}, nil
+	return r.successResult(secret, report), nil
+}
+
+// setResourceNotFoundCondition sets the ResourceNotFound condition of the PassboltSecret to the given missing passbolt resources.
+// If the condition changed, an event is recorded for every missing resource. It returns whether the condition changed.
+func (r *PassboltSecretReconciler) setResourceNotFoundCondition(secret *passboltv1.PassboltSecret, missing []string) bool {
+	condition := metav1.Condition{
+		Type:               passboltv1.ConditionTypeResourceNotFound,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: secret.Generation,
+		Reason:             passboltv1.ReasonResourcesFound,
+		Message:            "all referenced passbolt resources exist",
+	}
+	if len(missing) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = passboltv1.ReasonResourceMissing
+		condition.Message = fmt.Sprintf("referenced passbolt resources do not exist: %s", strings.Join(missing, ", "))
+	}
+	changed := apimeta.SetStatusCondition(&secret.Status.Conditions, condition)
+	if changed && r.Recorder != nil {
+		policy := secret.Spec.OnMissing
+		if policy == "" {
+			policy = passboltv1.OnMissingFail
+		}
+		for _, id := range missing {
+			r.Recorder.Eventf(secret, corev1.EventTypeWarning, passboltv1.ConditionTypeResourceNotFound,
+				"passbolt resource %s does not exist, applying onMissing policy %s", id, policy)
+		}
+	}
+	return changed
+}
+
+// recordSecretChange records an event if the Kubernetes secret of the PassboltSecret was created or updated.
+// The event lists the changed keys, but never their values.
+func (r *PassboltSecretReconciler) recordSecretChange(secret *passboltv1.PassboltSecret, opRslt controllerutil.OperationResult, changedKeys []string) {
+	if r.Recorder == nil {
+		return
+	}
+	switch opRslt {
+	case controllerutil.OperationResultCreated:
+		r.Recorder.Eventf(secret, corev1.EventTypeNormal, eventReasonCreated, "created secret %s with keys: %s", secret.Name, strings.Join(changedKeys, ", "))
+	case controllerutil.OperationResultUpdated:
+		if len(changedKeys) == 0 {
+			// e.g. the labels or annotations of the secret changed
+			r.Recorder.Eventf(secret, corev1.EventTypeNormal, eventReasonUpdated, "updated secret %s", secret.Name)
+			return
+		}
+		r.Recorder.Eventf(secret, corev1.EventTypeNormal, eventReasonUpdated, "updated secret %s, changed keys: %s", secret.Name, strings.Join(changedKeys, ", "))
+	}
+}
+
+// recordSyncErrors records a warning event for every sync error in the status of the PassboltSecret.
+func (r *PassboltSecretReconciler) recordSyncErrors(secret *passboltv1.PassboltSecret) {
+	if r.Recorder == nil {
+		return
+	}
+	for _, syncErr := range secret.Status.SyncErrors {
+		message := syncErr.Error()
+		// errors of the PassboltSecret itself do not refer to a passbolt resource or key
+		if syncErr.PassboltSecretID == "" && syncErr.SecretKey == "" {
+			message = syncErr.Message
+		}
+		r.Recorder.Event(secret, corev1.EventTypeWarning, eventReasonSyncFailed, message)
+	}
+}
+
+// syncOutcome is the outcome of a sync of a PassboltSecret, which is reflected by its conditions.
+type syncOutcome struct {
+	// reason is the reason of the Synced condition if the sync failed.
+	reason string
+	// err is the error of the sync. It is nil if the sync succeeded.
+	err error
+	// passboltErr is the error of a call to passbolt that failed. It is nil if passbolt answered all calls.
+	passboltErr error
+	// passboltContacted is true if passbolt was called during the sync.
+	// If neither passboltContacted is true nor passboltErr is set, the PassboltReachable condition is left unchanged.
+	passboltContacted bool
+	// rolloutErr is the error of the restart of the workloads after a successful sync. It is nil if the restart succeeded.
+	rolloutErr error
+}
+
+// setSyncConditions sets the Ready, Synced, PassboltReachable and Degraded conditions and the observed generation
+// of the PassboltSecret to the outcome of a sync. The ResourceNotFound condition must be set before.
+// It returns whether the status changed.
+func setSyncConditions(secret *passboltv1.PassboltSecret, outcome syncOutcome) bool {
+	changed := secret.Status.ObservedGeneration != secret.Generation
+	secret.Status.ObservedGeneration = secret.Generation
+	set := func(conditionType string, status metav1.ConditionStatus, reason, message string) {
+		if apimeta.SetStatusCondition(&secret.Status.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: secret.Generation,
+			Reason:             reason,
+			Message:            message,
+		}) {
+			changed = true
+		}
+	}
+
+	if outcome.err != nil {
+		set(passboltv1.ConditionTypeSynced, metav1.ConditionFalse, outcome.reason, outcome.err.Error())
+	} else {
+		set(passboltv1.ConditionTypeSynced, metav1.ConditionTrue, passboltv1.ReasonSynced, "the secret is synced from passbolt")
+	}
+
+	switch {
+	case outcome.passboltErr != nil:
+		set(passboltv1.ConditionTypePassboltReachable, metav1.ConditionFalse, passboltv1.ReasonPassboltUnavailable, outcome.passboltErr.Error())
+	case outcome.passboltContacted:
+		set(passboltv1.ConditionTypePassboltReachable, metav1.ConditionTrue, passboltv1.ReasonReachable, "passbolt answered all calls")
+	}
+
+	degraded := true
+	missing := apimeta.FindStatusCondition(secret.Status.Conditions, passboltv1.ConditionTypeResourceNotFound)
+	switch {
+	case missing != nil && missing.Status == metav1.ConditionTrue:
+		set(passboltv1.ConditionTypeDegraded, metav1.ConditionTrue, passboltv1.ReasonResourceMissing, missing.Message)
+	case outcome.rolloutErr != nil:
+		set(passboltv1.ConditionTypeDegraded, metav1.ConditionTrue, passboltv1.ReasonRolloutFailed,
+			fmt.Sprintf("failed to restart workloads: %s", outcome.rolloutErr))
+	// the secret of the last successful sync is still served, unless it was deleted
+	case outcome.err != nil && outcome.reason != passboltv1.ReasonSecretDeleted && !secret.Status.LastSync.IsZero():
+		set(passboltv1.ConditionTypeDegraded, metav1.ConditionTrue, passboltv1.ReasonStaleData,
+			fmt.Sprintf("the secret of the last successful sync at %s is served", secret.Status.LastSync.UTC().Format(time.RFC3339)))
+	default:
+		degraded = false
+		set(passboltv1.ConditionTypeDegraded, metav1.ConditionFalse, passboltv1.ReasonHealthy, "the secret is complete and up to date")
+	}
+
+	switch {
+	case outcome.err != nil:
+		set(passboltv1.ConditionTypeReady, metav1.ConditionFalse, outcome.reason, outcome.err.Error())
+	case degraded:
+		set(passboltv1.ConditionTypeReady, metav1.ConditionFalse, passboltv1.ReasonDegraded, "the secret is synced, but degraded")
+	default:
+		set(passboltv1.ConditionTypeReady, metav1.ConditionTrue, passboltv1.ReasonSynced, "the secret is synced from passbolt")
+	}
+	return changed
+}
+
+// deleteSecret deletes the Kubernetes secret of the PassboltSecret, because the given referenced passbolt resources
+// do not exist and the OnMissing policy is DeleteSecret.
+func (r *PassboltSecretReconciler) deleteSecret(ctx context.Context, secret *passboltv1.PassboltSecret, k8sSecret *corev1.Secret, missing []string, outcome syncOutcome) (ctrl.Result, error) {
+	if err := r.Client.Delete(ctx, k8sSecret); client.IgnoreNotFound(err) != nil {
+		return errResult, err
+	}
+	log.FromContext(ctx).Info("deleted secret, since referenced passbolt resources do not exist", "missing", missing)
+	syncErr := passboltv1.SyncError{
+		Message:          "referenced passbolt resources do not exist, the secret was deleted",
+		PassboltSecretID: strings.Join(missing, ","),
+		Time:             metav1.Now(),
+	}
+	outcome.err = syncErr
+	setSyncConditions(secret, outcome)
+	secret.Status.SyncStatus = passboltv1.SyncStatusError
+	secret.Status.SyncErrors = append(secret.Status.SyncErrors, syncErr)
+	r.recordSyncErrors(secret)
+	if err := r.Client.Status().Update(ctx, secret); err != nil {
+		return errResult, err
+	}
+	// the secret is recreated once the resources exist again
+	return errResult, nil
+}
+
+// passboltClient returns the client of the passbolt server the PassboltSecret is synced from.
+// If the PassboltSecret references credentials, the client of the server logged in with these credentials is returned.
+func (r *PassboltSecretReconciler) passboltClient(ctx context.Context, secret *passboltv1.PassboltSecret) (*passbolt.Client, error) {
+	return r.clients().get(ctx, secret.Namespace, secret.Spec.ServerRef, secret.Spec.CredentialsRef)
+}
+
+// clients returns the resolver of the passbolt clients of PassboltSecrets.
+func (r *PassboltSecretReconciler) clients() clientResolver {
+	return clientResolver{
+		client:                r.Client,
+		defaultClient:         r.PassboltClient,
+		servers:               r.Servers,
+		defaultServerURL:      r.DefaultServerURL,
+		requireCredentialsRef: r.RequireCredentialsRef,
+	}
+}
+
+// successResult returns the result of a successful reconciliation.
+// If a refresh interval is configured, the PassboltSecret is requeued to pick up changes made in passbolt.
+// If the synced data expires earlier (e.g. TOTP codes), the PassboltSecret is requeued when the data expires.
+func (r *PassboltSecretReconciler) successResult(secret *passboltv1.PassboltSecret, report *util.SyncReport) ctrl.Result {
+	interval := r.DefaultRefreshInterval
+	if secret.Spec.RefreshInterval != nil {
+		interval = secret.Spec.RefreshInterval.Duration
+	}
+	if report.RequeueAfter > 0 && (interval <= 0 || report.RequeueAfter < interval) {
+		interval = report.RequeueAfter
+	}
+	if interval <= 0 {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: interval}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PassboltSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index PassboltSecrets by the passbolt resources they reference,
+	// so that we are able to find them when a resource changes in passbolt.
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltSecret{}, passboltResourceIDIndex, indexPassboltResourceIDs)
+	if err != nil {
+		return err
+	}
+
+	// index PassboltSecrets by their credentials secret,
+	// so that we are able to log in again when the secret changes.
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltSecret{}, credentialsSecretIndex, func(obj client.Object) []string {
+		secret, ok := obj.(*passboltv1.PassboltSecret)
+		if !ok || secret.Spec.CredentialsRef == nil {
+			return nil
+		}
+		return []string{types.NamespacedName{Namespace: secret.Namespace, Name: secret.Spec.CredentialsRef.Name}.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := make(chan event.GenericEvent, changeEventBufferSize)
+	r.PassboltClient.RegisterChangeHandler(enqueueChangedResources(r.Client, func() client.ObjectList {
+		return &passboltv1.PassboltSecretList{}
+	}, changeIndexKeys(r.PassboltClient), changes))
+	// the resource IDs are unique across servers, but names, paths and tags are not.
+	// A change may therefore enqueue PassboltSecrets of other servers, which is harmless.
+	if r.Servers != nil {
+		r.Servers.RegisterChangeHandler(func(clnt *passbolt.Client) passbolt.ChangeHandler {
+			return enqueueChangedResources(r.Client, func() client.ObjectList {
+				return &passboltv1.PassboltSecretList{}
+			}, changeIndexKeys(clnt), changes)
+		})
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&passboltv1.PassboltSecret{}).
 		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(findObjectsForCredentialsSecret(r.Client, &passboltv1.PassboltSecretList{}))).
+		Watches(&passboltv1.PassboltAccessPolicy{}, handler.EnqueueRequestsFromMapFunc(findObjectsForAccessPolicy(r.Client, &passboltv1.PassboltSecretList{}))).
+		WatchesRawSource(source.Channel(changes, &handler.EnqueueRequestForObject{})).
 		Complete(r)
 }
+
+// indexPassboltResourceIDs returns the IDs of all passbolt resources referenced by the given PassboltSecret.
+func indexPassboltResourceIDs(obj client.Object) []string {
+	secret, ok := obj.(*passboltv1.PassboltSecret)
+	if !ok {
+		return nil
+	}
+	ids := []string{}
+	if secret.Spec.PassboltSecretID != nil {
+		ids = append(ids, *secret.Spec.PassboltSecretID)
+	}
+	for _, ref := range secret.Spec.PassboltSecrets {
+		// references by name or folder path are indexed by their selector, see changeIndexKeys
+		ids = append(ids, ref.Selector())
+	}
+	if secret.Spec.PassboltFolder != nil {
+		ids = append(ids, folderIndexKey(*secret.Spec.PassboltFolder))
+	}
+	if secret.Spec.TagSelector != nil {
+		for _, tag := range secret.Spec.TagSelector.Tags {
+			ids = append(ids, tagIndexKey(tag))
+		}
+	}
+	return ids
+}
+
+// folderIndexKey returns the key of passboltResourceIDIndex for a PassboltSecret syncing the given folder.
+func folderIndexKey(ref passboltv1.PassboltFolderRef) string {
+	return "folder:" + ref.Selector()
+}
+
+// tagIndexKey returns the key of passboltResourceIDIndex for a PassboltSecret selecting resources with the given tag.
+func tagIndexKey(tag string) string {
+	return "tag:" + tag
+}
+
+// changeIndexKeys returns a function that returns all keys of passboltResourceIDIndex a PassboltSecret
+// referencing one of the changed passbolt resources, folders or tags may be indexed with.
+func changeIndexKeys(clnt *passbolt.Client) func(changes passbolt.Changes) []string {
+	folderKeys := func(id string) []string {
+		keys := []string{folderIndexKey(passboltv1.PassboltFolderRef{ID: id})}
+		if path, ok := clnt.FolderPath(id); ok {
+			keys = append(keys, folderIndexKey(passboltv1.PassboltFolderRef{Path: path}))
+		}
+		return keys
+	}
+	pathKeys := func(path passbolt.ResourcePath) []string {
+		return []string{
+			passboltv1.PassboltSecretRef{Name: path.Name}.Selector(),
+			passboltv1.PassboltSecretRef{FolderPath: path.FolderPath}.Selector(),
+		}
+	}
+	return func(changes passbolt.Changes) []string {
+		keys := []string{}
+		for _, id := range changes.Resources {
+			keys = append(keys, id)
+			if name, folderPath, ok := clnt.ResourcePaths(id); ok {
+				keys = append(keys, pathKeys(passbolt.ResourcePath{Name: name, FolderPath: folderPath})...)
+			}
+			// deleted, renamed and moved resources are referenced by their previous name or folder path
+			if path, ok := changes.PreviousPaths[id]; ok {
+				keys = append(keys, pathKeys(path)...)
+			}
+			// the resource may be synced as part of its folder
+			if folderID, ok := clnt.ResourceFolder(id); ok && folderID != "" {
+				keys = append(keys, folderKeys(folderID)...)
+			}
+			// the resource may be selected by one of its tags
+			for _, tag := range clnt.ResourceTags(id) {
+				keys = append(keys, tagIndexKey(tag))
+			}
+		}
+		for _, id := range changes.Folders {
+			keys = append(keys, folderKeys(id)...)
+			if path, ok := changes.PreviousFolderPaths[id]; ok {
+				keys = append(keys, folderIndexKey(passboltv1.PassboltFolderRef{Path: path}))
+			}
+		}
+		for _, tag := range changes.Tags {
+			keys = append(keys, tagIndexKey(tag))
+		}
+		return keys
+	}
+}
+
+// enqueueChangedResources returns a passbolt.ChangeHandler that enqueues all objects of the given list type
+// referencing one of the changed passbolt resources. The objects must be indexed by passboltResourceIDIndex
+// with one of the keys returned by indexKeys for the changes.
+func enqueueChangedResources(c client.Client, newList func() client.ObjectList, indexKeys func(changes passbolt.Changes) []string, changes chan<- event.GenericEvent) passbolt.ChangeHandler {
+	return func(detected passbolt.Changes) {
+		logr := log.Log.WithName("passbolt-changes")
+		ctx, cf := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cf()
+
+		enqueued := map[types.NamespacedName]bool{}
+		for _, id := range indexKeys(detected) {
+			list := newList()
+			if err := c.List(ctx, list, client.MatchingFields{passboltResourceIDIndex: id}); err != nil {
+				logr.Error(err, "failed to list objects referencing changed resource", "id", id)
+				continue
+			}
+			items, err := apimeta.ExtractList(list)
+			if err != nil {
+				logr.Error(err, "failed to extract objects referencing changed resource", "id", id)
+				continue
+			}
+			for _, item := range items {
+				obj, ok := item.(client.Object)
+				if !ok {
+					continue
+				}
+				key := client.ObjectKeyFromObject(obj)
+				if enqueued[key] {
+					continue
+				}
+				enqueued[key] = true
+				select {
+				case changes <- event.GenericEvent{Object: obj}:
+					logr.V(5).Info("enqueued object for changed resource", "name", key, "id", id)
+				default:
+					// the controller does not consume events (e.g. not the leader), the periodic re-sync will pick it up
+					logr.Info("dropped change event, queue is full", "name", key, "id", id)
+				}
+			}
+		}
+	}
+}
@@ -18,15 +18,25 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
 )
 
 var _ = Describe("Run Controller", func() {
@@ -124,3 +134,323 @@ var _ = Describe("Run Controller", func() {
 		})
 	})
 })
+
+func TestChangeIndexKeys(t *testing.T) {
+	// the resource and the folder were deleted, so the client does not know them anymore
+	got := changeIndexKeys(&passbolt.Client{})(passbolt.Changes{
+		Resources:           []string{"postgres"},
+		Deleted:             []string{"postgres"},
+		Folders:             []string{"db"},
+		PreviousPaths:       map[string]passbolt.ResourcePath{"postgres": {Name: "postgres", FolderPath: "apps/db/postgres"}},
+		PreviousFolderPaths: map[string]string{"db": "apps/db"},
+	})
+	want := []string{
+		"postgres",
+		passboltv1.PassboltSecretRef{Name: "postgres"}.Selector(),
+		passboltv1.PassboltSecretRef{FolderPath: "apps/db/postgres"}.Selector(),
+		folderIndexKey(passboltv1.PassboltFolderRef{ID: "db"}),
+		folderIndexKey(passboltv1.PassboltFolderRef{Path: "apps/db"}),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("changeIndexKeys() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIndexPassboltResourceIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{
+		{
+			name: "opaque secret",
+			obj: &passboltv1.PassboltSecret{
+				Spec: passboltv1.PassboltSecretSpec{
+					SecretType: corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+						"username": {ID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", Field: passboltv1.FieldNameUsername},
+					},
+				},
+			},
+			want: []string{"184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"},
+		},
+		{
+			name: "secret referenced by name and folder path",
+			obj: &passboltv1.PassboltSecret{
+				Spec: passboltv1.PassboltSecretSpec{
+					SecretType: corev1.SecretTypeOpaque,
+					PassboltSecrets: map[string]passboltv1.PassboltSecretRef{
+						"username": {Name: "postgres", Field: passboltv1.FieldNameUsername},
+						"password": {FolderPath: "/infra/prod/postgres", Field: passboltv1.FieldNamePassword},
+					},
+				},
+			},
+			want: []string{"folderPath:infra/prod/postgres", "name:postgres"},
+		},
+		{
+			name: "secret syncing a folder",
+			obj: &passboltv1.PassboltSecret{
+				Spec: passboltv1.PassboltSecretSpec{
+					SecretType: corev1.SecretTypeOpaque,
+					PassboltFolder: &passboltv1.PassboltFolderRef{
+						Path: "/infra/prod/",
+					},
+				},
+			},
+			want: []string{"folder:path:infra/prod"},
+		},
+		{
+			name: "secret selecting resources by tags",
+			obj: &passboltv1.PassboltSecret{
+				Spec: passboltv1.PassboltSecretSpec{
+					SecretType: corev1.SecretTypeOpaque,
+					TagSelector: &passboltv1.PassboltTagSelector{
+						Tags: []string{"k8s:payments", "prod"},
+					},
+				},
+			},
+			want: []string{"tag:k8s:payments", "tag:prod"},
+		},
+		{
+			name: "docker config json secret",
+			obj: &passboltv1.PassboltSecret{
+				Spec: passboltv1.PassboltSecretSpec{
+					SecretType:       corev1.SecretTypeDockerConfigJson,
+					PassboltSecretID: func() *string { s := "cec328ec-cb1f-48f6-be1e-1ca35fc3c62d"; return &s }(),
+				},
+			},
+			want: []string{"cec328ec-cb1f-48f6-be1e-1ca35fc3c62d"},
+		},
+		{
+			name: "no passbolt secret",
+			obj:  &corev1.Secret{},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := indexPassboltResourceIDs(tt.obj)
+			// references are stored in a map, so their order is not stable
+			if diff := cmp.Diff(tt.want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+				t.Errorf("indexPassboltResourceIDs() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPassboltClientCredentialsRef(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "passbolt", Namespace: "team-a"},
+			Data: map[string][]byte{
+				"privateKey": []byte("key"),
+			},
+		},
+	).Build()
+	defaultClient := &passbolt.Client{}
+
+	tests := []struct {
+		name       string
+		reconciler *PassboltSecretReconciler
+		spec       passboltv1.PassboltSecretSpec
+		want       *passbolt.Client
+		wantErr    bool
+	}{
+		{
+			name:       "default credentials",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient, Servers: passbolt.NewPool(0)},
+			want:       defaultClient,
+		},
+		{
+			name:       "credentials reference required",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient, Servers: passbolt.NewPool(0), RequireCredentialsRef: true},
+			wantErr:    true,
+		},
+		{
+			name:       "credentials references not supported without pool",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient},
+			spec:       passboltv1.PassboltSecretSpec{CredentialsRef: &passboltv1.PassboltCredentialsRef{Name: "passbolt"}},
+			wantErr:    true,
+		},
+		{
+			name:       "referenced server is not ready",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient, Servers: passbolt.NewPool(0)},
+			spec: passboltv1.PassboltSecretSpec{
+				ServerRef:      &passboltv1.PassboltServerRef{Name: "prod"},
+				CredentialsRef: &passboltv1.PassboltCredentialsRef{Name: "passbolt"},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "credentials secret in other namespace",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient, Servers: passbolt.NewPool(0)},
+			spec:       passboltv1.PassboltSecretSpec{CredentialsRef: &passboltv1.PassboltCredentialsRef{Name: "other"}},
+			wantErr:    true,
+		},
+		{
+			name:       "incomplete credentials",
+			reconciler: &PassboltSecretReconciler{Client: c, PassboltClient: defaultClient, Servers: passbolt.NewPool(0)},
+			spec:       passboltv1.PassboltSecretSpec{CredentialsRef: &passboltv1.PassboltCredentialsRef{Name: "passbolt"}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &passboltv1.PassboltSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+				Spec:       tt.spec,
+			}
+			got, err := tt.reconciler.passboltClient(context.Background(), secret)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("passboltClient() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("passboltClient() = %p, want %p", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetResourceNotFoundCondition(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	r := &PassboltSecretReconciler{Recorder: recorder}
+	secret := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a", Generation: 2},
+		Spec:       passboltv1.PassboltSecretSpec{OnMissing: passboltv1.OnMissingKeep},
+	}
+
+	if !r.setResourceNotFoundCondition(secret, []string{"184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"}) {
+		t.Fatal("setResourceNotFoundCondition() = false, want true")
+	}
+	condition := apimeta.FindStatusCondition(secret.Status.Conditions, passboltv1.ConditionTypeResourceNotFound)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.ObservedGeneration != 2 {
+		t.Fatalf("condition = %+v, want status True in generation 2", condition)
+	}
+	if diff := cmp.Diff([]string{
+		"Warning ResourceNotFound passbolt resource 184734ea-8be3-4f5a-ba6c-5f4b3c0603e8 does not exist, applying onMissing policy Keep",
+	}, drainEvents(recorder)); diff != "" {
+		t.Errorf("events mismatch (-want +got):\n%s", diff)
+	}
+
+	// the event is not recorded again while the resource is missing
+	if r.setResourceNotFoundCondition(secret, []string{"184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"}) {
+		t.Error("setResourceNotFoundCondition() = true, want false")
+	}
+	if events := drainEvents(recorder); len(events) != 0 {
+		t.Errorf("events = %v, want none", events)
+	}
+
+	if !r.setResourceNotFoundCondition(secret, nil) {
+		t.Fatal("setResourceNotFoundCondition() = false, want true")
+	}
+	if !apimeta.IsStatusConditionFalse(secret.Status.Conditions, passboltv1.ConditionTypeResourceNotFound) {
+		t.Errorf("conditions = %+v, want ResourceNotFound to be False", secret.Status.Conditions)
+	}
+}
+
+// drainEvents returns the events recorded by the fake recorder so far.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestSetSyncConditions(t *testing.T) {
+	errSync := errors.New("sync failed")
+	tests := []struct {
+		name     string
+		lastSync metav1.Time
+		missing  []string
+		outcome  syncOutcome
+		want     map[string]string
+	}{
+		{
+			name:    "synced",
+			outcome: syncOutcome{passboltContacted: true},
+			want: map[string]string{
+				passboltv1.ConditionTypeReady:             "True/" + passboltv1.ReasonSynced,
+				passboltv1.ConditionTypeSynced:            "True/" + passboltv1.ReasonSynced,
+				passboltv1.ConditionTypePassboltReachable: "True/" + passboltv1.ReasonReachable,
+				passboltv1.ConditionTypeDegraded:          "False/" + passboltv1.ReasonHealthy,
+			},
+		},
+		{
+			name:    "access denied before passbolt is called",
+			outcome: syncOutcome{reason: passboltv1.ReasonAccessDenied, err: errSync},
+			want: map[string]string{
+				passboltv1.ConditionTypeReady:    "False/" + passboltv1.ReasonAccessDenied,
+				passboltv1.ConditionTypeSynced:   "False/" + passboltv1.ReasonAccessDenied,
+				passboltv1.ConditionTypeDegraded: "False/" + passboltv1.ReasonHealthy,
+			},
+		},
+		{
+			name:     "passbolt unavailable serves stale data",
+			lastSync: metav1.Now(),
+			outcome:  syncOutcome{reason: passboltv1.ReasonPassboltUnavailable, err: errSync, passboltErr: errSync, passboltContacted: true},
+			want: map[string]string{
+				passboltv1.ConditionTypeReady:             "False/" + passboltv1.ReasonPassboltUnavailable,
+				passboltv1.ConditionTypeSynced:            "False/" + passboltv1.ReasonPassboltUnavailable,
+				passboltv1.ConditionTypePassboltReachable: "False/" + passboltv1.ReasonPassboltUnavailable,
+				passboltv1.ConditionTypeDegraded:          "True/" + passboltv1.ReasonStaleData,
+			},
+		},
+		{
+			name:    "missing resource is kept",
+			missing: []string{"184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"},
+			outcome: syncOutcome{passboltContacted: true},
+			want: map[string]string{
+				passboltv1.ConditionTypeReady:             "False/" + passboltv1.ReasonDegraded,
+				passboltv1.ConditionTypeSynced:            "True/" + passboltv1.ReasonSynced,
+				passboltv1.ConditionTypePassboltReachable: "True/" + passboltv1.ReasonReachable,
+				passboltv1.ConditionTypeDegraded:          "True/" + passboltv1.ReasonResourceMissing,
+			},
+		},
+		{
+			name:     "failed rollout",
+			lastSync: metav1.Now(),
+			outcome:  syncOutcome{passboltContacted: true, rolloutErr: errors.New("forbidden")},
+			want: map[string]string{
+				passboltv1.ConditionTypeReady:             "False/" + passboltv1.ReasonDegraded,
+				passboltv1.ConditionTypeSynced:            "True/" + passboltv1.ReasonSynced,
+				passboltv1.ConditionTypePassboltReachable: "True/" + passboltv1.ReasonReachable,
+				passboltv1.ConditionTypeDegraded:          "True/" + passboltv1.ReasonRolloutFailed,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &passboltv1.PassboltSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a", Generation: 3},
+				Status:     passboltv1.PassboltSecretStatus{LastSync: tt.lastSync},
+			}
+			r := &PassboltSecretReconciler{}
+			r.setResourceNotFoundCondition(secret, tt.missing)
+
+			if !setSyncConditions(secret, tt.outcome) {
+				t.Fatal("setSyncConditions() = false, want true")
+			}
+			if secret.Status.ObservedGeneration != 3 {
+				t.Errorf("observedGeneration = %d, want 3", secret.Status.ObservedGeneration)
+			}
+			got := map[string]string{}
+			for _, condition := range secret.Status.Conditions {
+				if condition.Type != passboltv1.ConditionTypeResourceNotFound {
+					got[condition.Type] = string(condition.Status) + "/" + condition.Reason
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("conditions mismatch (-want +got):\n%s", diff)
+			}
+			if setSyncConditions(secret, tt.outcome) {
+				t.Error("setSyncConditions() = true on the same outcome, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+const (
+	// credentialsSecretIndex is the field index of the credentials secret (namespace/name) referenced by a passbolt server or PassboltSecret.
+	credentialsSecretIndex = ".spec.credentialsSecretRef"
+)
+
+// PassboltServerReconciler reconciles a PassboltServer object
+type PassboltServerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Servers is the pool the clients of the passbolt servers are added to.
+	Servers *passbolt.Pool
+}
+
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=passboltservers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile logs in to the passbolt server and adds the client to the pool.
+// The client is removed from the pool once the PassboltServer is deleted.
+func (r *PassboltServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	key := passbolt.ServerKey{Kind: passboltv1.PassboltServerKind, Namespace: req.Namespace, Name: req.Name}
+	server := &passboltv1.PassboltServer{}
+	if err := r.Client.Get(ctx, req.NamespacedName, server); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Servers.Remove(ctx, key)
+			return ctrl.Result{}, nil
+		}
+		return errResult, err
+	}
+	return reconcileServer(ctx, r.Client, r.Servers, key, server, server.Namespace, server.Spec, &server.Status)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PassboltServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index PassboltServers by their credentials secret,
+	// so that we are able to log in again when the secret changes.
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.PassboltServer{}, credentialsSecretIndex, func(obj client.Object) []string {
+		server, ok := obj.(*passboltv1.PassboltServer)
+		if !ok {
+			return nil
+		}
+		return []string{types.NamespacedName{Namespace: server.Namespace, Name: server.Spec.CredentialsSecretRef.Name}.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&passboltv1.PassboltServer{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(findObjectsForCredentialsSecret(r.Client, &passboltv1.PassboltServerList{}))).
+		Complete(r)
+}
+
+// ClusterPassboltServerReconciler reconciles a ClusterPassboltServer object
+type ClusterPassboltServerReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Servers is the pool the clients of the passbolt servers are added to.
+	Servers *passbolt.Pool
+}
+
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=clusterpassboltservers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=passbolt.tagesspiegel.de,resources=clusterpassboltservers/status,verbs=get;update;patch
+
+// Reconcile logs in to the passbolt server and adds the client to the pool.
+// The client is removed from the pool once the ClusterPassboltServer is deleted.
+func (r *ClusterPassboltServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	key := passbolt.ServerKey{Kind: passboltv1.ClusterPassboltServerKind, Name: req.Name}
+	server := &passboltv1.ClusterPassboltServer{}
+	if err := r.Client.Get(ctx, req.NamespacedName, server); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Servers.Remove(ctx, key)
+			return ctrl.Result{}, nil
+		}
+		return errResult, err
+	}
+	return reconcileServer(ctx, r.Client, r.Servers, key, server, server.Spec.CredentialsSecretRef.Namespace, server.Spec, &server.Status)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterPassboltServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// index ClusterPassboltServers by their credentials secret,
+	// so that we are able to log in again when the secret changes.
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &passboltv1.ClusterPassboltServer{}, credentialsSecretIndex, func(obj client.Object) []string {
+		server, ok := obj.(*passboltv1.ClusterPassboltServer)
+		if !ok {
+			return nil
+		}
+		ref := server.Spec.CredentialsSecretRef
+		return []string{types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}.String()}
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&passboltv1.ClusterPassboltServer{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(findObjectsForCredentialsSecret(r.Client, &passboltv1.ClusterPassboltServerList{}))).
+		Complete(r)
+}
+
+// reconcileServer logs in to the passbolt server with the credentials of the referenced secret
+// and records the result in the status of the server object.
+// The credentials secret is looked up in secretNamespace.
+func reconcileServer(ctx context.Context, c client.Client, pool *passbolt.Pool, key passbolt.ServerKey, obj client.Object, secretNamespace string, spec passboltv1.PassboltServerSpec, status *passboltv1.PassboltServerStatus) (ctrl.Result, error) {
+	logr := log.FromContext(ctx)
+	logr.Info("starting reconciliation...", "server", key.String())
+	defer logr.Info("finished reconciliation", "server", key.String())
+
+	// cleanup status
+	status.SyncErrors = []passboltv1.SyncError{}
+
+	config, err := serverConfig(ctx, c, secretNamespace, spec)
+	if err == nil {
+		_, err = pool.Ensure(ctx, key, config)
+	}
+	if err != nil {
+		status.SyncStatus = passboltv1.SyncStatusError
+		status.SyncErrors = append(status.SyncErrors, passboltv1.SyncError{
+			Message: err.Error(),
+			Time:    metav1.Now(),
+		})
+		if err := c.Status().Update(ctx, obj); err != nil {
+			return errResult, err
+		}
+		return errResult, err
+	}
+
+	if status.SyncStatus == passboltv1.SyncStatusSuccess {
+		return ctrl.Result{}, nil
+	}
+	status.SyncStatus = passboltv1.SyncStatusSuccess
+	status.LastSync = metav1.Now()
+	if err := c.Status().Update(ctx, obj); err != nil {
+		return reconcile.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// serverConfig reads the credentials of the passbolt server from the referenced secret.
+func serverConfig(ctx context.Context, c client.Client, secretNamespace string, spec passboltv1.PassboltServerSpec) (passbolt.ServerConfig, error) {
+	ref := spec.CredentialsSecretRef
+	if secretNamespace == "" {
+		return passbolt.ServerConfig{}, fmt.Errorf("the namespace of the credentials secret %q is required", ref.Name)
+	}
+	return credentialsConfig(ctx, c, spec.URL, secretNamespace, ref.PassboltCredentialsRef)
+}
+
+// credentialsConfig reads the credentials of a passbolt user from the referenced secret in the given namespace.
+func credentialsConfig(ctx context.Context, c client.Client, url, namespace string, ref passboltv1.PassboltCredentialsRef) (passbolt.ServerConfig, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return passbolt.ServerConfig{}, fmt.Errorf("failed to get credentials secret %s/%s: %w", namespace, ref.Name, err)
+	}
+	privateKeyKey := ref.PrivateKeyKey
+	if privateKeyKey == "" {
+		privateKeyKey = passboltv1.DefaultPrivateKeyKey
+	}
+	passwordKey := ref.PasswordKey
+	if passwordKey == "" {
+		passwordKey = passboltv1.DefaultPasswordKey
+	}
+	config := passbolt.ServerConfig{URL: url}
+	for _, field := range []struct {
+		key    string
+		target *string
+	}{
+		{key: privateKeyKey, target: &config.PrivateKey},
+		{key: passwordKey, target: &config.Password},
+	} {
+		value, ok := secret.Data[field.key]
+		if !ok {
+			return passbolt.ServerConfig{}, fmt.Errorf("key %q does not exist in credentials secret %s/%s", field.key, namespace, ref.Name)
+		}
+		*field.target = string(value)
+	}
+	return config, nil
+}
+
+// findObjectsForCredentialsSecret returns a map function that returns the objects of the given list type
+// referencing the given secret as credentials secret.
+func findObjectsForCredentialsSecret(c client.Client, list client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		objs := list.DeepCopyObject().(client.ObjectList)
+		if err := c.List(ctx, objs, client.MatchingFields{credentialsSecretIndex: client.ObjectKeyFromObject(obj).String()}); err != nil {
+			log.FromContext(ctx).Error(err, "failed to list objects referencing credentials secret", "name", client.ObjectKeyFromObject(obj))
+			return nil
+		}
+		items, err := apimeta.ExtractList(objs)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			if o, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+			}
+		}
+		return requests
+	}
+}
+
+// serverKey returns the key of the passbolt server referenced by an object in the given namespace.
+func serverKey(ref passboltv1.PassboltServerRef, namespace string) passbolt.ServerKey {
+	if ref.Kind == passboltv1.PassboltServerKind {
+		return passbolt.ServerKey{Kind: passboltv1.PassboltServerKind, Namespace: namespace, Name: ref.Name}
+	}
+	return passbolt.ServerKey{Kind: passboltv1.ClusterPassboltServerKind, Name: ref.Name}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+func TestServerKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       passboltv1.PassboltServerRef
+		namespace string
+		want      passbolt.ServerKey
+	}{
+		{
+			name:      "cluster passbolt server",
+			ref:       passboltv1.PassboltServerRef{Kind: passboltv1.ClusterPassboltServerKind, Name: "prod"},
+			namespace: "default",
+			want:      passbolt.ServerKey{Kind: passboltv1.ClusterPassboltServerKind, Name: "prod"},
+		},
+		{
+			name:      "kind defaults to cluster passbolt server",
+			ref:       passboltv1.PassboltServerRef{Name: "prod"},
+			namespace: "default",
+			want:      passbolt.ServerKey{Kind: passboltv1.ClusterPassboltServerKind, Name: "prod"},
+		},
+		{
+			name:      "namespaced passbolt server",
+			ref:       passboltv1.PassboltServerRef{Kind: passboltv1.PassboltServerKind, Name: "staging"},
+			namespace: "team-a",
+			want:      passbolt.ServerKey{Kind: passboltv1.PassboltServerKind, Namespace: "team-a", Name: "staging"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, serverKey(tt.ref, tt.namespace)); diff != "" {
+				t.Errorf("serverKey() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "passbolt", Namespace: "default"},
+			Data: map[string][]byte{
+				"privateKey": []byte("key"),
+				"password":   []byte("secret"),
+				"gpg":        []byte("other-key"),
+			},
+		},
+	).Build()
+
+	tests := []struct {
+		name            string
+		secretNamespace string
+		spec            passboltv1.PassboltServerSpec
+		want            passbolt.ServerConfig
+		wantErr         bool
+	}{
+		{
+			name:            "default keys",
+			secretNamespace: "default",
+			spec: passboltv1.PassboltServerSpec{
+				URL:                  "https://passbolt.example.com",
+				CredentialsSecretRef: passboltv1.PassboltCredentialsSecretRef{PassboltCredentialsRef: passboltv1.PassboltCredentialsRef{Name: "passbolt"}},
+			},
+			want: passbolt.ServerConfig{URL: "https://passbolt.example.com", PrivateKey: "key", Password: "secret"},
+		},
+		{
+			name:            "custom keys",
+			secretNamespace: "default",
+			spec: passboltv1.PassboltServerSpec{
+				URL:                  "https://passbolt.example.com",
+				CredentialsSecretRef: passboltv1.PassboltCredentialsSecretRef{PassboltCredentialsRef: passboltv1.PassboltCredentialsRef{Name: "passbolt", PrivateKeyKey: "gpg"}},
+			},
+			want: passbolt.ServerConfig{URL: "https://passbolt.example.com", PrivateKey: "other-key", Password: "secret"},
+		},
+		{
+			name:            "missing key",
+			secretNamespace: "default",
+			spec: passboltv1.PassboltServerSpec{
+				URL:                  "https://passbolt.example.com",
+				CredentialsSecretRef: passboltv1.PassboltCredentialsSecretRef{PassboltCredentialsRef: passboltv1.PassboltCredentialsRef{Name: "passbolt", PasswordKey: "passphrase"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:            "missing secret",
+			secretNamespace: "other",
+			spec: passboltv1.PassboltServerSpec{
+				URL:                  "https://passbolt.example.com",
+				CredentialsSecretRef: passboltv1.PassboltCredentialsSecretRef{PassboltCredentialsRef: passboltv1.PassboltCredentialsRef{Name: "passbolt"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing namespace",
+			spec: passboltv1.PassboltServerSpec{
+				URL:                  "https://passbolt.example.com",
+				CredentialsSecretRef: passboltv1.PassboltCredentialsSecretRef{PassboltCredentialsRef: passboltv1.PassboltCredentialsRef{Name: "passbolt"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := serverConfig(context.Background(), c, tt.secretNamespace, tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("serverConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("serverConfig() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
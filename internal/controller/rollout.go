@@ -0,0 +1,191 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/util"
+)
+
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
+
+// rolloutNameHashLength is the number of bytes of the hash of the name of a PassboltSecret in the rollout annotation.
+const rolloutNameHashLength = 4
+
+// rolloutWorkload is a workload restarted when the data of a secret changes.
+type rolloutWorkload struct {
+	kind   string
+	object client.Object
+}
+
+// podTemplate returns the pod template of the workload.
+func (w rolloutWorkload) podTemplate() *corev1.PodTemplateSpec {
+	switch obj := w.object.(type) {
+	case *appsv1.Deployment:
+		return &obj.Spec.Template
+	case *appsv1.StatefulSet:
+		return &obj.Spec.Template
+	case *appsv1.DaemonSet:
+		return &obj.Spec.Template
+	}
+	return nil
+}
+
+// newRolloutObject returns an empty object of the given rollout target kind, or nil if the kind is not supported.
+func newRolloutObject(kind string) client.Object {
+	switch kind {
+	case passboltv1.RolloutTargetKindDeployment:
+		return &appsv1.Deployment{}
+	case passboltv1.RolloutTargetKindStatefulSet:
+		return &appsv1.StatefulSet{}
+	case passboltv1.RolloutTargetKindDaemonSet:
+		return &appsv1.DaemonSet{}
+	}
+	return nil
+}
+
+// rolloutAnnotationKey returns the annotation of the pod templates of the workloads restarted for the PassboltSecret.
+// A short hash of the name of the PassboltSecret is part of the key, so that a workload can be restarted for several
+// PassboltSecrets and the key is valid for names of any length.
+func rolloutAnnotationKey(annotation, name string) (string, error) {
+	sum := sha256.Sum256([]byte(name))
+	key := annotation + "-" + hex.EncodeToString(sum[:rolloutNameHashLength])
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return "", fmt.Errorf("invalid rollout annotation %q: %s", key, strings.Join(errs, ", "))
+	}
+	return key, nil
+}
+
+// ValidateRolloutAnnotation returns an error if the annotations of the workloads restarted with the given prefix are invalid.
+func ValidateRolloutAnnotation(annotation string) error {
+	_, err := rolloutAnnotationKey(annotation, "")
+	return err
+}
+
+// dataHash returns the hash of the given data of a secret published in the status of the PassboltSecret,
+// or an empty string if no key is configured. The values of the given expiring keys are left out.
+func (r *PassboltSecretReconciler) dataHash(data map[string][]byte, expiringKeys []string) string {
+	if len(r.DataHashKey) == 0 {
+		return ""
+	}
+	return util.DataHash(r.DataHashKey, data, expiringKeys)
+}
+
+// rollout restarts the workloads of the PassboltSecret by patching the hash of the data of the secret into the annotations
+// of their pod templates. The workloads are only restarted if the data changed since the last successful sync.
+func (r *PassboltSecretReconciler) rollout(ctx context.Context, secret *passboltv1.PassboltSecret, dataHash string) error {
+	// the first sync does not replace data the workloads were started with
+	if r.RolloutAnnotation == "" || dataHash == "" || secret.Status.DataHash == "" || secret.Status.DataHash == dataHash {
+		return nil
+	}
+	workloads, err := r.rolloutWorkloads(ctx, secret)
+	if err != nil || len(workloads) == 0 {
+		return err
+	}
+	key, err := rolloutAnnotationKey(r.RolloutAnnotation, secret.Name)
+	if err != nil {
+		return err
+	}
+	for _, workload := range workloads {
+		template := workload.podTemplate()
+		// the workload was already restarted by a previous attempt
+		if template.Annotations[key] == dataHash {
+			continue
+		}
+		patch := client.MergeFrom(workload.object.DeepCopyObject().(client.Object))
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[key] = dataHash
+		if err := r.Client.Patch(ctx, workload.object, patch); err != nil {
+			return fmt.Errorf("failed to restart %s %s: %w", workload.kind, workload.object.GetName(), err)
+		}
+		log.FromContext(ctx).Info("restarted workload, since the data of the secret changed", "kind", workload.kind, "workload", workload.object.GetName())
+		if r.Recorder != nil {
+			r.Recorder.Eventf(secret, corev1.EventTypeNormal, eventReasonRolledOut, "restarted %s %s, since the data of secret %s changed",
+				workload.kind, workload.object.GetName(), secret.Name)
+		}
+	}
+	return nil
+}
+
+// rolloutWorkloads returns the workloads labeled with the name of the PassboltSecret and the rollout targets of the PassboltSecret.
+// Rollout targets that do not exist are skipped.
+func (r *PassboltSecretReconciler) rolloutWorkloads(ctx context.Context, secret *passboltv1.PassboltSecret) ([]rolloutWorkload, error) {
+	workloads := []rolloutWorkload{}
+	seen := map[string]bool{}
+	add := func(kind string, obj client.Object) {
+		key := kind + "/" + obj.GetName()
+		if !seen[key] {
+			seen[key] = true
+			workloads = append(workloads, rolloutWorkload{kind: kind, object: obj})
+		}
+	}
+
+	opts := []client.ListOption{client.InNamespace(secret.Namespace), client.MatchingLabels{passboltv1.RolloutLabel: secret.Name}}
+	deployments := &appsv1.DeploymentList{}
+	if err := r.Client.List(ctx, deployments, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		add(passboltv1.RolloutTargetKindDeployment, &deployments.Items[i])
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.Client.List(ctx, statefulSets, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		add(passboltv1.RolloutTargetKindStatefulSet, &statefulSets.Items[i])
+	}
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.Client.List(ctx, daemonSets, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for i := range daemonSets.Items {
+		add(passboltv1.RolloutTargetKindDaemonSet, &daemonSets.Items[i])
+	}
+
+	for _, target := range secret.Spec.RolloutTargets {
+		obj := newRolloutObject(target.Kind)
+		if obj == nil {
+			return nil, fmt.Errorf("unsupported rollout target kind %q", target.Kind)
+		}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: target.Name}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				log.FromContext(ctx).Info("skipping rollout target, since it does not exist", "kind", target.Kind, "workload", target.Name)
+				continue
+			}
+			return nil, fmt.Errorf("failed to get %s %s: %w", target.Kind, target.Name, err)
+		}
+		add(target.Kind, obj)
+	}
+	return workloads, nil
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+func TestRollout(t *testing.T) {
+	annotation, err := rolloutAnnotationKey("passbolt.tagesspiegel.de/data-hash", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labeled := map[string]string{passboltv1.RolloutLabel: "app"}
+	c := fake.NewClientBuilder().WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-a", Labels: labeled}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "team-b", Labels: labeled}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team-a"}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team-a"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "team-a", Labels: labeled}},
+	).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PassboltSecretReconciler{Client: c, Recorder: recorder, RolloutAnnotation: "passbolt.tagesspiegel.de/data-hash"}
+	secret := &passboltv1.PassboltSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		Spec: passboltv1.PassboltSecretSpec{
+			RolloutTargets: []passboltv1.RolloutTarget{
+				{Kind: passboltv1.RolloutTargetKindStatefulSet, Name: "db"},
+				{Kind: passboltv1.RolloutTargetKindDaemonSet, Name: "agent"},
+				{Kind: passboltv1.RolloutTargetKindDeployment, Name: "missing"},
+			},
+		},
+	}
+	// hash returns the rollout annotation of the pod template of the given workload.
+	hash := func(obj client.Object, name, namespace string) string {
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, obj); err != nil {
+			t.Fatalf("failed to get %s: %v", name, err)
+		}
+		return rolloutWorkload{object: obj}.podTemplate().Annotations[annotation]
+	}
+
+	// the first sync does not restart the workloads
+	if err := r.rollout(context.Background(), secret, "first"); err != nil {
+		t.Fatalf("rollout() error = %v", err)
+	}
+	if got := hash(&appsv1.Deployment{}, "web", "team-a"); got != "" {
+		t.Errorf("deployment annotation = %q after the first sync, want none", got)
+	}
+
+	secret.Status.DataHash = "first"
+	if err := r.rollout(context.Background(), secret, "second"); err != nil {
+		t.Fatalf("rollout() error = %v", err)
+	}
+	got := map[string]string{
+		"deployment team-a/web":   hash(&appsv1.Deployment{}, "web", "team-a"),
+		"deployment team-b/web":   hash(&appsv1.Deployment{}, "web", "team-b"),
+		"deployment team-a/other": hash(&appsv1.Deployment{}, "other", "team-a"),
+		"statefulset team-a/db":   hash(&appsv1.StatefulSet{}, "db", "team-a"),
+		"daemonset team-a/agent":  hash(&appsv1.DaemonSet{}, "agent", "team-a"),
+	}
+	want := map[string]string{
+		"deployment team-a/web":   "second",
+		"deployment team-b/web":   "",
+		"deployment team-a/other": "",
+		"statefulset team-a/db":   "second",
+		"daemonset team-a/agent":  "second",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("annotations mismatch (-want +got):\n%s", diff)
+	}
+	if events := drainEvents(recorder); len(events) != 3 {
+		t.Errorf("events = %v, want one per restarted workload", events)
+	}
+
+	// an unchanged hash does not restart the workloads again
+	secret.Status.DataHash = "second"
+	if err := r.rollout(context.Background(), secret, "second"); err != nil {
+		t.Fatalf("rollout() error = %v", err)
+	}
+	if events := drainEvents(recorder); len(events) != 0 {
+		t.Errorf("events = %v, want none", events)
+	}
+}
+
+func TestRolloutAnnotationKey(t *testing.T) {
+	key, err := rolloutAnnotationKey("passbolt.tagesspiegel.de/data-hash", "app")
+	if err != nil || key != "passbolt.tagesspiegel.de/data-hash-a172cedc" {
+		t.Errorf("rolloutAnnotationKey() = %q, %v, want passbolt.tagesspiegel.de/data-hash-a172cedc", key, err)
+	}
+	// the name of an annotation is limited to 63 characters, which does not limit the names of PassboltSecrets
+	long, err := rolloutAnnotationKey("passbolt.tagesspiegel.de/data-hash", strings.Repeat("a-very-long-name-", 15))
+	if err != nil {
+		t.Errorf("rolloutAnnotationKey() error = %v for a long name", err)
+	}
+	if long == key {
+		t.Errorf("rolloutAnnotationKey() = %q for different names", long)
+	}
+	if err := ValidateRolloutAnnotation("passbolt.tagesspiegel.de/" + strings.Repeat("a", 60)); err == nil {
+		t.Error("ValidateRolloutAnnotation() error = nil, want an error for a too long annotation")
+	}
+}
+
+func TestRolloutWithoutDataHashKey(t *testing.T) {
+	r := &PassboltSecretReconciler{RolloutAnnotation: "passbolt.tagesspiegel.de/data-hash"}
+	if got := r.dataHash(map[string][]byte{"password": []byte("secret")}, nil); got != "" {
+		t.Errorf("dataHash() = %q without a key, want none", got)
+	}
+	r.DataHashKey = []byte("operator-key")
+	if got := r.dataHash(map[string][]byte{"password": []byte("secret")}, nil); got == "" {
+		t.Error("dataHash() = \"\" with a key, want a hash")
+	}
+}
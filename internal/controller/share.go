@@ -0,0 +1,47 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+// passboltShares converts the shares of the API to the shares of the passbolt client.
+func passboltShares(shares []passboltv1.PassboltShare) []passbolt.Share {
+	result := make([]passbolt.Share, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, passbolt.Share{
+			User:       share.User,
+			Group:      share.Group,
+			Permission: passboltPermission(share.Permission),
+		})
+	}
+	return result
+}
+
+// passboltPermission converts the permission of the API to the permission type of passbolt.
+func passboltPermission(permission passboltv1.PassboltPermission) int {
+	switch permission {
+	case passboltv1.PassboltPermissionOwner:
+		return passbolt.PermissionOwner
+	case passboltv1.PassboltPermissionUpdate:
+		return passbolt.PermissionUpdate
+	default:
+		return passbolt.PermissionRead
+	}
+}
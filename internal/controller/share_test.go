@@ -0,0 +1,42 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+)
+
+func TestPassboltShares(t *testing.T) {
+	shares := []passboltv1.PassboltShare{
+		{Group: "developers"},
+		{User: "alice@example.com", Permission: passboltv1.PassboltPermissionUpdate},
+		{Group: "admins", Permission: passboltv1.PassboltPermissionOwner},
+	}
+	want := []passbolt.Share{
+		{Group: "developers", Permission: passbolt.PermissionRead},
+		{User: "alice@example.com", Permission: passbolt.PermissionUpdate},
+		{Group: "admins", Permission: passbolt.PermissionOwner},
+	}
+	if diff := cmp.Diff(want, passboltShares(shares)); diff != "" {
+		t.Errorf("passboltShares() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -227,7 +227,7 @@ var _ = BeforeSuite(func() {
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
-	if err = (&passboltv1.PassboltSecret{}).SetupWebhookWithManager(k8sManager); err != nil {
+	if err = (&passboltv1.PassboltSecret{}).SetupWebhookWithManager(k8sManager, &PolicyChecker{Client: k8sManager.GetClient(), PassboltClient: passboltClient}); err != nil {
 		Expect(err).ToNot(HaveOccurred(), "unable to create webhook", "webhook", "PassboltSecret")
 	}
 
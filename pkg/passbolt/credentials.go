@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// ReadCredentials reads the armored private key and the passphrase of a passbolt user from the given files.
+// Trailing line breaks of the passphrase are removed.
+func ReadCredentials(privateKeyFile, passwordFile string) (privateKey, password string, err error) {
+	rawKey, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	rawPassword, err := os.ReadFile(passwordFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(rawKey), strings.TrimRight(string(rawPassword), "\r\n"), nil
+}
+
+// credentialsUpdateTimeout is the maximum duration of logging in with changed credentials,
+// so that an unresponsive passbolt server does not block the watcher.
+const credentialsUpdateTimeout = 30 * time.Second
+
+// CredentialsWatcher updates the credentials of a client when the files containing them change,
+// e.g. when the Kubernetes secret mounted as files is updated.
+// The files are polled, because Kubernetes replaces mounted secrets by swapping symbolic links.
+type CredentialsWatcher struct {
+	// update is called with the changed credentials.
+	update func(ctx context.Context, privateKey, password string) error
+	// privateKeyFile and passwordFile are the files the credentials are read from.
+	privateKeyFile string
+	passwordFile   string
+	// interval is the interval in which the files are checked for changes.
+	interval time.Duration
+	// privateKey and password are the credentials that are currently used.
+	privateKey string
+	password   string
+}
+
+var _ manager.Runnable = &CredentialsWatcher{}
+var _ manager.LeaderElectionRunnable = &CredentialsWatcher{}
+
+// NewCredentialsWatcher creates a watcher that updates the credentials of the client in the given interval,
+// if the files changed since the client was logged in with the given credentials.
+func NewCredentialsWatcher(clnt *Client, privateKeyFile, passwordFile string, interval time.Duration, privateKey, password string) *CredentialsWatcher {
+	return &CredentialsWatcher{
+		update:         clnt.UpdateCredentials,
+		privateKeyFile: privateKeyFile,
+		passwordFile:   passwordFile,
+		interval:       interval,
+		privateKey:     privateKey,
+		password:       password,
+	}
+}
+
+// Start checks the files for changes until the context is canceled.
+func (w *CredentialsWatcher) Start(ctx context.Context) error {
+	logr := log.FromContext(ctx).WithName("credentials-watcher")
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := w.reload(ctx)
+			if err != nil {
+				// keep the previous credentials, the files may be in the middle of an update
+				logr.Error(err, "failed to reload passbolt credentials")
+				continue
+			}
+			if changed {
+				logr.Info("reloaded passbolt credentials")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The credentials are updated on all replicas, since the webhooks use the client as well.
+func (w *CredentialsWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// reload updates the credentials if the files changed.
+// It returns true if the credentials were updated.
+func (w *CredentialsWatcher) reload(ctx context.Context) (bool, error) {
+	privateKey, password, err := ReadCredentials(w.privateKeyFile, w.passwordFile)
+	if err != nil {
+		return false, err
+	}
+	if privateKey == w.privateKey && password == w.password {
+		return false, nil
+	}
+	updateCtx, cancel := context.WithTimeout(ctx, credentialsUpdateTimeout)
+	defer cancel()
+	if err := w.update(updateCtx, privateKey, password); err != nil {
+		return false, err
+	}
+	w.privateKey = privateKey
+	w.password = password
+	return true, nil
+}
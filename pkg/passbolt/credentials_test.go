@@ -0,0 +1,119 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialsWatcher_reload(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "privateKey")
+	passwordFile := filepath.Join(dir, "password")
+	write := func(key, password string) {
+		t.Helper()
+		if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(passwordFile, []byte(password), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	updates := 0
+	var updateErr error
+	w := &CredentialsWatcher{
+		update: func(ctx context.Context, privateKey, password string) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("update() called without a deadline")
+			}
+			if updateErr != nil {
+				return updateErr
+			}
+			updates++
+			return nil
+		},
+		privateKeyFile: keyFile,
+		passwordFile:   passwordFile,
+		privateKey:     "key",
+		password:       "secret",
+	}
+
+	steps := []struct {
+		name        string
+		key         string
+		password    string
+		updateErr   error
+		wantChanged bool
+		wantErr     bool
+		wantUpdates int
+	}{
+		{
+			name:        "unchanged credentials",
+			key:         "key",
+			password:    "secret\n",
+			wantUpdates: 0,
+		},
+		{
+			name:        "failed login keeps previous credentials",
+			key:         "rotated-key",
+			password:    "rotated-secret",
+			updateErr:   errors.New("login failed"),
+			wantErr:     true,
+			wantUpdates: 0,
+		},
+		{
+			name:        "rotated credentials",
+			key:         "rotated-key",
+			password:    "rotated-secret",
+			wantChanged: true,
+			wantUpdates: 1,
+		},
+		{
+			name:        "rotated credentials are only updated once",
+			key:         "rotated-key",
+			password:    "rotated-secret",
+			wantUpdates: 1,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			write(step.key, step.password)
+			updateErr = step.updateErr
+			changed, err := w.reload(context.Background())
+			if (err != nil) != step.wantErr {
+				t.Errorf("reload() error = %v, wantErr %v", err, step.wantErr)
+			}
+			if changed != step.wantChanged {
+				t.Errorf("reload() = %v, want %v", changed, step.wantChanged)
+			}
+			if updates != step.wantUpdates {
+				t.Errorf("reload() updates = %d, want %d", updates, step.wantUpdates)
+			}
+		})
+	}
+}
+
+func TestReadCredentials_missingFile(t *testing.T) {
+	if _, _, err := ReadCredentials(filepath.Join(t.TempDir(), "privateKey"), filepath.Join(t.TempDir(), "password")); err == nil {
+		t.Error("ReadCredentials() error = nil, want error")
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// HealthThresholds are the thresholds of the liveness check of a client.
+// The readiness is checked with CacheRefresher.Checker, which only uses the state of the last cache refresh.
+type HealthThresholds struct {
+	// MaxCallAge is the maximum time since the last successful call to the passbolt API before the client is not alive.
+	// 0 disables the liveness check.
+	MaxCallAge time.Duration
+}
+
+// LivenessChecker returns a liveness check that fails if no call to the passbolt API succeeded within MaxCallAge.
+func (c *Client) LivenessChecker(thresholds HealthThresholds) healthz.Checker {
+	return func(_ *http.Request) error {
+		if thresholds.MaxCallAge <= 0 {
+			return nil
+		}
+		if age := time.Since(c.LastSuccessfulCall()); age > thresholds.MaxCallAge {
+			return fmt.Errorf("no successful call to passbolt for %s", age.Round(time.Second))
+		}
+		return nil
+	}
+}
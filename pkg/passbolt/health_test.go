@@ -0,0 +1,56 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClient_LivenessChecker(t *testing.T) {
+	tests := []struct {
+		name     string
+		lastCall time.Time
+		maxAge   time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "recent call",
+			lastCall: time.Now().Add(-time.Minute),
+			maxAge:   time.Hour,
+		},
+		{
+			name:     "no recent call",
+			lastCall: time.Now().Add(-2 * time.Hour),
+			maxAge:   time.Hour,
+			wantErr:  true,
+		},
+		{
+			name:     "disabled",
+			lastCall: time.Now().Add(-2 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{}
+			c.lastSuccessfulCall.Store(tt.lastCall.UnixNano())
+			if err := c.LivenessChecker(HealthThresholds{MaxCallAge: tt.maxAge})(nil); (err != nil) != tt.wantErr {
+				t.Errorf("LivenessChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	passboltThrottledCallsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_throttled_calls_total",
+			Help: "Number of calls to passbolt that had to wait for the rate or concurrency limit.",
+		},
+	)
+	passboltThrottleWaitSeconds = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "passbolt_throttle_wait_seconds",
+			Help:    "Time calls to passbolt waited for the rate or concurrency limit.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		},
+	)
+	passboltCallsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "passbolt_calls_in_flight",
+			Help: "Number of calls to passbolt that are currently in flight.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		passboltThrottledCallsTotal,
+		passboltThrottleWaitSeconds,
+		passboltCallsInFlight,
+	)
+}
+
+// Limits restricts the calls of a client to the passbolt API.
+// A call may consist of multiple requests, e.g. retrieving and decrypting a resource.
+type Limits struct {
+	// QPS is the number of calls per second. 0 disables the rate limit.
+	QPS float64
+	// Burst is the number of calls that may exceed QPS at once.
+	Burst int
+	// MaxInFlight is the maximum number of concurrent calls. 0 disables the concurrency limit.
+	MaxInFlight int
+}
+
+// limiter enforces Limits.
+type limiter struct {
+	// rate is the token bucket of the calls, nil if the rate is not limited.
+	rate *rate.Limiter
+	// inFlight contains an element for every call in flight, nil if the concurrency is not limited.
+	inFlight chan struct{}
+}
+
+// newLimiter creates a limiter enforcing the given limits.
+func newLimiter(limits Limits) *limiter {
+	l := &limiter{}
+	if limits.QPS > 0 {
+		burst := limits.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = rate.NewLimiter(rate.Limit(limits.QPS), burst)
+	}
+	if limits.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, limits.MaxInFlight)
+	}
+	return l
+}
+
+// acquire waits until a call is allowed by the limits.
+// The returned function must be called once the call finished.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	throttled := false
+	if l.rate != nil {
+		reservation := l.rate.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			throttled = true
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				reservation.Cancel()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			throttled = true
+			select {
+			case l.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+	if throttled {
+		passboltThrottledCallsTotal.Inc()
+		passboltThrottleWaitSeconds.Observe(time.Since(start).Seconds())
+	}
+	passboltCallsInFlight.Inc()
+	return func() {
+		passboltCallsInFlight.Dec()
+		if l.inFlight != nil {
+			<-l.inFlight
+		}
+	}, nil
+}
+
+// SetLimits restricts the calls of the client to the passbolt API.
+// Calls that are already waiting for the previous limits are not affected.
+func (c *Client) SetLimits(limits Limits) {
+	c.limiter.Store(newLimiter(limits))
+}
+
+// acquire waits until a call is allowed by the limits of the client, see limiter.acquire.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	l := c.limiter.Load()
+	if l == nil {
+		passboltCallsInFlight.Inc()
+		return passboltCallsInFlight.Dec, nil
+	}
+	return l.acquire(ctx)
+}
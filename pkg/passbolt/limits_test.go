@@ -0,0 +1,69 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_acquire(t *testing.T) {
+	t.Run("max in flight", func(t *testing.T) {
+		l := newLimiter(Limits{MaxInFlight: 2})
+		first, err := l.acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		// the third call has to wait until a call finished
+		ctx, cf := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cf()
+		if _, err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("acquire() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		first()
+		if _, err := l.acquire(context.Background()); err != nil {
+			t.Errorf("acquire() error = %v, want nil", err)
+		}
+	})
+	t.Run("rate", func(t *testing.T) {
+		l := newLimiter(Limits{QPS: 1, Burst: 2})
+		for i := 0; i < 2; i++ {
+			if _, err := l.acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the burst is exhausted, the next call is allowed in a second
+		ctx, cf := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cf()
+		if _, err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("acquire() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		l := newLimiter(Limits{})
+		for i := 0; i < 100; i++ {
+			if _, err := l.acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrResourceNotFound is returned if no resource matches a lookup.
+	ErrResourceNotFound = errors.New("resource not found")
+	// ErrAmbiguousResource is returned if more than one resource matches a lookup.
+	ErrAmbiguousResource = errors.New("resource is ambiguous")
+	// ErrFolderNotFound is returned if no folder matches a lookup.
+	ErrFolderNotFound = errors.New("folder not found")
+	// ErrAmbiguousFolder is returned if more than one folder matches a lookup.
+	ErrAmbiguousFolder = errors.New("folder is ambiguous")
+)
+
+// cachedResource is the cached information of a resource required for lookups.
+type cachedResource struct {
+	Name           string
+	FolderParentID string
+	// Tags are the sorted slugs of the tags of the resource.
+	Tags []string
+}
+
+// cachedFolder is the cached information of a folder required to build folder paths.
+type cachedFolder struct {
+	Name           string
+	FolderParentID string
+}
+
+// ResolveResourceID returns the ID of the resource with the given name.
+// If folderPath is set, name must be empty and the last element of the path is used as resource name,
+// e.g. infra/prod/postgres is the resource postgres in the folder prod inside the root folder infra.
+// ErrResourceNotFound and ErrAmbiguousResource are returned if none or more than one resource matches.
+func (c *Client) ResolveResourceID(name, folderPath string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return resolveResourceID(c.resourceCache, c.folderCache, name, folderPath)
+}
+
+// ResourcePaths returns the name and the folder path of the resource with the given ID as seen during the last cache sync.
+func (c *Client) ResourcePaths(id string) (name, folderPath string, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.resourceCache[id]
+	if !ok {
+		return "", "", false
+	}
+	return res.Name, joinPath(folderNames(c.folderCache, res.FolderParentID), res.Name), true
+}
+
+// ResolveFolderID returns the ID of the folder with the given ID or path, e.g. infra/prod is the folder prod inside the root folder infra.
+// ErrFolderNotFound and ErrAmbiguousFolder are returned if none or more than one folder matches.
+func (c *Client) ResolveFolderID(id, path string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return resolveFolderID(c.folderCache, id, path)
+}
+
+// FolderPath returns the path of the folder with the given ID as seen during the last cache sync.
+func (c *Client) FolderPath(id string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.folderCache[id]; !ok {
+		return "", false
+	}
+	return strings.Join(folderNames(c.folderCache, id), "/"), true
+}
+
+// FolderParent returns the ID of the parent folder of the folder with the given ID as seen during the last cache sync.
+// An empty ID is returned for folders in the root folder.
+func (c *Client) FolderParent(id string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	folder, ok := c.folderCache[id]
+	return folder.FolderParentID, ok
+}
+
+// FolderResources returns the sorted IDs of all resources that are directly inside the folder with the given ID
+// as seen during the last cache sync. Resources of sub folders are not included.
+func (c *Client) FolderResources(id string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return folderResources(c.resourceCache, id)
+}
+
+// ResourceFolder returns the ID of the folder the resource with the given ID is in as seen during the last cache sync.
+// An empty ID is returned for resources in the root folder.
+func (c *Client) ResourceFolder(id string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.resourceCache[id]
+	return res.FolderParentID, ok
+}
+
+// TaggedResources returns the sorted IDs of all resources that carry all of the given tags as seen during the last cache sync.
+func (c *Client) TaggedResources(tags []string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return taggedResources(c.tagCache, tags)
+}
+
+// ResourceTags returns the sorted slugs of the tags of the resource with the given ID as seen during the last cache sync.
+func (c *Client) ResourceTags(id string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.resourceCache[id].Tags)
+}
+
+func resolveResourceID(resources map[string]cachedResource, folders map[string]cachedFolder, name, folderPath string) (string, error) {
+	var selector string
+	var folder []string
+	switch {
+	case name != "" && folderPath != "":
+		return "", fmt.Errorf("name and folder path are mutually exclusive")
+	case name != "":
+		selector = name
+	case folderPath != "":
+		selector = folderPath
+		elems := splitPath(folderPath)
+		if len(elems) == 0 {
+			return "", fmt.Errorf("invalid folder path %q", folderPath)
+		}
+		name = elems[len(elems)-1]
+		folder = elems[:len(elems)-1]
+	default:
+		return "", fmt.Errorf("either name or folder path is required")
+	}
+
+	matches := []string{}
+	for id, res := range resources {
+		if res.Name != name {
+			continue
+		}
+		if folderPath != "" && !slices.Equal(folderNames(folders, res.FolderParentID), folder) {
+			continue
+		}
+		matches = append(matches, id)
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", ErrResourceNotFound, selector)
+	case 1:
+		return matches[0], nil
+	default:
+		slices.Sort(matches)
+		return "", fmt.Errorf("%w: %q matches the resources %s", ErrAmbiguousResource, selector, strings.Join(matches, ", "))
+	}
+}
+
+// folderNames returns the names of all folders from the root folder to the folder with the given ID.
+// Folders that are not accessible end the path.
+func folderNames(folders map[string]cachedFolder, id string) []string {
+	names := []string{}
+	// the depth is limited to protect against cycles
+	for i := 0; id != "" && i < len(folders); i++ {
+		folder, ok := folders[id]
+		if !ok {
+			break
+		}
+		names = append(names, folder.Name)
+		id = folder.FolderParentID
+	}
+	slices.Reverse(names)
+	return names
+}
+
+// splitPath splits a folder path into its elements, ignoring leading, trailing and duplicate slashes.
+func splitPath(path string) []string {
+	elems := []string{}
+	for _, elem := range strings.Split(path, "/") {
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
+// joinPath joins folder names and a resource name to a folder path.
+func joinPath(folder []string, name string) string {
+	return strings.Join(append(slices.Clone(folder), name), "/")
+}
+
+func resolveFolderID(folders map[string]cachedFolder, id, path string) (string, error) {
+	switch {
+	case id != "" && path != "":
+		return "", fmt.Errorf("folder ID and path are mutually exclusive")
+	case id != "":
+		if _, ok := folders[id]; !ok {
+			return "", fmt.Errorf("%w: %q", ErrFolderNotFound, id)
+		}
+		return id, nil
+	case path == "":
+		return "", fmt.Errorf("either folder ID or path is required")
+	}
+
+	elems := splitPath(path)
+	if len(elems) == 0 {
+		return "", fmt.Errorf("invalid folder path %q", path)
+	}
+	matches := []string{}
+	for folderID := range folders {
+		if slices.Equal(folderNames(folders, folderID), elems) {
+			matches = append(matches, folderID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", ErrFolderNotFound, path)
+	case 1:
+		return matches[0], nil
+	default:
+		slices.Sort(matches)
+		return "", fmt.Errorf("%w: %q matches the folders %s", ErrAmbiguousFolder, path, strings.Join(matches, ", "))
+	}
+}
+
+func folderResources(resources map[string]cachedResource, folderID string) []string {
+	ids := []string{}
+	for id, res := range resources {
+		if res.FolderParentID == folderID {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// changedResources returns the resources that were modified, renamed, moved or deleted between two cache syncs.
+// Resources seen for the first time are not reported, because no PassboltSecret can have been synced with an older version of them.
+func changedResources(previous map[string]cachedResource, previousModified map[string]time.Time, current map[string]cachedResource, currentModified map[string]time.Time) Changes {
+	changes := Changes{
+		Resources: []string{},
+		Deleted:   []string{},
+		Renamed:   []string{},
+	}
+	for id, prev := range previous {
+		res, ok := current[id]
+		switch {
+		case !ok:
+			changes.Deleted = append(changes.Deleted, id)
+		case prev.Name != res.Name:
+			changes.Renamed = append(changes.Renamed, id)
+		case prev.FolderParentID != res.FolderParentID:
+			// moved resources are found by another folder path
+		default:
+			modified, ok := currentModified[id]
+			if prevModified, prevOk := previousModified[id]; !ok || !prevOk || modified.Equal(prevModified) {
+				continue
+			}
+		}
+		changes.Resources = append(changes.Resources, id)
+	}
+	slices.Sort(changes.Resources)
+	slices.Sort(changes.Deleted)
+	slices.Sort(changes.Renamed)
+	return changes
+}
+
+// previousPaths returns the names and folder paths of the changed resources and the paths of the changed folders
+// in the given caches of the previous cache sync. Resources and folders that did not exist before are left out.
+func previousPaths(resources map[string]cachedResource, folders map[string]cachedFolder, changes Changes) (map[string]ResourcePath, map[string]string) {
+	resourcePaths := make(map[string]ResourcePath, len(changes.Resources))
+	for _, id := range changes.Resources {
+		res, ok := resources[id]
+		if !ok {
+			continue
+		}
+		resourcePaths[id] = ResourcePath{
+			Name:       res.Name,
+			FolderPath: joinPath(folderNames(folders, res.FolderParentID), res.Name),
+		}
+	}
+	folderPaths := make(map[string]string, len(changes.Folders))
+	for _, id := range changes.Folders {
+		if _, ok := folders[id]; ok {
+			folderPaths[id] = strings.Join(folderNames(folders, id), "/")
+		}
+	}
+	return resourcePaths, folderPaths
+}
+
+// changedFolders returns the sorted IDs of all folders whose resources were added, removed or moved between two cache syncs.
+// The root folder is not reported.
+func changedFolders(previous, current map[string]cachedResource) []string {
+	folders := map[string]bool{}
+	for id, res := range current {
+		prev, ok := previous[id]
+		if ok && prev.FolderParentID == res.FolderParentID {
+			continue
+		}
+		folders[res.FolderParentID] = true
+		if ok {
+			folders[prev.FolderParentID] = true
+		}
+	}
+	for id, prev := range previous {
+		if _, ok := current[id]; !ok {
+			folders[prev.FolderParentID] = true
+		}
+	}
+	delete(folders, "")
+	ids := make([]string, 0, len(folders))
+	for id := range folders {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func taggedResources(tagIndex map[string][]string, tags []string) []string {
+	if len(tags) == 0 {
+		return []string{}
+	}
+	ids := slices.Clone(tagIndex[tags[0]])
+	for _, tag := range tags[1:] {
+		tagged := tagIndex[tag]
+		ids = slices.DeleteFunc(ids, func(id string) bool {
+			_, found := slices.BinarySearch(tagged, id)
+			return !found
+		})
+	}
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// changedTags returns the sorted slugs of all tags that were added to or removed from resources between two cache syncs.
+// The tags of added and deleted resources are reported as well.
+func changedTags(previous, current map[string]cachedResource) []string {
+	tags := map[string]bool{}
+	for id, res := range current {
+		prev := previous[id]
+		if slices.Equal(prev.Tags, res.Tags) {
+			continue
+		}
+		for _, tag := range res.Tags {
+			if !slices.Contains(prev.Tags, tag) {
+				tags[tag] = true
+			}
+		}
+		for _, tag := range prev.Tags {
+			if !slices.Contains(res.Tags, tag) {
+				tags[tag] = true
+			}
+		}
+	}
+	for id, prev := range previous {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		for _, tag := range prev.Tags {
+			tags[tag] = true
+		}
+	}
+	slugs := make([]string, 0, len(tags))
+	for tag := range tags {
+		slugs = append(slugs, tag)
+	}
+	slices.Sort(slugs)
+	return slugs
+}
@@ -0,0 +1,579 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/passbolt/go-passbolt/api"
+)
+
+func Test_resolveResourceID(t *testing.T) {
+	folders := map[string]cachedFolder{
+		"infra":      {Name: "infra"},
+		"prod":       {Name: "prod", FolderParentID: "infra"},
+		"staging":    {Name: "staging", FolderParentID: "infra"},
+		"other-prod": {Name: "prod"},
+	}
+	resources := map[string]cachedResource{
+		"postgres-prod":    {Name: "postgres", FolderParentID: "prod"},
+		"postgres-staging": {Name: "postgres", FolderParentID: "staging"},
+		"redis-prod":       {Name: "redis", FolderParentID: "prod"},
+		"redis-other":      {Name: "redis", FolderParentID: "other-prod"},
+		"s3":               {Name: "s3"},
+	}
+	type args struct {
+		name       string
+		folderPath string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr error
+	}{
+		{
+			name: "unique name",
+			args: args{name: "s3"},
+			want: "s3",
+		},
+		{
+			name:    "ambiguous name",
+			args:    args{name: "postgres"},
+			wantErr: ErrAmbiguousResource,
+		},
+		{
+			name:    "unknown name",
+			args:    args{name: "mysql"},
+			wantErr: ErrResourceNotFound,
+		},
+		{
+			name: "folder path",
+			args: args{folderPath: "infra/prod/postgres"},
+			want: "postgres-prod",
+		},
+		{
+			name: "folder path with surrounding slashes",
+			args: args{folderPath: "/infra/staging/postgres/"},
+			want: "postgres-staging",
+		},
+		{
+			name: "folder path of resource in root folder",
+			args: args{folderPath: "s3"},
+			want: "s3",
+		},
+		{
+			name: "folder path distinguishes folders with the same name",
+			args: args{folderPath: "prod/redis"},
+			want: "redis-other",
+		},
+		{
+			name:    "unknown folder path",
+			args:    args{folderPath: "infra/dev/postgres"},
+			wantErr: ErrResourceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveResourceID(resources, folders, tt.args.name, tt.args.folderPath)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("resolveResourceID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("resolveResourceID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_folderNames(t *testing.T) {
+	folders := map[string]cachedFolder{
+		"a": {Name: "a", FolderParentID: "b"},
+		"b": {Name: "b", FolderParentID: "a"},
+	}
+	// cycles must not result in an endless loop
+	if got := folderNames(folders, "a"); len(got) != 2 {
+		t.Errorf("folderNames() = %v, want 2 elements", got)
+	}
+}
+
+func Test_resolveFolderID(t *testing.T) {
+	folders := map[string]cachedFolder{
+		"infra":      {Name: "infra"},
+		"prod":       {Name: "prod", FolderParentID: "infra"},
+		"other-prod": {Name: "prod", FolderParentID: "infra"},
+		"staging":    {Name: "staging", FolderParentID: "infra"},
+	}
+	type args struct {
+		id   string
+		path string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr error
+	}{
+		{
+			name: "folder ID",
+			args: args{id: "staging"},
+			want: "staging",
+		},
+		{
+			name:    "unknown folder ID",
+			args:    args{id: "dev"},
+			wantErr: ErrFolderNotFound,
+		},
+		{
+			name: "folder path",
+			args: args{path: "/infra/staging/"},
+			want: "staging",
+		},
+		{
+			name:    "ambiguous folder path",
+			args:    args{path: "infra/prod"},
+			wantErr: ErrAmbiguousFolder,
+		},
+		{
+			name:    "unknown folder path",
+			args:    args{path: "staging"},
+			wantErr: ErrFolderNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveFolderID(folders, tt.args.id, tt.args.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("resolveFolderID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("resolveFolderID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_changedFolders(t *testing.T) {
+	previous := map[string]cachedResource{
+		"postgres": {Name: "postgres", FolderParentID: "prod"},
+		"redis":    {Name: "redis", FolderParentID: "prod"},
+		"s3":       {Name: "s3", FolderParentID: "staging"},
+		"root":     {Name: "root"},
+	}
+	tests := []struct {
+		name    string
+		current map[string]cachedResource
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			current: previous,
+			want:    []string{},
+		},
+		{
+			name: "renamed resource",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgresql", FolderParentID: "prod"},
+				"redis":    {Name: "redis", FolderParentID: "prod"},
+				"s3":       {Name: "s3", FolderParentID: "staging"},
+				"root":     {Name: "root"},
+			},
+			want: []string{},
+		},
+		{
+			name: "added, moved and removed resources",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgres", FolderParentID: "prod"},
+				"redis":    {Name: "redis", FolderParentID: "dev"},
+				"root":     {Name: "root"},
+				"mysql":    {Name: "mysql", FolderParentID: "test"},
+			},
+			want: []string{"dev", "prod", "staging", "test"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, changedFolders(previous, tt.current)); diff != "" {
+				t.Errorf("changedFolders() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_taggedResources(t *testing.T) {
+	tagIndex := map[string][]string{
+		"k8s:payments": {"postgres", "redis", "s3"},
+		"prod":         {"postgres", "s3"},
+		"staging":      {"redis"},
+	}
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{
+			name: "single tag",
+			tags: []string{"k8s:payments"},
+			want: []string{"postgres", "redis", "s3"},
+		},
+		{
+			name: "all tags must match",
+			tags: []string{"k8s:payments", "prod"},
+			want: []string{"postgres", "s3"},
+		},
+		{
+			name: "no resource matches",
+			tags: []string{"prod", "staging"},
+			want: []string{},
+		},
+		{
+			name: "unknown tag",
+			tags: []string{"dev"},
+			want: []string{},
+		},
+		{
+			name: "no tags",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, taggedResources(tagIndex, tt.tags)); diff != "" {
+				t.Errorf("taggedResources() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+	// the index must not be modified
+	if diff := cmp.Diff([]string{"postgres", "redis", "s3"}, tagIndex["k8s:payments"]); diff != "" {
+		t.Errorf("taggedResources() modified the index (-want +got):\n%s", diff)
+	}
+}
+
+func Test_changedTags(t *testing.T) {
+	previous := map[string]cachedResource{
+		"postgres": {Name: "postgres", Tags: []string{"k8s:payments", "prod"}},
+		"redis":    {Name: "redis", Tags: []string{"k8s:payments"}},
+		"s3":       {Name: "s3", Tags: []string{"staging"}},
+	}
+	tests := []struct {
+		name    string
+		current map[string]cachedResource
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			current: previous,
+			want:    []string{},
+		},
+		{
+			name: "added and removed tags",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgres", Tags: []string{"k8s:payments"}},
+				"redis":    {Name: "redis", Tags: []string{"k8s:orders", "k8s:payments"}},
+				"s3":       {Name: "s3", Tags: []string{"staging"}},
+			},
+			want: []string{"k8s:orders", "prod"},
+		},
+		{
+			name: "added and deleted resources",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgres", Tags: []string{"k8s:payments", "prod"}},
+				"redis":    {Name: "redis", Tags: []string{"k8s:payments"}},
+				"mysql":    {Name: "mysql", Tags: []string{"dev"}},
+			},
+			want: []string{"dev", "staging"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, changedTags(previous, tt.current)); diff != "" {
+				t.Errorf("changedTags() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_changedResources(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := map[string]cachedResource{
+		"postgres": {Name: "postgres"},
+		"redis":    {Name: "redis"},
+		"s3":       {Name: "s3"},
+	}
+	previousModified := map[string]time.Time{
+		"postgres": modified,
+		"redis":    modified,
+		"s3":       modified,
+	}
+	tests := []struct {
+		name            string
+		current         map[string]cachedResource
+		currentModified map[string]time.Time
+		want            Changes
+	}{
+		{
+			name:            "unchanged",
+			current:         previous,
+			currentModified: previousModified,
+			want:            Changes{Resources: []string{}, Deleted: []string{}, Renamed: []string{}},
+		},
+		{
+			name: "modified, renamed, deleted and added resources",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgres"},
+				"redis":    {Name: "cache"},
+				"mysql":    {Name: "mysql"},
+			},
+			currentModified: map[string]time.Time{
+				"postgres": modified.Add(time.Hour),
+				"redis":    modified.Add(time.Hour),
+				"mysql":    modified,
+			},
+			want: Changes{
+				Resources: []string{"postgres", "redis", "s3"},
+				Deleted:   []string{"s3"},
+				Renamed:   []string{"redis"},
+			},
+		},
+		{
+			name: "moved resource",
+			current: map[string]cachedResource{
+				"postgres": {Name: "postgres", FolderParentID: "db"},
+				"redis":    {Name: "redis"},
+				"s3":       {Name: "s3"},
+			},
+			currentModified: previousModified,
+			want: Changes{
+				Resources: []string{"postgres"},
+				Deleted:   []string{},
+				Renamed:   []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedResources(previous, previousModified, tt.current, tt.currentModified)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("changedResources() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_previousPaths(t *testing.T) {
+	resources := map[string]cachedResource{
+		"postgres": {Name: "postgres", FolderParentID: "db"},
+		"s3":       {Name: "s3"},
+	}
+	folders := map[string]cachedFolder{
+		"apps": {Name: "apps"},
+		"db":   {Name: "db", FolderParentID: "apps"},
+	}
+	gotResources, gotFolders := previousPaths(resources, folders, Changes{
+		Resources: []string{"postgres", "s3", "mysql"},
+		Folders:   []string{"db", "new"},
+	})
+	wantResources := map[string]ResourcePath{
+		"postgres": {Name: "postgres", FolderPath: "apps/db/postgres"},
+		"s3":       {Name: "s3", FolderPath: "s3"},
+	}
+	if diff := cmp.Diff(wantResources, gotResources); diff != "" {
+		t.Errorf("previousPaths() resources mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]string{"db": "apps/db"}, gotFolders); diff != "" {
+		t.Errorf("previousPaths() folders mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_ambiguousNames(t *testing.T) {
+	previous := map[string]cachedResource{
+		"postgres-staging": {Name: "postgres"},
+		"postgres-prod":    {Name: "postgres"},
+		"redis":            {Name: "redis"},
+	}
+	current := map[string]cachedResource{
+		"postgres-staging": {Name: "postgres"},
+		"postgres-prod":    {Name: "postgres"},
+		"redis":            {Name: "redis"},
+		"redis-copy":       {Name: "redis"},
+		"s3":               {Name: "s3"},
+		"s3-copy":          {Name: "s3"},
+	}
+	want := []string{"redis", "s3"}
+	if diff := cmp.Diff(want, ambiguousNames(previous, current)); diff != "" {
+		t.Errorf("ambiguousNames() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func Test_newCacheSnapshot(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := newCacheSnapshot([]api.Resource{
+		{ID: "postgres-staging", Name: "postgres", Modified: &api.Time{Time: modified}},
+		{ID: "postgres-prod", Name: "postgres", Tags: []api.Tag{{Slug: "prod"}}},
+		{ID: "redis", Name: "redis", Tags: []api.Tag{{Slug: "prod"}}},
+	}, []api.Folder{
+		{ID: "infra", Name: "infra"},
+	})
+	if diff := cmp.Diff(map[string][]string{
+		"postgres": {"postgres-prod", "postgres-staging"},
+		"redis":    {"redis"},
+	}, snapshot.names); diff != "" {
+		t.Errorf("newCacheSnapshot() names mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string][]string{"prod": {"postgres-prod", "redis"}}, snapshot.tags); diff != "" {
+		t.Errorf("newCacheSnapshot() tags mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]time.Time{"postgres-staging": modified}, snapshot.modified); diff != "" {
+		t.Errorf("newCacheSnapshot() modified mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]cachedFolder{"infra": {Name: "infra"}}, snapshot.folders); diff != "" {
+		t.Errorf("newCacheSnapshot() folders mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_GetSecretID(t *testing.T) {
+	c := &Client{nameIndex: map[string][]string{
+		"postgres": {"postgres-prod", "postgres-staging"},
+		"redis":    {"redis"},
+	}}
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "redis",
+			want: "redis",
+		},
+		{
+			name:    "postgres",
+			wantErr: ErrAmbiguousResource,
+		},
+		{
+			name:    "mysql",
+			wantErr: ErrResourceNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetSecretID(tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetSecretID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetSecretID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_indexName(t *testing.T) {
+	c := &Client{nameIndex: map[string][]string{
+		"postgres": {"postgres-prod", "postgres-staging"},
+		"redis":    {"redis"},
+	}}
+	// a created resource is added to the existing name
+	c.indexName("postgres-dev", "postgres")
+	// a renamed resource is removed from its previous name
+	c.indexName("redis", "cache")
+	if diff := cmp.Diff(map[string][]string{
+		"postgres": {"postgres-dev", "postgres-prod", "postgres-staging"},
+		"cache":    {"redis"},
+	}, c.GetCache()); diff != "" {
+		t.Errorf("indexName() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestClient_isResourceNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/resources/deleted.json", "/resources/broken.json":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"header":{"status":"error","code":404},"body":{}}`)
+		case "/resources/forbidden.json":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"header":{"status":"error","code":403},"body":{}}`)
+		case "/resources.json":
+			switch r.URL.Query().Get("filter[has-id][]") {
+			case "broken":
+				// the resource exists, but its secret or resource type is missing
+				fmt.Fprint(w, `{"header":{"status":"success","code":200},"body":[{"id":"broken"}]}`)
+			case "unavailable":
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"header":{"status":"error","code":503},"body":{}}`)
+			default:
+				fmt.Fprint(w, `{"header":{"status":"success","code":200},"body":[]}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"header":{"status":"error","code":404},"body":{}}`)
+		}
+	}))
+	defer server.Close()
+
+	clnt, err := api.NewClient(newHTTPClient(), "", server.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{passboltClient: clnt, calls: &sync.WaitGroup{}}
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{
+			name: "deleted resource",
+			id:   "deleted",
+			want: true,
+		},
+		{
+			name: "resource found by a fresh lookup",
+			id:   "broken",
+		},
+		{
+			name: "missing permissions",
+			id:   "forbidden",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.getSecret(context.Background(), tt.id)
+			if err == nil {
+				t.Fatal("getSecret() error = nil, want error")
+			}
+			if got := errors.Is(err, ErrResourceNotFound); got != tt.want {
+				t.Errorf("getSecret() error = %v, is ErrResourceNotFound %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+	t.Run("failed lookup", func(t *testing.T) {
+		if c.isResourceNotFound(context.Background(), "unavailable") {
+			t.Error("isResourceNotFound() = true, want false if the lookup fails")
+		}
+	})
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/passbolt/go-passbolt/api"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// getMetadataKeysOptions requests the metadata keys including the private keys encrypted for the current user.
+type getMetadataKeysOptions struct {
+	ContainMetadataPrivateKeys bool `url:"contain[metadata_private_keys],omitempty"`
+}
+
+// getResourcesOptions requests resources including the tags. The v5 metadata is always included.
+type getResourcesOptions struct {
+	FilterHasID     []string `url:"filter[has-id][],omitempty"`
+	FilterHasParent []string `url:"filter[has-parent][],omitempty"`
+	ContainTags     bool     `url:"contain[tag],omitempty"`
+}
+
+// metadataKey is a shared metadata key as returned by passbolt.
+type metadataKey struct {
+	ID string `json:"id"`
+	// MetadataPrivateKeys contains the private key encrypted for the current user.
+	MetadataPrivateKeys []struct {
+		Data string `json:"data"`
+	} `json:"metadata_private_keys"`
+}
+
+// metadataPrivateKey is the decrypted private key of a shared metadata key.
+type metadataPrivateKey struct {
+	ArmoredKey string `json:"armored_key"`
+	Passphrase string `json:"passphrase"`
+}
+
+// decryptedName is the name decrypted from the metadata of a v5 resource.
+type decryptedName struct {
+	metadata string
+	name     string
+}
+
+// metadataKeyring caches the decrypted shared metadata keys and the names decrypted from the metadata of v5 resources.
+// The shared metadata keys are loaded when the first resource encrypted with a shared key is decrypted,
+// and reloaded when a resource refers to an unknown key, e.g. after the key was rotated.
+type metadataKeyring struct {
+	mu   sync.Mutex
+	keys map[string]metadataPrivateKey
+	// names caches the decrypted names by resource ID, the entries are only used as long as the metadata did not change.
+	names map[string]decryptedName
+}
+
+// reset forgets the decrypted keys and names, e.g. because the credentials changed.
+func (k *metadataKeyring) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = nil
+	k.names = nil
+}
+
+// key returns the decrypted shared metadata key with the given ID.
+func (k *metadataKeyring) key(ctx context.Context, clnt *api.Client, id string) (metadataPrivateKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[id]; ok {
+		return key, nil
+	}
+	keys, err := getMetadataKeys(ctx, clnt)
+	if err != nil {
+		return metadataPrivateKey{}, err
+	}
+	k.keys = keys
+	key, ok := keys[id]
+	if !ok {
+		return metadataPrivateKey{}, fmt.Errorf("metadata key %q is not shared with the user", id)
+	}
+	return key, nil
+}
+
+// decryptMetadata decrypts the metadata of the given v5 resource.
+func (k *metadataKeyring) decryptMetadata(ctx context.Context, clnt *api.Client, res resource) (*resourceMetadata, error) {
+	var rawMetadata string
+	switch res.MetadataKeyType {
+	case metadataKeyTypeUser:
+		raw, err := clnt.DecryptMessage(res.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt resource metadata: %w", err)
+		}
+		rawMetadata = raw
+	case metadataKeyTypeShared:
+		key, err := k.key(ctx, clnt, res.MetadataKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata key: %w", err)
+		}
+		var passphrase []byte
+		if key.Passphrase != "" {
+			passphrase = []byte(key.Passphrase)
+		}
+		raw, err := helper.DecryptMessageArmored(key.ArmoredKey, passphrase, res.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt resource metadata: %w", err)
+		}
+		rawMetadata = raw
+	default:
+		return nil, fmt.Errorf("resource metadata encrypted with %q is not supported", res.MetadataKeyType)
+	}
+	var metadata resourceMetadata
+	if err := json.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse resource metadata: %w", err)
+	}
+	return &metadata, nil
+}
+
+// resourceNames fills the names of the given v5 resources from their decrypted metadata.
+// Names of resources whose metadata did not change are taken from the previous call.
+// If all is true, the given resources are all resources, so the names of the other resources are forgotten.
+func (k *metadataKeyring) resourceNames(ctx context.Context, clnt *api.Client, resources []resource, all bool) []api.Resource {
+	k.mu.Lock()
+	previous := k.names
+	k.mu.Unlock()
+
+	names := make(map[string]decryptedName, len(resources))
+	result := make([]api.Resource, 0, len(resources))
+	for _, res := range resources {
+		if res.Metadata != "" {
+			cached, ok := previous[res.ID]
+			if !ok || cached.metadata != res.Metadata {
+				metadata, err := k.decryptMetadata(ctx, clnt, res)
+				if err != nil {
+					// the resource can still be looked up by its ID
+					log.FromContext(ctx).Error(err, "failed to decrypt the name of passbolt resource", "id", res.ID)
+					result = append(result, res.Resource)
+					continue
+				}
+				cached = decryptedName{metadata: res.Metadata, name: metadata.Name}
+			}
+			names[res.ID] = cached
+			res.Name = cached.name
+		}
+		result = append(result, res.Resource)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if all || k.names == nil {
+		k.names = names
+	} else {
+		for id, name := range names {
+			k.names[id] = name
+		}
+	}
+	return result
+}
+
+// getResources retrieves the resources matching the given options and decrypts the names of v5 resources.
+func (k *metadataKeyring) getResources(ctx context.Context, clnt *api.Client, opts getResourcesOptions) ([]api.Resource, error) {
+	msg, err := clnt.DoCustomRequest(ctx, "GET", "/resources.json", "v2", nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var resources []resource
+	if err := json.Unmarshal(msg.Body, &resources); err != nil {
+		return nil, fmt.Errorf("failed to parse resources: %w", err)
+	}
+	all := len(opts.FilterHasID) == 0 && len(opts.FilterHasParent) == 0
+	return k.resourceNames(ctx, clnt, resources, all), nil
+}
+
+// getMetadataKeys retrieves the shared metadata keys and decrypts their private keys with the key of the user.
+func getMetadataKeys(ctx context.Context, clnt *api.Client) (map[string]metadataPrivateKey, error) {
+	msg, err := clnt.DoCustomRequest(ctx, "GET", "/metadata/keys.json", "v2", nil, getMetadataKeysOptions{ContainMetadataPrivateKeys: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata keys: %w", err)
+	}
+	var keys []metadataKey
+	if err := json.Unmarshal(msg.Body, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata keys: %w", err)
+	}
+	result := make(map[string]metadataPrivateKey, len(keys))
+	for _, key := range keys {
+		// passbolt only returns the private key encrypted for the current user
+		for _, privateKey := range key.MetadataPrivateKeys {
+			raw, err := clnt.DecryptMessage(privateKey.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt metadata key %q: %w", key.ID, err)
+			}
+			var decrypted metadataPrivateKey
+			if err := json.Unmarshal([]byte(raw), &decrypted); err != nil {
+				return nil, fmt.Errorf("failed to parse metadata key %q: %w", key.ID, err)
+			}
+			result[key.ID] = decrypted
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v2/crypto"
+	"github.com/ProtonMail/gopenpgp/v2/helper"
+	"github.com/google/go-cmp/cmp"
+	"github.com/passbolt/go-passbolt/api"
+)
+
+// testKey generates an armored private and public key, the private key is locked with the given passphrase.
+func testKey(t *testing.T, passphrase string) (string, string) {
+	t.Helper()
+	key, err := crypto.GenerateKey("test", "test@example.com", "x25519", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := key.GetArmoredPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passphrase != "" {
+		if key, err = key.Lock([]byte(passphrase)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	private, err := key.Armor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private, public
+}
+
+// testEncrypt encrypts the given value as JSON with the given public key.
+func testEncrypt(t *testing.T, publicKey string, value any) string {
+	t.Helper()
+	raw, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := helper.EncryptMessageArmored(publicKey, string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMetadataKeyring_getResources(t *testing.T) {
+	userPrivate, userPublic := testKey(t, "secret")
+	sharedPrivate, sharedPublic := testKey(t, "")
+
+	metadataKeys := []map[string]any{{
+		"id": "shared",
+		"metadata_private_keys": []map[string]any{{
+			"data": testEncrypt(t, userPublic, metadataPrivateKey{ArmoredKey: sharedPrivate}),
+		}},
+	}}
+	resources := []map[string]any{
+		{"id": "legacy", "name": "legacy-name"},
+		{
+			"id":                "user",
+			"metadata_key_type": metadataKeyTypeUser,
+			"metadata":          testEncrypt(t, userPublic, resourceMetadata{Name: "user-name"}),
+		},
+		{
+			"id":                "shared",
+			"metadata_key_type": metadataKeyTypeShared,
+			"metadata_key_id":   "shared",
+			"metadata":          testEncrypt(t, sharedPublic, resourceMetadata{Name: "shared-name"}),
+		},
+		{
+			"id":                "unknown-key",
+			"metadata_key_type": metadataKeyTypeShared,
+			"metadata_key_id":   "unknown",
+			"metadata":          testEncrypt(t, sharedPublic, resourceMetadata{Name: "unknown-name"}),
+		},
+	}
+	var keyRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metadata/keys.json"):
+			keyRequests.Add(1)
+			body = metadataKeys
+		case strings.HasSuffix(r.URL.Path, "/resources.json"):
+			body = resources
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"header":{"status":"error","code":404},"body":{}}`)
+			return
+		}
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Error(err)
+		}
+		fmt.Fprintf(w, `{"header":{"status":"success","code":200},"body":%s}`, raw)
+	}))
+	defer server.Close()
+
+	clnt, err := api.NewClient(newHTTPClient(), "", server.URL, userPrivate, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := &metadataKeyring{}
+	for i := 0; i < 2; i++ {
+		got, err := keys.getResources(context.Background(), clnt, getResourcesOptions{})
+		if err != nil {
+			t.Fatalf("getResources() error = %v", err)
+		}
+		names := map[string]string{}
+		for _, res := range got {
+			names[res.ID] = res.Name
+		}
+		want := map[string]string{
+			"legacy": "legacy-name",
+			"user":   "user-name",
+			"shared": "shared-name",
+			// the name of a resource encrypted with an unknown key can not be decrypted
+			"unknown-key": "",
+		}
+		if diff := cmp.Diff(want, names); diff != "" {
+			t.Errorf("getResources() names mismatch (-want +got):\n%s", diff)
+		}
+	}
+	// the keys are loaded once for the shared resource and reloaded for the unknown key during the first call,
+	// the second call only reloads them for the unknown key, since the other names did not change
+	if got := keyRequests.Load(); got != 3 {
+		t.Errorf("getResources() requested the metadata keys %d times, want 3", got)
+	}
+}
@@ -20,12 +20,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/passbolt/go-passbolt/api"
 	"github.com/passbolt/go-passbolt/helper"
 	"github.com/prometheus/client_golang/prometheus"
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -66,6 +70,30 @@ var (
 			Help: "Number of cache sync errors.",
 		},
 	)
+	passboltResourceWriteAttemptsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_resource_write_attempts_total",
+			Help: "Number of attempts to create or update a resource in passbolt.",
+		},
+	)
+	passboltResourceWriteFailureAttemptsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_resource_write_failure_attempts_total",
+			Help: "Number of failure attempts to create or update a resource in passbolt.",
+		},
+	)
+	passboltCacheRefreshBackoffs = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_cache_refresh_backoffs_total",
+			Help: "Number of failed cache refreshes that are retried with backoff.",
+		},
+	)
+	passboltResourceChanges = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_resource_changes_total",
+			Help: "Number of changed passbolt resources detected during cache syncs.",
+		},
+	)
 )
 
 func init() {
@@ -77,6 +105,10 @@ func init() {
 		passboltReLoginFailures,
 		passboltCacheSync,
 		passboltCacheFailures,
+		passboltCacheRefreshBackoffs,
+		passboltResourceChanges,
+		passboltResourceWriteAttemptsTotal,
+		passboltResourceWriteFailureAttemptsTotal,
 	)
 }
 
@@ -87,20 +119,70 @@ type PassboltSecretDefinition struct {
 	URI            string
 	Password       string
 	Description    string
+	// ResourceType is the slug of the passbolt resource type, e.g. password-and-description.
+	ResourceType string
+	// Fields contains all additional fields of the secret, e.g. custom fields of passbolt v5 resources.
+	Fields map[string]string
+	// TOTP is the TOTP configuration of the resource, if any.
+	TOTP *TOTP
 }
 
 // FieldValue returns the value of the given field by name.
+// Fields that do not exist return an empty string.
 func (p PassboltSecretDefinition) FieldValue(fieldName passboltv1.FieldName) string {
+	value, _ := p.LookupField(fieldName)
+	return value
+}
+
+// LookupField returns the value of the given field by name.
+// Besides the well known fields, all additional fields of the secret can be looked up.
+// The boolean is false if the field does not exist.
+func (p PassboltSecretDefinition) LookupField(fieldName passboltv1.FieldName) (string, bool) {
 	switch fieldName {
 	case passboltv1.FieldNameUsername:
-		return p.Username
+		return p.Username, true
 	case passboltv1.FieldNameUri:
-		return p.URI
+		return p.URI, true
 	case passboltv1.FieldNamePassword:
-		return p.Password
+		return p.Password, true
+	case passboltv1.FieldNameName:
+		return p.Name, true
+	case passboltv1.FieldNameDescription:
+		return p.Description, true
+	case passboltv1.FieldNameTOTP:
+		return p.TOTPCode(), p.TOTP != nil
+	case passboltv1.FieldNameTOTPSecret:
+		return p.TOTPSecret(), p.TOTP != nil
+	case passboltv1.FieldNameTOTPURI:
+		return p.TOTPURI(), p.TOTP != nil
 	default:
+		value, ok := p.Fields[string(fieldName)]
+		return value, ok
+	}
+}
+
+// TOTPCode returns the current TOTP code of the resource or an empty string if no TOTP is configured.
+func (p PassboltSecretDefinition) TOTPCode() string {
+	if p.TOTP == nil {
+		return ""
+	}
+	return p.TOTP.Code(time.Now())
+}
+
+// TOTPSecret returns the TOTP seed of the resource or an empty string if no TOTP is configured.
+func (p PassboltSecretDefinition) TOTPSecret() string {
+	if p.TOTP == nil {
 		return ""
 	}
+	return p.TOTP.SecretKey
+}
+
+// TOTPURI returns the otpauth URI of the resource or an empty string if no TOTP is configured.
+func (p PassboltSecretDefinition) TOTPURI() string {
+	if p.TOTP == nil {
+		return ""
+	}
+	return p.TOTP.URI(p.Name, p.Username)
 }
 
 // Client is a passbolt client.
@@ -110,19 +192,98 @@ func (p PassboltSecretDefinition) FieldValue(fieldName passboltv1.FieldName) str
 // Instead, we must retrieve all secrets and their UUIDs.
 // This is not ideal, but it is the only way to retrieve secrets by name.
 type Client struct {
+	// url is the URL of the passbolt server.
+	url string
+	// apiMu is used to prevent concurrent access to the underlying passbolt client while it is replaced.
+	apiMu sync.RWMutex
 	// passboltClient is the underlying passbolt client.
+	// It must only be accessed with api, since it is replaced when the credentials are updated.
 	passboltClient *api.Client
+	// calls tracks the in-flight calls of passboltClient, so that its session is only closed once they finished.
+	// It is replaced together with passboltClient and nil if the calls are not tracked.
+	calls *sync.WaitGroup
 	// mu is used to prevent concurrent access to the secret cache.
 	mu sync.RWMutex
-	// secretCache represents a cache of NAME -> UUID mappings.
-	// This is used to avoid unnecessary API calls.
-	secretCache map[string]string
+	// loadMu serializes cache syncs, so that the changes are always detected against the previous sync.
+	loadMu sync.Mutex
+	// nameIndex represents a cache of NAME -> sorted UUIDs mappings.
+	// Names are not unique in passbolt, so a name may refer to multiple resources.
+	nameIndex map[string][]string
+	// modifiedCache represents a cache of UUID -> modified timestamp mappings.
+	// It is used to detect changes of resources between two cache syncs.
+	modifiedCache map[string]time.Time
+	// resourceCache represents a cache of UUID -> resource mappings.
+	// It is used to look up resources by name and folder path.
+	resourceCache map[string]cachedResource
+	// folderCache represents a cache of UUID -> folder mappings.
+	// It is used to resolve the folder path of resources.
+	folderCache map[string]cachedFolder
+	// tagCache represents a cache of tag slug -> sorted UUIDs of the tagged resources.
+	// It is used to select resources by tags.
+	tagCache map[string][]string
+	// cacheSyncedAt is the time of the last successful cache sync.
+	// Folder changes are only reported after the first sync.
+	cacheSyncedAt time.Time
+	// changeHandlers are called with the changes detected during a cache sync.
+	changeHandlers []ChangeHandler
+	// loginMu serializes logins, so that concurrent calls failing with an expired session log in only once.
+	loginMu sync.Mutex
+	// loginPending is true until the first login of a client created with NewLazyClient succeeded.
+	loginPending atomic.Bool
+	// sessionGeneration is incremented with every login.
+	// It is used to detect whether the session was already renewed after a call failed.
+	sessionGeneration atomic.Uint64
+	// limiter restricts the calls to the passbolt API, nil if the calls are not limited.
+	limiter atomic.Pointer[limiter]
+	// secrets caches decrypted secrets, nil if secrets are not cached.
+	secrets atomic.Pointer[secretCache]
+	// lastSuccessfulCall is the time of the last successful call to the passbolt API in unix nanoseconds.
+	lastSuccessfulCall atomic.Int64
+	// metadataKeys decrypts the metadata of v5 resources.
+	metadataKeys metadataKeyring
+	// principals caches the users and groups used to resolve shares.
+	principals principalCache
+}
+
+// Changes are the changes of passbolt resources detected during a cache sync.
+type Changes struct {
+	// Resources are the sorted IDs of the resources that were modified, renamed, moved or deleted since the last cache sync.
+	Resources []string
+	// Deleted are the sorted IDs of the resources that were deleted since the last cache sync.
+	// They are included in Resources.
+	Deleted []string
+	// Renamed are the sorted IDs of the resources that were renamed since the last cache sync.
+	// They are included in Resources.
+	Renamed []string
+	// Folders are the IDs of the folders to which resources were added or from which resources were removed.
+	Folders []string
+	// Tags are the slugs of the tags that were added to or removed from resources.
+	Tags []string
+	// PreviousPaths are the names and folder paths of the changed resources as seen during the previous cache sync,
+	// so that references to deleted, renamed or moved resources can be found by their previous name or folder path.
+	PreviousPaths map[string]ResourcePath
+	// PreviousFolderPaths are the paths of the changed folders as seen during the previous cache sync.
+	PreviousFolderPaths map[string]string
+}
+
+// ResourcePath is the name and the folder path of a resource.
+type ResourcePath struct {
+	Name       string
+	FolderPath string
+}
+
+// IsEmpty reports whether no changes were detected.
+func (c Changes) IsEmpty() bool {
+	return len(c.Resources) == 0 && len(c.Folders) == 0 && len(c.Tags) == 0
 }
 
+// ChangeHandler is called with the changes of the passbolt resources since the last cache sync.
+type ChangeHandler func(changes Changes)
+
 // NewClient initializes a new passbolt client and logs in.
 // The client is configured to use the given URL, username and password.
 func NewClient(ctx context.Context, url, username, password string) (*Client, error) {
-	clnt, err := api.NewClient(&http.Client{}, "", url, username, password)
+	clnt, err := api.NewClient(newHTTPClient(), "", url, username, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create passbolt client: %w", err)
 	}
@@ -130,11 +291,36 @@ func NewClient(ctx context.Context, url, username, password string) (*Client, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to login to passbolt: %w", err)
 	}
+	c := newClient(url, clnt)
+	c.recordSuccessfulCall()
+	return c, nil
+}
+
+// NewLazyClient initializes a new passbolt client without logging in.
+// The client logs in before the first call to the passbolt API, so that it can be created while passbolt is unavailable.
+func NewLazyClient(url, username, password string) (*Client, error) {
+	clnt, err := api.NewClient(newHTTPClient(), "", url, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create passbolt client: %w", err)
+	}
+	c := newClient(url, clnt)
+	c.loginPending.Store(true)
+	return c, nil
+}
+
+// newClient returns a client with empty caches using the given passbolt client.
+func newClient(url string, clnt *api.Client) *Client {
 	return &Client{
+		url:            url,
 		passboltClient: clnt,
-		secretCache:    map[string]string{},
+		calls:          &sync.WaitGroup{},
+		nameIndex:      map[string][]string{},
+		modifiedCache:  map[string]time.Time{},
+		resourceCache:  map[string]cachedResource{},
+		folderCache:    map[string]cachedFolder{},
+		tagCache:       map[string][]string{},
 		mu:             sync.RWMutex{},
-	}, nil
+	}
 }
 
 // LoadCache fills the secret cache with all secret names and IDs.
@@ -142,22 +328,193 @@ func NewClient(ctx context.Context, url, username, password string) (*Client, er
 // This is necessary because the passbolt API does not allow for searching secrets by name.
 // Instead, we must retrieve all secrets and their UUIDs.
 // This is not ideal, but it is the only way to retrieve secrets by name.
+//
+// Resources whose modified timestamp differs from the previous sync, folders whose resources were added, removed or moved
+// and tags that were added to or removed from resources are passed to the registered change handlers.
 func (c *Client) LoadCache(ctx context.Context) error {
 	passboltCacheSync.Inc()
-	// prevent concurrent access to the cache
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	changes, err := c.loadCache(ctx)
+	if err != nil {
+		return err
+	}
+	if changes.IsEmpty() {
+		return nil
+	}
+	passboltResourceChanges.Add(float64(len(changes.Resources)))
+	// the handlers are called without holding the lock, so that they are free to query the cache
+	c.mu.RLock()
+	handlers := c.changeHandlers
+	c.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(changes)
+	}
+	return nil
+}
+
+// loadCache fills the cache and returns the changes since the last call.
+// The resources and folders are retrieved without blocking lookups, afterwards the caches are replaced at once,
+// so that lookups never see a partially refreshed cache and deleted or renamed resources disappear.
+func (c *Client) loadCache(ctx context.Context) (Changes, error) {
+	// prevent concurrent syncs
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
 	// retrieve all secrets
-	resources, err := c.passboltClient.GetResources(ctx, &api.GetResourcesOptions{})
+	var resources []api.Resource
+	err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		resources, err = c.metadataKeys.getResources(ctx, clnt, getResourcesOptions{
+			ContainTags: true,
+		})
+		return err
+	})
 	if err != nil {
 		passboltCacheFailures.Inc()
-		return fmt.Errorf("failed to get secrets: %w", err)
+		return Changes{}, fmt.Errorf("failed to get secrets: %w", err)
+	}
+	// retrieve all folders to be able to resolve folder paths
+	var folders []api.Folder
+	err = c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		folders, err = clnt.GetFolders(ctx, &api.GetFoldersOptions{})
+		return err
+	})
+	if err != nil {
+		passboltCacheFailures.Inc()
+		return Changes{}, fmt.Errorf("failed to get folders: %w", err)
+	}
+	snapshot := newCacheSnapshot(resources, folders)
+
+	// prevent concurrent access to the cache while it is replaced
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes := Changes{}
+	if !c.cacheSyncedAt.IsZero() {
+		changes = changedResources(c.resourceCache, c.modifiedCache, snapshot.resources, snapshot.modified)
+		changes.Folders = changedFolders(c.resourceCache, snapshot.resources)
+		changes.Tags = changedTags(c.resourceCache, snapshot.resources)
+		// the paths must be resolved before the caches are replaced, since the resources may have been deleted or moved
+		changes.PreviousPaths, changes.PreviousFolderPaths = previousPaths(c.resourceCache, c.folderCache, changes)
+		logChanges(ctx, c.resourceCache, snapshot.resources, changes)
+	}
+	c.nameIndex = snapshot.names
+	c.modifiedCache = snapshot.modified
+	c.resourceCache = snapshot.resources
+	c.folderCache = snapshot.folders
+	c.tagCache = snapshot.tags
+	c.cacheSyncedAt = time.Now()
+	c.recordSuccessfulCall()
+	return changes, nil
+}
+
+// cacheSnapshot contains the caches built from the resources and folders retrieved during a single cache sync.
+type cacheSnapshot struct {
+	names     map[string][]string
+	modified  map[string]time.Time
+	resources map[string]cachedResource
+	folders   map[string]cachedFolder
+	tags      map[string][]string
+}
+
+// newCacheSnapshot builds the caches of the given resources and folders.
+func newCacheSnapshot(resources []api.Resource, folders []api.Folder) cacheSnapshot {
+	snapshot := cacheSnapshot{
+		names:     map[string][]string{},
+		modified:  make(map[string]time.Time, len(resources)),
+		resources: make(map[string]cachedResource, len(resources)),
+		folders:   make(map[string]cachedFolder, len(folders)),
+		tags:      map[string][]string{},
+	}
+	for _, folder := range folders {
+		snapshot.folders[folder.ID] = cachedFolder{
+			Name:           folder.Name,
+			FolderParentID: folder.FolderParentID,
+		}
 	}
-	// fill the cache
 	for _, sctr := range resources {
-		c.secretCache[sctr.Name] = sctr.ID
+		// the name of v5 resources whose metadata could not be decrypted is unknown
+		if sctr.Name != "" {
+			snapshot.names[sctr.Name] = append(snapshot.names[sctr.Name], sctr.ID)
+		}
+		tags := make([]string, 0, len(sctr.Tags))
+		for _, tag := range sctr.Tags {
+			tags = append(tags, tag.Slug)
+			snapshot.tags[tag.Slug] = append(snapshot.tags[tag.Slug], sctr.ID)
+		}
+		slices.Sort(tags)
+		snapshot.resources[sctr.ID] = cachedResource{
+			Name:           sctr.Name,
+			FolderParentID: sctr.FolderParentID,
+			Tags:           tags,
+		}
+		if sctr.Modified != nil {
+			snapshot.modified[sctr.ID] = sctr.Modified.Time
+		}
 	}
-	return nil
+	for _, ids := range snapshot.names {
+		slices.Sort(ids)
+	}
+	for _, ids := range snapshot.tags {
+		slices.Sort(ids)
+	}
+	return snapshot
+}
+
+// logChanges logs the deleted and renamed resources as well as the names that became ambiguous during a cache sync.
+func logChanges(ctx context.Context, previous, current map[string]cachedResource, changes Changes) {
+	logr := log.FromContext(ctx)
+	for _, id := range changes.Deleted {
+		logr.Info("passbolt resource was deleted", "id", id, "name", previous[id].Name)
+	}
+	for _, id := range changes.Renamed {
+		logr.Info("passbolt resource was renamed", "id", id, "previousName", previous[id].Name, "name", current[id].Name)
+	}
+	for _, name := range ambiguousNames(previous, current) {
+		logr.Info("passbolt resource name became ambiguous, lookups by this name fail", "name", name)
+	}
+}
+
+// ambiguousNames returns the sorted names that are used by multiple resources in current, but were unique or unused in previous.
+func ambiguousNames(previous, current map[string]cachedResource) []string {
+	previousCount := nameCounts(previous)
+	names := []string{}
+	for name, count := range nameCounts(current) {
+		if count > 1 && previousCount[name] <= 1 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// nameCounts returns the number of resources per name.
+func nameCounts(resources map[string]cachedResource) map[string]int {
+	counts := map[string]int{}
+	for _, res := range resources {
+		counts[res.Name]++
+	}
+	return counts
+}
+
+// RegisterChangeHandler registers a handler that is called after each cache sync
+// with the changes of the resources in passbolt since the previous sync.
+func (c *Client) RegisterChangeHandler(handler ChangeHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.changeHandlers = append(c.changeHandlers, handler)
+}
+
+// CacheSyncedAt returns the time of the last successful cache sync.
+// The time is zero if the cache was not loaded yet.
+func (c *Client) CacheSyncedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cacheSyncedAt
+}
+
+// GetModified returns the modified timestamp of the resource with the given ID as seen during the last cache sync.
+func (c *Client) GetModified(id string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	modified, ok := c.modifiedCache[id]
+	return modified, ok
 }
 
 // Close logs out of the passbolt client.
@@ -165,19 +522,88 @@ func (c *Client) LoadCache(ctx context.Context) error {
 func (c *Client) Close(ctx context.Context) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.passboltClient.Logout(ctx)
+	return c.api().Logout(ctx)
+}
+
+// api returns the underlying passbolt client.
+func (c *Client) api() *api.Client {
+	c.apiMu.RLock()
+	defer c.apiMu.RUnlock()
+	return c.passboltClient
+}
+
+// useAPI returns the underlying passbolt client and registers an in-flight call.
+// The returned function must be called once the call finished.
+func (c *Client) useAPI() (*api.Client, func()) {
+	c.apiMu.RLock()
+	defer c.apiMu.RUnlock()
+	if c.calls == nil {
+		return c.passboltClient, func() {}
+	}
+	c.calls.Add(1)
+	return c.passboltClient, c.calls.Done
+}
+
+// UpdateCredentials logs in with the given private key and passphrase and replaces the underlying passbolt client
+// once the login succeeded. The caches and change handlers are kept, so that reconciliations are not interrupted.
+// If the login fails, the previous client is kept.
+// The previous session is closed in the background once the calls still using it finished.
+func (c *Client) UpdateCredentials(ctx context.Context, privateKey, password string) error {
+	clnt, err := api.NewClient(newHTTPClient(), "", c.url, privateKey, password)
+	if err != nil {
+		return fmt.Errorf("failed to create passbolt client: %w", err)
+	}
+	if err := clnt.Login(ctx); err != nil {
+		return fmt.Errorf("failed to login to passbolt: %w", err)
+	}
+	c.loginMu.Lock()
+	c.apiMu.Lock()
+	previous, calls := c.passboltClient, c.calls
+	c.passboltClient, c.calls = clnt, &sync.WaitGroup{}
+	c.apiMu.Unlock()
+	c.sessionGeneration.Add(1)
+	c.loginPending.Store(false)
+	c.loginMu.Unlock()
+	c.recordSuccessfulCall()
+	// the new credentials may not be permitted to access the cached secrets
+	if s := c.secrets.Load(); s != nil {
+		s.clear()
+	}
+	c.metadataKeys.reset()
+	c.principals.reset()
+	go logoutWhenIdle(previous, calls)
+	return nil
+}
+
+// logoutTimeout is the maximum duration of logging out of a replaced session.
+const logoutTimeout = 30 * time.Second
+
+// logoutWhenIdle logs out of the given passbolt client once the given in-flight calls finished.
+// The calls are not awaited if they are not tracked.
+func logoutWhenIdle(clnt *api.Client, calls *sync.WaitGroup) {
+	if calls != nil {
+		calls.Wait()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+	defer cancel()
+	_ = clnt.Logout(ctx)
 }
 
 // GetSecretID retrieves the secret ID for the given secret name from the cache.
+// ErrResourceNotFound and ErrAmbiguousResource are returned if none or more than one resource has the name.
 func (c *Client) GetSecretID(name string) (string, error) {
 	// prevent concurrent access to the cache
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	// check if the secret is in the cache
-	if _, ok := c.secretCache[name]; !ok {
-		return "", fmt.Errorf("unable to find secret in cache with name %q", name)
+	switch ids := c.nameIndex[name]; len(ids) {
+	case 0:
+		return "", fmt.Errorf("unable to find secret in cache with name %q: %w", name, ErrResourceNotFound)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%d secrets in cache with name %q: %w", len(ids), name, ErrAmbiguousResource)
 	}
-	return c.secretCache[name], nil
 }
 
 // GetSecretName retrieves the secret name for the given secret ID from the cache.
@@ -186,47 +612,198 @@ func (c *Client) GetSecretName(id string) (string, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	// check if the secret is in the cache
-	for name, secretID := range c.secretCache {
-		if secretID == id {
+	for name, ids := range c.nameIndex {
+		if slices.Contains(ids, id) {
 			return name, nil
 		}
 	}
 	return "", fmt.Errorf("unable to find secret in cache with id %q", id)
 }
 
-func (c *Client) GetCache() map[string]string {
-	return c.secretCache
+// GetCache returns a copy of the cached NAME -> sorted UUIDs mappings.
+func (c *Client) GetCache() map[string][]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cache := make(map[string][]string, len(c.nameIndex))
+	for name, ids := range c.nameIndex {
+		cache[name] = slices.Clone(ids)
+	}
+	return cache
+}
+
+// indexName adds the resource with the given ID to the name index under the given name.
+// A previous name of the resource is removed from the index.
+// c.mu must be held.
+func (c *Client) indexName(id, name string) {
+	for cachedName, ids := range c.nameIndex {
+		if cachedName == name {
+			continue
+		}
+		if ids = slices.DeleteFunc(ids, func(cachedID string) bool { return cachedID == id }); len(ids) == 0 {
+			delete(c.nameIndex, cachedName)
+		} else {
+			c.nameIndex[cachedName] = ids
+		}
+	}
+	ids := c.nameIndex[name]
+	if i, found := slices.BinarySearch(ids, id); !found {
+		c.nameIndex[name] = slices.Insert(ids, i, id)
+	}
 }
 
 // GetSecret retrieves the secret value for the given secret ID.
+// If the secret cache is enabled, decrypted secrets are served from the cache until their TTL expired
+// or the modified timestamp of the resource changed, and concurrent calls for the same secret are coalesced.
 func (c *Client) GetSecret(ctx context.Context, id string) (*PassboltSecretDefinition, error) {
+	s := c.secrets.Load()
+	if s == nil {
+		return c.getSecret(ctx, id)
+	}
+	modified, _ := c.GetModified(id)
+	return s.get(ctx, id, modified, func() (*PassboltSecretDefinition, error) {
+		// the retrieval is shared with other callers, so it must not be canceled with the context of the first caller
+		return c.getSecret(context.WithoutCancel(ctx), id)
+	})
+}
+
+// GetSecretUncached retrieves the secret value for the given secret ID from passbolt, bypassing the secret cache.
+// It is used when the current state of a resource is required, e.g. to detect changes made in the passbolt UI.
+// The cached secret is discarded, since it may be outdated.
+func (c *Client) GetSecretUncached(ctx context.Context, id string) (*PassboltSecretDefinition, error) {
+	c.invalidateSecret(id)
+	return c.getSecret(ctx, id)
+}
+
+// getSecret retrieves the secret value for the given secret ID from passbolt.
+func (c *Client) getSecret(ctx context.Context, id string) (*PassboltSecretDefinition, error) {
 	passboltSecretGetAttemptsTotal.Inc()
 	// retrieve the secret
-	folderParentID, name, username, uri, pw, description, err := helper.GetResource(ctx, c.passboltClient, id)
+	var secret *PassboltSecretDefinition
+	notFound := false
+	err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		secret, err = getResource(ctx, clnt, &c.metadataKeys, id)
+		notFound = err != nil && lastStatusCode(ctx) == http.StatusNotFound
+		return err
+	})
 	if err != nil {
 		passboltSecretGetFailureAttemptsTotal.Inc()
+		if notFound && c.isResourceNotFound(ctx, id) {
+			return nil, fmt.Errorf("failed to get secret from Passbolt with ID %q: %w: %w", id, ErrResourceNotFound, err)
+		}
 		return nil, fmt.Errorf("failed to get secret from Passbolt with ID %q: %w", id, err)
 	}
-	secret := &PassboltSecretDefinition{
-		Username:       username,
-		URI:            uri,
-		Password:       pw,
-		Description:    description,
-		FolderParentID: folderParentID,
-		Name:           name,
-	}
+	c.recordSuccessfulCall()
 	return secret, nil
 }
 
+// isResourceNotFound confirms that the resource with the given ID does not exist after passbolt answered a request for it
+// with HTTP status 404. Passbolt also answers with 404 if the resource type or secret of a resource is missing,
+// so the resource is only considered missing if a fresh lookup by its ID does not find it either.
+func (c *Client) isResourceNotFound(ctx context.Context, id string) bool {
+	var resources []api.Resource
+	err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		resources, err = clnt.GetResources(ctx, &api.GetResourcesOptions{FilterHasID: []string{id}})
+		return err
+	})
+	if err != nil {
+		return false
+	}
+	for _, res := range resources {
+		if res.ID == id {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateResource creates a new resource in passbolt and returns its ID.
+// The resource is created in the folder defined by FolderParentID.
+func (c *Client) CreateResource(ctx context.Context, def PassboltSecretDefinition) (string, error) {
+	passboltResourceWriteAttemptsTotal.Inc()
+	var id string
+	// the creation consists of multiple requests and is not retried, since the resource may already have been created
+	err := c.withSessionNoRetry(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		id, err = helper.CreateResource(ctx, clnt, def.FolderParentID, def.Name, def.Username, def.URI, def.Password, def.Description)
+		return err
+	})
+	if err != nil {
+		passboltResourceWriteFailureAttemptsTotal.Inc()
+		return "", fmt.Errorf("failed to create resource %q in Passbolt: %w", def.Name, err)
+	}
+	c.recordSuccessfulCall()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexName(id, def.Name)
+	return id, nil
+}
+
+// FindResources returns the sorted IDs of the resources with the given name in the folder with the given ID.
+// An empty folder ID is the root folder. Unlike the lookups of the cache, the resources are retrieved from passbolt,
+// so that resources created since the last cache sync are found.
+func (c *Client) FindResources(ctx context.Context, name, folderID string) ([]string, error) {
+	opts := getResourcesOptions{}
+	if folderID != "" {
+		opts.FilterHasParent = []string{folderID}
+	}
+	var resources []api.Resource
+	err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+		resources, err = c.metadataKeys.getResources(ctx, clnt, opts)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find resource %q in Passbolt: %w", name, err)
+	}
+	c.recordSuccessfulCall()
+	return matchingResources(resources, name, folderID), nil
+}
+
+// matchingResources returns the sorted IDs of the given resources with the given name in the folder with the given ID.
+func matchingResources(resources []api.Resource, name, folderID string) []string {
+	ids := []string{}
+	for _, res := range resources {
+		if res.Name == name && res.FolderParentID == folderID {
+			ids = append(ids, res.ID)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// UpdateResource updates the name, username, URI, password and description of the resource with the given ID.
+// The folder of the resource is not changed.
+func (c *Client) UpdateResource(ctx context.Context, id string, def PassboltSecretDefinition) error {
+	passboltResourceWriteAttemptsTotal.Inc()
+	err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) error {
+		return helper.UpdateResource(ctx, clnt, id, def.Name, def.Username, def.URI, def.Password, def.Description)
+	})
+	// the update may have been applied even if it failed
+	c.invalidateSecret(id)
+	if err != nil {
+		passboltResourceWriteFailureAttemptsTotal.Inc()
+		return fmt.Errorf("failed to update resource with ID %q in Passbolt: %w", id, err)
+	}
+	c.recordSuccessfulCall()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexName(id, def.Name)
+	return nil
+}
+
 // ReLogin logs out of the passbolt client and logs in again.
 // This is useful if the session has expired.
 // This function should be called before any other function.
 func (c *Client) ReLogin(ctx context.Context) error {
-	passboltReLogins.Inc()
-	err := c.passboltClient.Login(ctx)
-	if err != nil {
-		passboltReLoginFailures.Inc()
-		return fmt.Errorf("failed to re-login to passbolt: %w", err)
-	}
-	return nil
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	return c.login(ctx)
+}
+
+// LastSuccessfulCall returns the time of the last successful call to the passbolt API.
+func (c *Client) LastSuccessfulCall() time.Time {
+	return time.Unix(0, c.lastSuccessfulCall.Load())
+}
+
+// recordSuccessfulCall records that a call to the passbolt API succeeded.
+func (c *Client) recordSuccessfulCall() {
+	c.lastSuccessfulCall.Store(time.Now().UnixNano())
 }
@@ -20,6 +20,9 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/passbolt/go-passbolt/api"
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
 )
 
@@ -127,6 +130,7 @@ func TestPassboltSecretDefinition_FieldValue(t *testing.T) {
 		URI            string
 		Password       string
 		Description    string
+		Fields         map[string]string
 	}
 	type args struct {
 		fieldName passboltv1.FieldName
@@ -182,6 +186,54 @@ func TestPassboltSecretDefinition_FieldValue(t *testing.T) {
 			},
 			want: "URI",
 		},
+		{
+			name: "test field name",
+			fields: fields{
+				FolderParentID: "FolderParentID",
+				Name:           "Name",
+				Username:       "Username",
+				Password:       "Password",
+				URI:            "URI",
+				Description:    "Description",
+			},
+			args: args{
+				fieldName: passboltv1.FieldNameName,
+			},
+			want: "Name",
+		},
+		{
+			name: "test field description",
+			fields: fields{
+				FolderParentID: "FolderParentID",
+				Name:           "Name",
+				Username:       "Username",
+				Password:       "Password",
+				URI:            "URI",
+				Description:    "Description",
+			},
+			args: args{
+				fieldName: passboltv1.FieldNameDescription,
+			},
+			want: "Description",
+		},
+		{
+			name: "test custom field",
+			fields: fields{
+				FolderParentID: "FolderParentID",
+				Name:           "Name",
+				Username:       "Username",
+				Password:       "Password",
+				URI:            "URI",
+				Description:    "Description",
+				Fields: map[string]string{
+					"api_token": "Token",
+				},
+			},
+			args: args{
+				fieldName: passboltv1.FieldName("api_token"),
+			},
+			want: "Token",
+		},
 		{
 			name: "test field abc",
 			fields: fields{
@@ -207,6 +259,7 @@ func TestPassboltSecretDefinition_FieldValue(t *testing.T) {
 				URI:            tt.fields.URI,
 				Password:       tt.fields.Password,
 				Description:    tt.fields.Description,
+				Fields:         tt.fields.Fields,
 			}
 			if got := p.FieldValue(tt.args.fieldName); got != tt.want {
 				t.Errorf("PassboltSecretDefinition.FieldValue() = %v, want %v", got, tt.want)
@@ -456,12 +509,13 @@ func TestClient_GetSecret(t *testing.T) {
 
 			got, err := c.GetSecret(tt.args.ctx, tt.args.id)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("Client.GetSecret() error = %v, wantErr %v\nCache data:\n%+v", err, tt.wantErr, tt.fields.client.secretCache)
+				t.Errorf("Client.GetSecret() error = %v, wantErr %v\nCache data:\n%+v", err, tt.wantErr, tt.fields.client.nameIndex)
 				return
 			}
 			if got != nil && tt.want != nil {
-				if *got != *tt.want {
-					t.Errorf("Client.GetSecret() = %+v, want %+v", *got, *tt.want)
+				// the resource type depends on the passbolt version used to create the resource
+				if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(PassboltSecretDefinition{}, "ResourceType")); diff != "" {
+					t.Errorf("Client.GetSecret() mismatch (-want +got):\n%s", diff)
 				}
 			}
 		})
@@ -512,3 +566,56 @@ func TestClient_ReLogin(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_GetModified(t *testing.T) {
+	clnt, err := NewClient(context.Background(), passboltURL, passboltUsername, passboltPassword)
+	if err != nil {
+		t.Fatalf("failed to create passbolt client: %v", err)
+	}
+	defer clnt.Close(context.Background())
+
+	// the cache is empty before the first sync
+	if _, ok := clnt.GetModified("184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"); ok {
+		t.Errorf("Client.GetModified() found resource before cache was loaded")
+	}
+
+	changed := []string{}
+	clnt.RegisterChangeHandler(func(changes Changes) {
+		changed = append(changed, changes.Resources...)
+		changed = append(changed, changes.Folders...)
+		changed = append(changed, changes.Tags...)
+	})
+
+	// load the cache twice, nothing was modified in between
+	for i := 0; i < 2; i++ {
+		if err := clnt.LoadCache(context.Background()); err != nil {
+			t.Fatalf("failed to load cache: %v", err)
+		}
+	}
+
+	if _, ok := clnt.GetModified("184734ea-8be3-4f5a-ba6c-5f4b3c0603e8"); !ok {
+		t.Errorf("Client.GetModified() did not find resource after cache was loaded")
+	}
+	if len(changed) != 0 {
+		t.Errorf("Client.LoadCache() reported changed resources %v, want none", changed)
+	}
+}
+
+func Test_matchingResources(t *testing.T) {
+	resources := []api.Resource{
+		{ID: "c", Name: "db", FolderParentID: "folder"},
+		{ID: "a", Name: "db", FolderParentID: "folder"},
+		{ID: "b", Name: "db"},
+		{ID: "d", Name: "cache", FolderParentID: "folder"},
+	}
+	if diff := cmp.Diff([]string{"a", "c"}, matchingResources(resources, "db", "folder")); diff != "" {
+		t.Errorf("matchingResources() mismatch (-want +got):\n%s", diff)
+	}
+	// the root folder does not include resources of sub folders
+	if diff := cmp.Diff([]string{"b"}, matchingResources(resources, "db", "")); diff != "" {
+		t.Errorf("matchingResources() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{}, matchingResources(resources, "queue", "")); diff != "" {
+		t.Errorf("matchingResources() mismatch (-want +got):\n%s", diff)
+	}
+}
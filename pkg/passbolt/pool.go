@@ -0,0 +1,287 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ServerKey identifies a passbolt server in a Pool.
+type ServerKey struct {
+	// Kind is the kind of the Kubernetes object defining the server.
+	Kind string
+	// Namespace is the namespace of the Kubernetes object defining the server, if it is namespaced.
+	Namespace string
+	// Name is the name of the Kubernetes object defining the server.
+	Name string
+	// Credentials is the name of the secret in Namespace whose credentials are used instead of the credentials of the server.
+	Credentials string
+}
+
+// String returns the key in the form kind/namespace/name or kind/name.
+// If the key uses separate credentials, the secret of the credentials is appended.
+func (k ServerKey) String() string {
+	server := k.Kind + "/" + k.Name
+	if k.Kind == "" {
+		server = "default"
+	} else if k.Namespace != "" && k.Credentials == "" {
+		server = k.Kind + "/" + k.Namespace + "/" + k.Name
+	}
+	if k.Credentials != "" {
+		return server + " as " + k.Namespace + "/" + k.Credentials
+	}
+	return server
+}
+
+// ServerConfig contains everything required to log in to a passbolt server.
+type ServerConfig struct {
+	// URL is the URL of the passbolt server.
+	URL string
+	// PrivateKey is the armored private GPG key of the passbolt user.
+	PrivateKey string
+	// Password is the passphrase of the private key.
+	Password string
+}
+
+// Pool manages logged in clients of multiple passbolt servers.
+// Every client has its own cache that is refreshed periodically until the client is removed from the pool.
+type Pool struct {
+	// mu is used to prevent concurrent access to the servers and change handlers.
+	mu sync.RWMutex
+	// servers are the clients of the pool by server.
+	servers map[ServerKey]*poolEntry
+	// locks serialize the logins and removals of a server, so that concurrent calls of Ensure log in only once.
+	locks map[ServerKey]*serverLock
+	// login creates a logged in client with a loaded cache for the given configuration.
+	login func(ctx context.Context, config ServerConfig) (*Client, error)
+	// changeHandlers create the change handlers that are registered with every client of the pool.
+	changeHandlers []func(clnt *Client) ChangeHandler
+	// refreshInterval is the interval of the cache refreshes.
+	refreshInterval time.Duration
+	// limits restrict the calls of every client of the pool.
+	limits Limits
+	// secretCache configures the cache of decrypted secrets of every client of the pool.
+	secretCache SecretCacheOptions
+}
+
+// poolEntry is a client of a Pool.
+type poolEntry struct {
+	client *Client
+	config ServerConfig
+	// stop stops the cache refresh of the client.
+	stop context.CancelFunc
+}
+
+// serverLock is the lock of a server of a Pool.
+type serverLock struct {
+	mu sync.Mutex
+	// refs is the number of callers holding or waiting for the lock.
+	refs int
+}
+
+// NewPool creates an empty pool whose clients refresh their cache in the given interval.
+func NewPool(refreshInterval time.Duration) *Pool {
+	p := &Pool{
+		servers:         map[ServerKey]*poolEntry{},
+		locks:           map[ServerKey]*serverLock{},
+		refreshInterval: refreshInterval,
+	}
+	p.login = p.newClient
+	return p
+}
+
+// Get returns the client of the given server.
+// The boolean is false if the server is not part of the pool, e.g. because the login failed.
+func (p *Pool) Get(key ServerKey) (*Client, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	entry, ok := p.servers[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.client, true
+}
+
+// Config returns the configuration of the given server.
+// The boolean is false if the server is not part of the pool.
+func (p *Pool) Config(key ServerKey) (ServerConfig, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	entry, ok := p.servers[key]
+	if !ok {
+		return ServerConfig{}, false
+	}
+	return entry.config, true
+}
+
+// Keys returns the keys of all servers of the pool.
+func (p *Pool) Keys() []ServerKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	keys := make([]ServerKey, 0, len(p.servers))
+	for key := range p.servers {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Ensure makes sure that the pool contains a logged in client for the given server using the given configuration.
+// If the server is not part of the pool yet or its configuration changed, a new client is logged in and its cache is loaded
+// before it replaces the previous client. If the login fails, the previous client is kept.
+// Concurrent calls for the same server log in only once and return the same client.
+func (p *Pool) Ensure(ctx context.Context, key ServerKey, config ServerConfig) (*Client, error) {
+	if clnt, ok := p.current(key, config); ok {
+		return clnt, nil
+	}
+	unlock := p.lock(key)
+	defer unlock()
+	// another call may have logged in while waiting for the lock
+	if clnt, ok := p.current(key, config); ok {
+		return clnt, nil
+	}
+
+	clnt, err := p.login(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshCtx, stop := context.WithCancel(context.Background())
+	p.mu.Lock()
+	for _, newHandler := range p.changeHandlers {
+		clnt.RegisterChangeHandler(newHandler(clnt))
+	}
+	previous := p.servers[key]
+	p.servers[key] = &poolEntry{
+		client: clnt,
+		config: config,
+		stop:   stop,
+	}
+	p.mu.Unlock()
+
+	refresher := NewCacheRefresher(clnt, p.refreshInterval, log.Log.WithName("passbolt-pool").WithValues("server", key.String()))
+	go func() {
+		_ = refresher.Start(refreshCtx)
+	}()
+	if previous != nil {
+		previous.close(ctx)
+	}
+	return clnt, nil
+}
+
+// Remove removes the client of the given server from the pool and logs out.
+func (p *Pool) Remove(ctx context.Context, key ServerKey) {
+	unlock := p.lock(key)
+	defer unlock()
+	p.mu.Lock()
+	entry, ok := p.servers[key]
+	delete(p.servers, key)
+	p.mu.Unlock()
+	if ok {
+		entry.close(ctx)
+	}
+}
+
+// current returns the client of the given server if it uses the given configuration.
+func (p *Pool) current(key ServerKey, config ServerConfig) (*Client, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	entry, ok := p.servers[key]
+	if !ok || entry.config != config {
+		return nil, false
+	}
+	return entry.client, true
+}
+
+// lock locks the given server and returns the function that unlocks it again.
+// The lock is dropped once no caller holds or waits for it anymore.
+func (p *Pool) lock(key ServerKey) func() {
+	p.mu.Lock()
+	l, ok := p.locks[key]
+	if !ok {
+		l = &serverLock{}
+		p.locks[key] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(p.locks, key)
+		}
+	}
+}
+
+// newClient logs in to the given server and loads the cache of the new client.
+func (p *Pool) newClient(ctx context.Context, config ServerConfig) (*Client, error) {
+	clnt, err := NewClient(ctx, config.URL, config.PrivateKey, config.Password)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	clnt.SetLimits(p.limits)
+	clnt.SetSecretCache(p.secretCache)
+	p.mu.RUnlock()
+	if err := clnt.LoadCache(ctx); err != nil {
+		_ = clnt.Close(ctx)
+		return nil, err
+	}
+	return clnt, nil
+}
+
+// SetLimits restricts the calls of every client that is added to the pool afterwards.
+// Every client has its own limits.
+func (p *Pool) SetLimits(limits Limits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits = limits
+}
+
+// SetSecretCache configures the cache of decrypted secrets of every client that is added to the pool afterwards.
+// Every client has its own cache.
+func (p *Pool) SetSecretCache(opts SecretCacheOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secretCache = opts
+}
+
+// RegisterChangeHandler registers a change handler with all current and future clients of the pool.
+// The change handler is created for every client, so that it is able to query the cache of the client.
+func (p *Pool) RegisterChangeHandler(newHandler func(clnt *Client) ChangeHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.changeHandlers = append(p.changeHandlers, newHandler)
+	for _, entry := range p.servers {
+		entry.client.RegisterChangeHandler(newHandler(entry.client))
+	}
+}
+
+// close stops the cache refresh and logs out.
+func (e *poolEntry) close(ctx context.Context) {
+	e.stop()
+	if err := e.client.Close(ctx); err != nil {
+		log.Log.WithName("passbolt-pool").Error(err, "failed to log out of passbolt")
+	}
+}
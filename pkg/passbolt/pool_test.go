@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServerKey_String(t *testing.T) {
+	tests := []struct {
+		name string
+		key  ServerKey
+		want string
+	}{
+		{
+			name: "cluster scoped",
+			key:  ServerKey{Kind: "ClusterPassboltServer", Name: "prod"},
+			want: "ClusterPassboltServer/prod",
+		},
+		{
+			name: "namespaced",
+			key:  ServerKey{Kind: "PassboltServer", Namespace: "team-a", Name: "staging"},
+			want: "PassboltServer/team-a/staging",
+		},
+		{
+			name: "default server with separate credentials",
+			key:  ServerKey{Namespace: "team-a", Credentials: "passbolt"},
+			want: "default as team-a/passbolt",
+		},
+		{
+			name: "cluster scoped with separate credentials",
+			key:  ServerKey{Kind: "ClusterPassboltServer", Namespace: "team-a", Name: "prod", Credentials: "passbolt"},
+			want: "ClusterPassboltServer/prod as team-a/passbolt",
+		},
+		{
+			name: "namespaced with separate credentials",
+			key:  ServerKey{Kind: "PassboltServer", Namespace: "team-a", Name: "staging", Credentials: "passbolt"},
+			want: "PassboltServer/staging as team-a/passbolt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.key.String(); got != tt.want {
+				t.Errorf("ServerKey.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPool_Ensure(t *testing.T) {
+	pool := NewPool(time.Minute)
+	key := ServerKey{Kind: "ClusterPassboltServer", Name: "prod"}
+
+	// the login fails because of the invalid private key, the server must not be added to the pool
+	_, err := pool.Ensure(context.Background(), key, ServerConfig{
+		URL:        "http://localhost:1",
+		PrivateKey: "invalid",
+		Password:   "invalid",
+	})
+	if err == nil {
+		t.Fatalf("Pool.Ensure() error = nil, want error")
+	}
+	if _, ok := pool.Get(key); ok {
+		t.Errorf("Pool.Get() found server after failed login")
+	}
+	// removing an unknown server is a no-op
+	pool.Remove(context.Background(), key)
+}
+
+func TestPool_Ensure_Concurrent(t *testing.T) {
+	pool := NewPool(time.Minute)
+	logins := atomic.Int32{}
+	pool.login = func(ctx context.Context, config ServerConfig) (*Client, error) {
+		logins.Add(1)
+		// give the other calls time to wait for the login
+		time.Sleep(50 * time.Millisecond)
+		return &Client{url: config.URL, cacheSyncedAt: time.Now()}, nil
+	}
+	key := ServerKey{Kind: "ClusterPassboltServer", Name: "prod"}
+	config := ServerConfig{URL: "https://passbolt.example.com", PrivateKey: "key", Password: "password"}
+
+	clients := make([]*Client, 10)
+	wg := sync.WaitGroup{}
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clnt, err := pool.Ensure(context.Background(), key, config)
+			if err != nil {
+				t.Errorf("Pool.Ensure() error = %v", err)
+			}
+			clients[i] = clnt
+		}()
+	}
+	wg.Wait()
+
+	if got := logins.Load(); got != 1 {
+		t.Errorf("Pool.Ensure() logged in %d times, want 1", got)
+	}
+	current, ok := pool.Get(key)
+	if !ok {
+		t.Fatalf("Pool.Get() did not find server")
+	}
+	for i, clnt := range clients {
+		if clnt != current {
+			t.Errorf("Pool.Ensure() call %d returned a client that is not part of the pool", i)
+		}
+	}
+	if len(pool.locks) != 0 {
+		t.Errorf("Pool.locks = %v, want no remaining locks", pool.locks)
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// cacheRefreshTimeout is the timeout of a single cache refresh.
+	cacheRefreshTimeout = 30 * time.Second
+	// cacheRefreshMinBackoff is the delay before a failed cache refresh is retried for the first time.
+	// The delay is doubled with every failure, up to the refresh interval.
+	cacheRefreshMinBackoff = 5 * time.Second
+)
+
+// CacheRefresher periodically refreshes the cache of a client.
+// If a refresh fails, the client logs in again and the refresh is retried with exponential backoff.
+// In the meantime, the stale cache is served, so that reconciliations, webhooks and conversions keep working.
+type CacheRefresher struct {
+	client *Client
+	// interval is the interval of the cache refreshes.
+	interval time.Duration
+	// log is the logger of the refresher.
+	log logr.Logger
+	// mu is used to prevent concurrent access to lastErr.
+	mu sync.RWMutex
+	// lastErr is the error of the last refresh, nil if it succeeded.
+	lastErr error
+}
+
+var _ manager.Runnable = &CacheRefresher{}
+var _ manager.LeaderElectionRunnable = &CacheRefresher{}
+
+// NewCacheRefresher creates a refresher that refreshes the cache of the given client in the given interval.
+func NewCacheRefresher(clnt *Client, interval time.Duration, log logr.Logger) *CacheRefresher {
+	return &CacheRefresher{
+		client:   clnt,
+		interval: interval,
+		log:      log,
+	}
+}
+
+// Start refreshes the cache until the context is canceled. It never fails.
+// If the cache was not loaded yet, it is loaded immediately.
+func (r *CacheRefresher) Start(ctx context.Context) error {
+	delay := r.interval
+	if r.client.CacheSyncedAt().IsZero() {
+		delay = 0
+	}
+	backoff := time.Duration(0)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			if err := r.refresh(ctx); err != nil {
+				backoff = nextBackoff(backoff, r.interval)
+				passboltCacheRefreshBackoffs.Inc()
+				r.log.Error(err, "failed to refresh passbolt cache, serving stale cache", "retryIn", backoff.String(), "cacheAge", r.cacheAge().String())
+				timer.Reset(backoff)
+				continue
+			}
+			if backoff > 0 {
+				r.log.Info("recovered passbolt cache refresh")
+			}
+			backoff = 0
+			timer.Reset(r.interval)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The cache is refreshed on all replicas, since the webhooks and conversions use it as well.
+func (r *CacheRefresher) NeedLeaderElection() bool {
+	return false
+}
+
+// refresh refreshes the cache. If the refresh fails, the client logs in again before the refresh is repeated.
+func (r *CacheRefresher) refresh(ctx context.Context) error {
+	ctx, cf := context.WithTimeout(logr.NewContext(ctx, r.log), cacheRefreshTimeout)
+	defer cf()
+	err := r.client.LoadCache(ctx)
+	if err != nil {
+		r.log.Info("failed to refresh passbolt cache, logging in again", "error", err.Error())
+		if err = r.client.ReLogin(ctx); err == nil {
+			err = r.client.LoadCache(ctx)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	return err
+}
+
+// cacheAge returns the time since the last successful cache sync or 0 if the cache was never loaded.
+func (r *CacheRefresher) cacheAge() time.Duration {
+	syncedAt := r.client.CacheSyncedAt()
+	if syncedAt.IsZero() {
+		return 0
+	}
+	return time.Since(syncedAt)
+}
+
+// ReadyChecker returns a readiness check that fails until the cache was loaded once.
+// A stale cache does not make the check fail, since the webhooks and conversions would become unavailable
+// while they are still able to serve the stale cache, see DegradedMetric.
+// It only uses the state of the last refresh, so that probes do not call passbolt.
+func (r *CacheRefresher) ReadyChecker() healthz.Checker {
+	return func(_ *http.Request) error {
+		if !r.client.CacheSyncedAt().IsZero() {
+			return nil
+		}
+		if err := r.lastError(); err != nil {
+			return fmt.Errorf("passbolt cache was not loaded yet: %w", err)
+		}
+		return fmt.Errorf("passbolt cache was not loaded yet")
+	}
+}
+
+// degraded returns an error if the cache refreshes failed and the cache was not refreshed for longer than maxStaleness.
+// A maxStaleness of 0 allows stale caches of any age.
+func (r *CacheRefresher) degraded(maxStaleness time.Duration) error {
+	lastErr := r.lastError()
+	if age := r.cacheAge(); lastErr != nil && maxStaleness > 0 && age > maxStaleness {
+		return fmt.Errorf("passbolt cache is degraded, last successful refresh %s ago: %w", age.Round(time.Second), lastErr)
+	}
+	return nil
+}
+
+// lastError returns the error of the last refresh, nil if it succeeded.
+func (r *CacheRefresher) lastError() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastErr
+}
+
+// DegradedMetric returns a metric that reports 1 if the cache refreshes failed and the cache was not refreshed
+// for longer than maxStaleness, 0 otherwise.
+func (r *CacheRefresher) DegradedMetric(maxStaleness time.Duration) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "passbolt_cache_degraded",
+			Help: "Whether the passbolt cache was not refreshed for longer than the max staleness because the refreshes failed (1) or not (0).",
+		},
+		func() float64 {
+			if r.degraded(maxStaleness) != nil {
+				return 1
+			}
+			return 0
+		},
+	)
+}
+
+// CacheAgeMetric returns a metric reporting the time since the last successful cache sync in seconds.
+func (r *CacheRefresher) CacheAgeMetric() prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "passbolt_cache_age_seconds",
+			Help: "Time since the last successful cache sync with passbolt in seconds.",
+		},
+		func() float64 {
+			return r.cacheAge().Seconds()
+		},
+	)
+}
+
+// nextBackoff returns the delay before the next retry after a failure, given the delay before the failed attempt.
+func nextBackoff(previous, limit time.Duration) time.Duration {
+	next := previous * 2
+	if next < cacheRefreshMinBackoff {
+		next = cacheRefreshMinBackoff
+	}
+	if limit > 0 && next > limit {
+		next = limit
+	}
+	return next
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func Test_nextBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous time.Duration
+		limit    time.Duration
+		want     time.Duration
+	}{
+		{
+			name:  "first failure",
+			limit: 5 * time.Minute,
+			want:  cacheRefreshMinBackoff,
+		},
+		{
+			name:     "doubled",
+			previous: 20 * time.Second,
+			limit:    5 * time.Minute,
+			want:     40 * time.Second,
+		},
+		{
+			name:     "limited to the refresh interval",
+			previous: 4 * time.Minute,
+			limit:    5 * time.Minute,
+			want:     5 * time.Minute,
+		},
+		{
+			name:     "no limit",
+			previous: 4 * time.Minute,
+			want:     8 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextBackoff(tt.previous, tt.limit); got != tt.want {
+				t.Errorf("nextBackoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheRefresher_ReadyChecker(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheSyncedAt time.Time
+		lastErr       error
+		wantErr       bool
+	}{
+		{
+			name:    "cache not loaded yet",
+			wantErr: true,
+		},
+		{
+			name:    "cache not loaded because the login failed",
+			lastErr: errors.New("failed to login"),
+			wantErr: true,
+		},
+		{
+			name:          "fresh cache",
+			cacheSyncedAt: time.Now(),
+		},
+		{
+			name:          "stale cache",
+			cacheSyncedAt: time.Now().Add(-time.Hour),
+			lastErr:       errors.New("unavailable"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCacheRefresher(&Client{cacheSyncedAt: tt.cacheSyncedAt}, time.Minute, logr.Discard())
+			r.lastErr = tt.lastErr
+			if err := r.ReadyChecker()(nil); (err != nil) != tt.wantErr {
+				t.Errorf("ReadyChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCacheRefresher_degraded(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheSyncedAt time.Time
+		lastErr       error
+		maxStaleness  time.Duration
+		wantErr       bool
+	}{
+		{
+			name:          "fresh cache",
+			cacheSyncedAt: time.Now(),
+			maxStaleness:  time.Minute,
+		},
+		{
+			name:          "old cache without failed refresh",
+			cacheSyncedAt: time.Now().Add(-time.Hour),
+			maxStaleness:  time.Minute,
+		},
+		{
+			name:          "stale cache within limit",
+			cacheSyncedAt: time.Now().Add(-time.Minute),
+			lastErr:       errors.New("unavailable"),
+			maxStaleness:  time.Hour,
+		},
+		{
+			name:          "stale cache exceeding limit",
+			cacheSyncedAt: time.Now().Add(-time.Hour),
+			lastErr:       errors.New("unavailable"),
+			maxStaleness:  time.Minute,
+			wantErr:       true,
+		},
+		{
+			name:          "stale cache without limit",
+			cacheSyncedAt: time.Now().Add(-time.Hour),
+			lastErr:       errors.New("unavailable"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCacheRefresher(&Client{cacheSyncedAt: tt.cacheSyncedAt}, time.Minute, logr.Discard())
+			r.lastErr = tt.lastErr
+			if err := r.degraded(tt.maxStaleness); (err != nil) != tt.wantErr {
+				t.Errorf("degraded() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/passbolt/go-passbolt/api"
+)
+
+const (
+	// resourceTypePasswordString is the legacy resource type where the secret only contains the password.
+	resourceTypePasswordString = "password-string"
+	// metadataKeyTypeUser marks v5 metadata that is encrypted with the key of the user.
+	metadataKeyTypeUser = "user_key"
+	// metadataKeyTypeShared marks v5 metadata that is encrypted with a shared metadata key.
+	metadataKeyTypeShared = "shared_key"
+)
+
+// resource is a passbolt resource including the v5 metadata fields, which are not supported by go-passbolt.
+type resource struct {
+	api.Resource
+	// Metadata is the armored and encrypted metadata of a v5 resource.
+	Metadata string `json:"metadata,omitempty"`
+	// MetadataKeyType defines with which key the metadata is encrypted (user_key or shared_key).
+	MetadataKeyType string `json:"metadata_key_type,omitempty"`
+	// MetadataKeyID is the ID of the shared metadata key the metadata is encrypted with.
+	MetadataKeyID string `json:"metadata_key_id,omitempty"`
+}
+
+// resourceMetadata is the decrypted metadata of a v5 resource.
+type resourceMetadata struct {
+	Name         string        `json:"name"`
+	Username     string        `json:"username"`
+	URIs         []string      `json:"uris"`
+	Description  string        `json:"description"`
+	CustomFields []customField `json:"custom_fields"`
+}
+
+// secretData is the decrypted secret of a resource.
+// Depending on the resource type, only some of the fields are set.
+type secretData struct {
+	Password     string        `json:"password"`
+	Description  string        `json:"description"`
+	TOTP         *TOTP         `json:"totp"`
+	CustomFields []customField `json:"custom_fields"`
+}
+
+// customField is a v5 custom field.
+// The key is stored in the metadata, while the value is stored in the secret (or vice versa).
+type customField struct {
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	MetadataKey   string `json:"metadata_key,omitempty"`
+	MetadataValue string `json:"metadata_value,omitempty"`
+	SecretKey     string `json:"secret_key,omitempty"`
+	SecretValue   string `json:"secret_value,omitempty"`
+}
+
+// getResource retrieves and decrypts the resource with the given ID.
+// All requests use the same client, the secret can only be decrypted with the key of the session it was read with.
+// The metadata of v5 resources is decrypted with the key of the user or the shared metadata key of the given keyring.
+func getResource(ctx context.Context, clnt *api.Client, keys *metadataKeyring, id string) (*PassboltSecretDefinition, error) {
+	msg, err := clnt.DoCustomRequest(ctx, "GET", "/resources/"+id+".json", "v2", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource: %w", err)
+	}
+	var res resource
+	if err := json.Unmarshal(msg.Body, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse resource: %w", err)
+	}
+	rType, err := clnt.GetResourceType(ctx, res.ResourceTypeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource type: %w", err)
+	}
+	secret, err := clnt.GetSecret(ctx, res.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource secret: %w", err)
+	}
+	rawSecret, err := clnt.DecryptMessage(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt resource secret: %w", err)
+	}
+
+	def := &PassboltSecretDefinition{
+		FolderParentID: res.FolderParentID,
+		Name:           res.Name,
+		Username:       res.Username,
+		URI:            res.URI,
+		Description:    res.Description,
+		ResourceType:   rType.Slug,
+		Fields:         map[string]string{},
+	}
+
+	// v5 resources store name, username, URIs and the description in the encrypted metadata
+	var customFields []customField
+	if res.Metadata != "" {
+		metadata, err := keys.decryptMetadata(ctx, clnt, res)
+		if err != nil {
+			return nil, err
+		}
+		def.Name = metadata.Name
+		def.Username = metadata.Username
+		def.Description = metadata.Description
+		if len(metadata.URIs) > 0 {
+			def.URI = metadata.URIs[0]
+		}
+		customFields = metadata.CustomFields
+	}
+
+	if err := decodeSecretData(def, rawSecret, customFields); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// decodeSecretData decodes the decrypted secret into the given definition.
+// The custom fields of the metadata are required to resolve the names of the custom fields stored in the secret.
+func decodeSecretData(def *PassboltSecretDefinition, rawSecret string, metadataFields []customField) error {
+	if def.Fields == nil {
+		def.Fields = map[string]string{}
+	}
+	// the legacy resource type does not use JSON at all
+	if def.ResourceType == resourceTypePasswordString {
+		def.Password = rawSecret
+		return nil
+	}
+
+	var data secretData
+	if err := json.Unmarshal([]byte(rawSecret), &data); err != nil {
+		return fmt.Errorf("failed to parse secret of resource type %q: %w", def.ResourceType, err)
+	}
+	def.Password = data.Password
+	if data.Description != "" {
+		def.Description = data.Description
+	}
+	if data.TOTP != nil {
+		if err := data.TOTP.validate(); err != nil {
+			return fmt.Errorf("failed to parse secret of resource type %q: %w", def.ResourceType, err)
+		}
+		def.TOTP = data.TOTP
+	}
+
+	// all additional top level string values of the secret are exposed as fields,
+	// this allows to access the content of resource types we do not know yet.
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(rawSecret), &raw); err != nil {
+		return fmt.Errorf("failed to parse secret of resource type %q: %w", def.ResourceType, err)
+	}
+	for key, value := range raw {
+		str, ok := value.(string)
+		if !ok || isBuiltinField(key) {
+			continue
+		}
+		def.Fields[key] = str
+	}
+
+	// custom fields are split between metadata (key) and secret (value)
+	fields := map[string]customField{}
+	for _, field := range metadataFields {
+		fields[field.ID] = field
+	}
+	for _, field := range data.CustomFields {
+		meta := fields[field.ID]
+		key := firstNonEmpty(meta.MetadataKey, field.MetadataKey, field.SecretKey)
+		if key == "" {
+			key = field.ID
+		}
+		def.Fields[key] = firstNonEmpty(field.SecretValue, meta.MetadataValue)
+		delete(fields, field.ID)
+	}
+	// custom fields without a secret value only exist in the metadata
+	for _, field := range fields {
+		if field.MetadataKey == "" {
+			continue
+		}
+		def.Fields[field.MetadataKey] = field.MetadataValue
+	}
+	return nil
+}
+
+// isBuiltinField reports whether the key of a secret is mapped to a dedicated field of PassboltSecretDefinition.
+func isBuiltinField(key string) bool {
+	switch strings.ToLower(key) {
+	case "password", "description", "totp", "custom_fields", "object_type":
+		return true
+	default:
+		return false
+	}
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_decodeSecretData(t *testing.T) {
+	type args struct {
+		resourceType   string
+		rawSecret      string
+		metadataFields []customField
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *PassboltSecretDefinition
+		wantErr bool
+	}{
+		{
+			name: "password-string",
+			args: args{
+				resourceType: "password-string",
+				rawSecret:    "secret",
+			},
+			want: &PassboltSecretDefinition{
+				ResourceType: "password-string",
+				Password:     "secret",
+				Fields:       map[string]string{},
+			},
+		},
+		{
+			name: "password-and-description",
+			args: args{
+				resourceType: "password-and-description",
+				rawSecret:    `{"password":"secret","description":"my description"}`,
+			},
+			want: &PassboltSecretDefinition{
+				ResourceType: "password-and-description",
+				Password:     "secret",
+				Description:  "my description",
+				Fields:       map[string]string{},
+			},
+		},
+		{
+			name: "unknown top level fields",
+			args: args{
+				resourceType: "v5-note",
+				rawSecret:    `{"object_type":"PASSBOLT_SECRET_DATA","note":"some note","count":1}`,
+			},
+			want: &PassboltSecretDefinition{
+				ResourceType: "v5-note",
+				Fields: map[string]string{
+					"note": "some note",
+				},
+			},
+		},
+		{
+			name: "v5 custom fields",
+			args: args{
+				resourceType: "v5-default",
+				rawSecret: `{
+					"object_type": "PASSBOLT_SECRET_DATA",
+					"password": "secret",
+					"custom_fields": [
+						{"id": "1", "type": "text", "secret_value": "token"},
+						{"id": "2", "type": "text", "secret_value": "unnamed"}
+					]
+				}`,
+				metadataFields: []customField{
+					{ID: "1", Type: "text", MetadataKey: "api_token"},
+					{ID: "3", Type: "text", MetadataKey: "region", MetadataValue: "eu-central-1"},
+				},
+			},
+			want: &PassboltSecretDefinition{
+				ResourceType: "v5-default",
+				Password:     "secret",
+				Fields: map[string]string{
+					"api_token": "token",
+					"2":         "unnamed",
+					"region":    "eu-central-1",
+				},
+			},
+		},
+		{
+			name: "password-description-totp",
+			args: args{
+				resourceType: "password-description-totp",
+				rawSecret:    `{"password":"secret","description":"","totp":{"secret_key":"jbswy3dpehpk3pxp","algorithm":"sha1","digits":6,"period":30}}`,
+			},
+			want: &PassboltSecretDefinition{
+				ResourceType: "password-description-totp",
+				Password:     "secret",
+				TOTP: &TOTP{
+					SecretKey: "JBSWY3DPEHPK3PXP",
+					Algorithm: "SHA1",
+					Digits:    6,
+					Period:    30,
+				},
+				Fields: map[string]string{},
+			},
+		},
+		{
+			name: "invalid json",
+			args: args{
+				resourceType: "password-and-description",
+				rawSecret:    "secret",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &PassboltSecretDefinition{
+				ResourceType: tt.args.resourceType,
+			}
+			err := decodeSecretData(got, tt.args.rawSecret, tt.args.metadataFields)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeSecretData() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("decodeSecretData() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
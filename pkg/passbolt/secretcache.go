@@ -0,0 +1,235 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	passboltSecretCacheHitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_secret_cache_hits_total",
+			Help: "Number of secrets served from the decrypted secret cache.",
+		},
+	)
+	passboltSecretCacheMissesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_secret_cache_misses_total",
+			Help: "Number of secrets that had to be retrieved from passbolt because they were not cached.",
+		},
+	)
+	passboltSecretCacheCoalescedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "passbolt_secret_cache_coalesced_total",
+			Help: "Number of secrets that were not cached but shared the retrieval of a concurrent call.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		passboltSecretCacheHitsTotal,
+		passboltSecretCacheMissesTotal,
+		passboltSecretCacheCoalescedTotal,
+	)
+}
+
+// SecretCacheOptions configures the cache of decrypted secrets.
+type SecretCacheOptions struct {
+	// TTL is the time a decrypted secret is cached. 0 disables the cache.
+	TTL time.Duration
+	// MaxEntries is the maximum number of cached secrets. 0 does not limit the number of secrets.
+	MaxEntries int
+}
+
+// secretCache caches decrypted secrets by resource ID.
+// A cached secret is valid until its TTL expired or the modified timestamp of the resource changed.
+// Concurrent retrievals of the same secret are coalesced into a single call.
+type secretCache struct {
+	ttl        time.Duration
+	maxEntries int
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+
+	// mu is used to prevent concurrent access to the entries and calls.
+	mu sync.Mutex
+	// entries are the cached secrets by resource ID.
+	entries map[string]secretCacheEntry
+	// calls are the retrievals in flight by resource ID.
+	calls map[string]*secretCall
+}
+
+// secretCacheEntry is a decrypted secret of a secretCache.
+type secretCacheEntry struct {
+	secret PassboltSecretDefinition
+	// modified is the modified timestamp of the resource when the secret was retrieved.
+	modified time.Time
+	expires  time.Time
+}
+
+// secretCall is a retrieval of a secret that concurrent callers wait for.
+type secretCall struct {
+	// modified is the modified timestamp of the resource when the retrieval started.
+	modified time.Time
+	// done is closed once secret and err are set.
+	done   chan struct{}
+	secret *PassboltSecretDefinition
+	err    error
+}
+
+// newSecretCache creates a cache using the given options.
+func newSecretCache(opts SecretCacheOptions) *secretCache {
+	return &secretCache{
+		ttl:        opts.TTL,
+		maxEntries: opts.MaxEntries,
+		now:        time.Now,
+		entries:    map[string]secretCacheEntry{},
+		calls:      map[string]*secretCall{},
+	}
+}
+
+// get returns the secret of the given resource, which has the given modified timestamp.
+// If the secret is not cached, it is retrieved with fetch, unless a retrieval for the same modified timestamp is in flight already.
+// Every caller gets its own copy of the secret.
+func (s *secretCache) get(ctx context.Context, id string, modified time.Time, fetch func() (*PassboltSecretDefinition, error)) (*PassboltSecretDefinition, error) {
+	s.mu.Lock()
+	if entry, ok := s.entries[id]; ok {
+		if entry.modified.Equal(modified) && s.now().Before(entry.expires) {
+			s.mu.Unlock()
+			passboltSecretCacheHitsTotal.Inc()
+			return copySecret(entry.secret), nil
+		}
+		delete(s.entries, id)
+	}
+	if call, ok := s.calls[id]; ok && call.modified.Equal(modified) {
+		s.mu.Unlock()
+		passboltSecretCacheCoalescedTotal.Inc()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copySecret(*call.secret), nil
+	}
+	passboltSecretCacheMissesTotal.Inc()
+	call := &secretCall{
+		modified: modified,
+		done:     make(chan struct{}),
+	}
+	s.calls[id] = call
+	s.mu.Unlock()
+
+	call.secret, call.err = fetch()
+
+	s.mu.Lock()
+	// the call was replaced or invalidated in the meantime, so the secret may be outdated already
+	if s.calls[id] == call {
+		delete(s.calls, id)
+		if call.err == nil {
+			s.add(id, secretCacheEntry{
+				secret:   *call.secret,
+				modified: modified,
+				expires:  s.now().Add(s.ttl),
+			})
+		}
+	}
+	s.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return copySecret(*call.secret), nil
+}
+
+// add caches the given entry.
+// If the cache is full, expired entries are removed and, if that is not sufficient, the entry expiring first.
+// s.mu must be held.
+func (s *secretCache) add(id string, entry secretCacheEntry) {
+	if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		now := s.now()
+		oldestID := ""
+		for cachedID, cached := range s.entries {
+			if !now.Before(cached.expires) {
+				delete(s.entries, cachedID)
+				continue
+			}
+			if oldestID == "" || cached.expires.Before(s.entries[oldestID].expires) {
+				oldestID = cachedID
+			}
+		}
+		if len(s.entries) >= s.maxEntries {
+			delete(s.entries, oldestID)
+		}
+	}
+	s.entries[id] = entry
+}
+
+// invalidate removes the secret of the given resource from the cache.
+// The result of a retrieval in flight is not cached.
+func (s *secretCache) invalidate(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	delete(s.calls, id)
+}
+
+// clear removes all secrets from the cache.
+// The results of the retrievals in flight are not cached.
+func (s *secretCache) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = map[string]secretCacheEntry{}
+	s.calls = map[string]*secretCall{}
+}
+
+// copySecret returns a copy of the given secret, so that callers cannot modify the cached secret.
+func copySecret(secret PassboltSecretDefinition) *PassboltSecretDefinition {
+	secret.Fields = maps.Clone(secret.Fields)
+	if secret.TOTP != nil {
+		totp := *secret.TOTP
+		secret.TOTP = &totp
+	}
+	return &secret
+}
+
+// SetSecretCache configures the cache of decrypted secrets of the client.
+// Previously cached secrets are discarded.
+func (c *Client) SetSecretCache(opts SecretCacheOptions) {
+	if opts.TTL <= 0 {
+		c.secrets.Store(nil)
+		return
+	}
+	c.secrets.Store(newSecretCache(opts))
+}
+
+// invalidateSecret removes the decrypted secret of the given resource from the cache of the client.
+func (c *Client) invalidateSecret(id string) {
+	if s := c.secrets.Load(); s != nil {
+		s.invalidate(id)
+	}
+}
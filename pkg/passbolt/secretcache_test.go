@@ -0,0 +1,235 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/passbolt/go-passbolt/api"
+)
+
+func TestSecretCache_get(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// advance is the time between the first and the second call
+		advance time.Duration
+		// modified is the modified timestamp of the resource at the second call
+		modified  time.Time
+		wantCalls int32
+	}{
+		{
+			name:      "cached",
+			advance:   30 * time.Second,
+			modified:  modified,
+			wantCalls: 1,
+		},
+		{
+			name:      "expired",
+			advance:   time.Minute,
+			modified:  modified,
+			wantCalls: 2,
+		},
+		{
+			name:      "modified",
+			advance:   time.Second,
+			modified:  modified.Add(time.Hour),
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			s := newSecretCache(SecretCacheOptions{TTL: time.Minute})
+			s.now = func() time.Time { return now }
+			var calls atomic.Int32
+			fetch := func() (*PassboltSecretDefinition, error) {
+				calls.Add(1)
+				return &PassboltSecretDefinition{Name: "postgres", Password: "secret"}, nil
+			}
+			if _, err := s.get(context.Background(), "id", modified, fetch); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(tt.advance)
+			got, err := s.get(context.Background(), "id", tt.modified, fetch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Password != "secret" {
+				t.Errorf("get() password = %q, want %q", got.Password, "secret")
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("get() fetched %d times, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestSecretCache_getCoalesced(t *testing.T) {
+	s := newSecretCache(SecretCacheOptions{TTL: time.Minute})
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func() (*PassboltSecretDefinition, error) {
+		calls.Add(1)
+		<-release
+		return &PassboltSecretDefinition{Password: "secret"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.get(context.Background(), "id", time.Time{}, fetch)
+			if err != nil || got.Password != "secret" {
+				t.Errorf("get() = %v, %v, want secret", got, err)
+			}
+		}()
+	}
+	// wait until all callers are waiting for the retrieval in flight
+	for {
+		s.mu.Lock()
+		_, inFlight := s.calls["id"]
+		s.mu.Unlock()
+		if inFlight {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Errorf("get() fetched %d times, want 1", calls.Load())
+	}
+}
+
+func TestSecretCache_getError(t *testing.T) {
+	s := newSecretCache(SecretCacheOptions{TTL: time.Minute})
+	errFetch := errors.New("unavailable")
+	if _, err := s.get(context.Background(), "id", time.Time{}, func() (*PassboltSecretDefinition, error) {
+		return nil, errFetch
+	}); !errors.Is(err, errFetch) {
+		t.Fatalf("get() error = %v, want %v", err, errFetch)
+	}
+	// errors are not cached
+	got, err := s.get(context.Background(), "id", time.Time{}, func() (*PassboltSecretDefinition, error) {
+		return &PassboltSecretDefinition{Password: "secret"}, nil
+	})
+	if err != nil || got.Password != "secret" {
+		t.Errorf("get() = %v, %v, want secret", got, err)
+	}
+}
+
+func TestSecretCache_invalidate(t *testing.T) {
+	s := newSecretCache(SecretCacheOptions{TTL: time.Minute})
+	// the secret is invalidated while it is retrieved, e.g. because it was updated concurrently
+	if _, err := s.get(context.Background(), "id", time.Time{}, func() (*PassboltSecretDefinition, error) {
+		s.invalidate("id")
+		return &PassboltSecretDefinition{Password: "old"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.get(context.Background(), "id", time.Time{}, func() (*PassboltSecretDefinition, error) {
+		return &PassboltSecretDefinition{Password: "new"}, nil
+	})
+	if err != nil || got.Password != "new" {
+		t.Errorf("get() = %v, %v, want new", got, err)
+	}
+}
+
+func TestClient_GetSecretUncached(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"header":{"status":"error","code":500},"body":{}}`)
+	}))
+	defer server.Close()
+	clnt, err := api.NewClient(newHTTPClient(), "", server.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{passboltClient: clnt, calls: &sync.WaitGroup{}}
+	c.SetSecretCache(SecretCacheOptions{TTL: time.Minute})
+	if _, err := c.secrets.Load().get(context.Background(), "id", time.Time{}, func() (*PassboltSecretDefinition, error) {
+		return &PassboltSecretDefinition{Password: "cached"}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached secret is neither returned nor kept
+	if got, err := c.GetSecretUncached(context.Background(), "id"); err == nil {
+		t.Errorf("GetSecretUncached() = %v, want error", got)
+	}
+	if got, err := c.GetSecret(context.Background(), "id"); err == nil {
+		t.Errorf("GetSecret() = %v, want error", got)
+	}
+	if requests.Load() == 0 {
+		t.Error("GetSecretUncached() did not request passbolt")
+	}
+}
+
+func TestSecretCache_maxEntries(t *testing.T) {
+	now := time.Now()
+	s := newSecretCache(SecretCacheOptions{TTL: time.Minute, MaxEntries: 2})
+	s.now = func() time.Time { return now }
+	fetch := func() (*PassboltSecretDefinition, error) {
+		return &PassboltSecretDefinition{}, nil
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := s.get(context.Background(), id, time.Time{}, fetch); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+	if len(s.entries) != 2 {
+		t.Errorf("len(entries) = %d, want 2", len(s.entries))
+	}
+	// the secret expiring first is evicted
+	if _, ok := s.entries["a"]; ok {
+		t.Errorf("entries contain %q, want it to be evicted", "a")
+	}
+}
+
+func TestSecretCache_copy(t *testing.T) {
+	s := newSecretCache(SecretCacheOptions{TTL: time.Minute})
+	fetch := func() (*PassboltSecretDefinition, error) {
+		return &PassboltSecretDefinition{Fields: map[string]string{"key": "value"}}, nil
+	}
+	got, err := s.get(context.Background(), "id", time.Time{}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// modifying a returned secret must not modify the cached secret
+	got.Fields["key"] = "modified"
+	got, err = s.get(context.Background(), "id", time.Time{}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Fields["key"] != "value" {
+		t.Errorf("get() field = %q, want %q", got.Fields["key"], "value")
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/passbolt/go-passbolt/api"
+)
+
+// withSession calls the given function with the underlying passbolt client within the limits of the client.
+// If the call fails because the session expired, the client logs in again and the call is retried once.
+// Concurrent calls failing with the same expired session share a single re-login.
+// If the client did not log in yet, it logs in before the call.
+// The given function must use the given context for its requests, so that expired sessions are detected.
+func (c *Client) withSession(ctx context.Context, call func(ctx context.Context, clnt *api.Client) error) error {
+	return c.callWithSession(ctx, true, call)
+}
+
+// withSessionNoRetry calls the given function like withSession, but does not retry it if the session expired.
+// It is used for calls that are not idempotent, since they may have been applied partially before the session expired.
+// The client still logs in again, so that the next call succeeds.
+func (c *Client) withSessionNoRetry(ctx context.Context, call func(ctx context.Context, clnt *api.Client) error) error {
+	return c.callWithSession(ctx, false, call)
+}
+
+// callWithSession calls the given function and logs in again if passbolt rejected a request as unauthorized.
+// The call is retried once after the login if retry is true.
+func (c *Client) callWithSession(ctx context.Context, retry bool, call func(ctx context.Context, clnt *api.Client) error) error {
+	if err := c.ensureLogin(ctx); err != nil {
+		return err
+	}
+	generation := c.sessionGeneration.Load()
+	callCtx, status := withResponseStatus(ctx)
+	clnt, done := c.useAPI()
+	err := c.limited(callCtx, clnt, call)
+	done()
+	if err == nil || !status.unauthorized() {
+		return err
+	}
+	if loginErr := c.renewSession(ctx, generation); loginErr != nil {
+		return fmt.Errorf("%w (%w)", err, loginErr)
+	}
+	if !retry {
+		return err
+	}
+	callCtx, _ = withResponseStatus(ctx)
+	clnt, done = c.useAPI()
+	defer done()
+	return c.limited(callCtx, clnt, call)
+}
+
+// limited calls the given function once the call is allowed by the limits of the client.
+func (c *Client) limited(ctx context.Context, clnt *api.Client, call func(ctx context.Context, clnt *api.Client) error) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for passbolt rate limit: %w", err)
+	}
+	defer release()
+	return call(ctx, clnt)
+}
+
+// renewSession logs in again, unless another call already logged in since the given session generation.
+func (c *Client) renewSession(ctx context.Context, generation uint64) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if c.sessionGeneration.Load() != generation {
+		return nil
+	}
+	return c.login(ctx)
+}
+
+// ensureLogin logs in, unless the client already logged in once.
+func (c *Client) ensureLogin(ctx context.Context) error {
+	if !c.loginPending.Load() {
+		return nil
+	}
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if !c.loginPending.Load() {
+		return nil
+	}
+	return c.login(ctx)
+}
+
+// login logs in again and starts a new session generation. The caller must hold loginMu.
+func (c *Client) login(ctx context.Context) error {
+	passboltReLogins.Inc()
+	if err := c.api().Login(ctx); err != nil {
+		passboltReLoginFailures.Inc()
+		return fmt.Errorf("failed to re-login to passbolt: %w", err)
+	}
+	c.sessionGeneration.Add(1)
+	c.loginPending.Store(false)
+	c.recordSuccessfulCall()
+	return nil
+}
+
+// newHTTPClient returns the HTTP client of the passbolt clients.
+// It records the status codes of the responses for the calls of withSession.
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: statusTransport{next: http.DefaultTransport}}
+}
+
+// responseStatusKey is the context key of the responseStatus of a call.
+type responseStatusKey struct{}
+
+// responseStatus records the HTTP status code of the last response of a call.
+type responseStatus struct {
+	code atomic.Int32
+}
+
+// withResponseStatus returns a context recording the HTTP status codes of the responses to its requests.
+func withResponseStatus(ctx context.Context) (context.Context, *responseStatus) {
+	status := &responseStatus{}
+	return context.WithValue(ctx, responseStatusKey{}, status), status
+}
+
+// lastStatusCode returns the HTTP status code of the last response to the requests of the given context of a call
+// of withSession, 0 if no response was received.
+func lastStatusCode(ctx context.Context) int {
+	status, ok := ctx.Value(responseStatusKey{}).(*responseStatus)
+	if !ok {
+		return 0
+	}
+	return int(status.code.Load())
+}
+
+// unauthorized returns true if passbolt rejected the last request of the call because the session is not valid.
+func (s *responseStatus) unauthorized() bool {
+	return s.code.Load() == http.StatusUnauthorized
+}
+
+// statusTransport records the status codes of the responses in the responseStatus of the request context.
+type statusTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if status, ok := req.Context().Value(responseStatusKey{}).(*responseStatus); ok && resp != nil {
+		status.code.Store(int32(resp.StatusCode))
+	}
+	return resp, err
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/passbolt/go-passbolt/api"
+)
+
+func TestStatusTransport(t *testing.T) {
+	code := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name             string
+		code             int
+		wantUnauthorized bool
+	}{
+		{
+			name: "success",
+			code: http.StatusOK,
+		},
+		{
+			name:             "unauthorized",
+			code:             http.StatusUnauthorized,
+			wantUnauthorized: true,
+		},
+		{
+			name: "forbidden",
+			code: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code = tt.code
+			ctx, status := withResponseStatus(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := newHTTPClient().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := status.unauthorized(); got != tt.wantUnauthorized {
+				t.Errorf("unauthorized() = %v, want %v", got, tt.wantUnauthorized)
+			}
+		})
+	}
+}
+
+func TestClient_withSession(t *testing.T) {
+	var responses []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/resources.json") || len(responses) == 0 {
+			// logging in fails
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"header":{"status":"error","code":401},"body":{}}`)
+			return
+		}
+		code := responses[0]
+		responses = responses[1:]
+		w.WriteHeader(code)
+		if code == http.StatusOK {
+			fmt.Fprint(w, `{"header":{"status":"success","code":200},"body":[]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"header":{"status":"error","code":%d},"body":{}}`, code)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		// responses are the status codes of the consecutive calls, renew simulates a concurrent login after the first call
+		responses []int
+		renew     bool
+		noRetry   bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "success",
+			responses: []int{http.StatusOK},
+			wantCalls: 1,
+		},
+		{
+			name:      "other errors are not retried",
+			responses: []int{http.StatusInternalServerError, http.StatusOK},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "missing permissions are not retried",
+			responses: []int{http.StatusForbidden, http.StatusOK},
+			renew:     true,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "retried after concurrent login",
+			responses: []int{http.StatusUnauthorized, http.StatusOK},
+			renew:     true,
+			wantCalls: 2,
+		},
+		{
+			name:      "retried only once",
+			responses: []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusOK},
+			renew:     true,
+			wantCalls: 2,
+			wantErr:   true,
+		},
+		{
+			name:      "failed login",
+			responses: []int{http.StatusUnauthorized, http.StatusOK},
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		{
+			name:      "not idempotent calls are not retried",
+			responses: []int{http.StatusUnauthorized, http.StatusOK},
+			renew:     true,
+			noRetry:   true,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			responses = tt.responses
+			clnt, err := api.NewClient(newHTTPClient(), "", server.URL, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &Client{passboltClient: clnt, calls: &sync.WaitGroup{}}
+			calls := 0
+			call := func(ctx context.Context, clnt *api.Client) error {
+				calls++
+				if calls == 1 && tt.renew {
+					c.sessionGeneration.Add(1)
+				}
+				_, err := clnt.DoCustomRequest(ctx, http.MethodGet, "/resources.json", "v2", nil, nil)
+				return err
+			}
+			if tt.noRetry {
+				err = c.withSessionNoRetry(context.Background(), call)
+			} else {
+				err = c.withSession(context.Background(), call)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("withSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("withSession() calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLogoutWhenIdle(t *testing.T) {
+	var logouts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/auth/logout.json") {
+			logouts.Add(1)
+		}
+		fmt.Fprint(w, `{"header":{"status":"success"},"body":{}}`)
+	}))
+	defer server.Close()
+
+	clnt, err := api.NewClient(server.Client(), "", server.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{passboltClient: clnt, calls: &sync.WaitGroup{}}
+	_, done := c.useAPI()
+
+	loggedOut := make(chan struct{})
+	go func() {
+		logoutWhenIdle(clnt, c.calls)
+		close(loggedOut)
+	}()
+	select {
+	case <-loggedOut:
+		t.Fatal("logoutWhenIdle() returned while a call was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := logouts.Load(); got != 0 {
+		t.Fatalf("logouts while a call was in flight = %d, want 0", got)
+	}
+
+	done()
+	select {
+	case <-loggedOut:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logoutWhenIdle() did not return after the call finished")
+	}
+	if got := logouts.Load(); got != 1 {
+		t.Errorf("logouts = %d, want 1", got)
+	}
+}
+
+func TestNewLazyClient(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, err := NewLazyClient(server.URL, "", "")
+	if err != nil {
+		t.Fatalf("NewLazyClient() error = %v", err)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("requests after NewLazyClient() = %d, want 0", got)
+	}
+	called := false
+	err = c.withSession(context.Background(), func(_ context.Context, _ *api.Client) error {
+		called = true
+		return nil
+	})
+	if err == nil {
+		t.Error("withSession() error = nil, want login error")
+	}
+	if called {
+		t.Error("withSession() called the function without a login")
+	}
+	if !c.loginPending.Load() {
+		t.Error("loginPending = false after a failed login, want true")
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/passbolt/go-passbolt/api"
+	"github.com/passbolt/go-passbolt/helper"
+)
+
+const (
+	// PermissionRead allows to read a resource.
+	PermissionRead = 1
+	// PermissionUpdate allows to read and update a resource.
+	PermissionUpdate = 7
+	// PermissionOwner allows to read, update, delete and share a resource.
+	PermissionOwner = 15
+
+	// permissionDelete deletes an existing permission.
+	permissionDelete = -1
+	// aroUser is the ARO type of passbolt users.
+	aroUser = "User"
+	// aroGroup is the ARO type of passbolt groups.
+	aroGroup = "Group"
+)
+
+// Share defines a permission on a resource that is granted to a passbolt user or group.
+// Exactly one of User and Group must be set.
+type Share struct {
+	// User is the username (email address) of the passbolt user.
+	User string
+	// Group is the name of the passbolt group.
+	Group string
+	// Permission is one of PermissionRead, PermissionUpdate or PermissionOwner.
+	Permission int
+}
+
+// ShareResource reconciles the permissions of the resource with the given ID.
+// All shares are granted with the given permission. Shares that were granted before (previous)
+// but are no longer listed are revoked. Permissions that were neither granted nor listed by the caller
+// (e.g. granted manually in the UI) are kept, and the permission of the operator itself is never changed.
+func (c *Client) ShareResource(ctx context.Context, id string, shares, previous []Share) error {
+	if len(shares) == 0 && len(previous) == 0 {
+		return nil
+	}
+	resolver, err := c.newShareResolver(ctx, shares, previous)
+	if err != nil {
+		return err
+	}
+	desired := []helper.ShareOperation{}
+	for _, share := range shares {
+		op, err := resolver.resolve(share)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, op)
+	}
+	revoked := []helper.ShareOperation{}
+	for _, share := range previous {
+		// users and groups that do not exist anymore have no permissions that could be revoked
+		if op, err := resolver.resolve(share); err == nil {
+			revoked = append(revoked, op)
+		}
+	}
+
+	err = c.withSession(ctx, func(ctx context.Context, clnt *api.Client) error {
+		permissions, err := clnt.GetResourcePermissions(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get permissions of resource with ID %q: %w", id, err)
+		}
+		changes, err := shareOperations(permissions, desired, revoked, clnt.GetUserID())
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := helper.ShareResource(ctx, clnt, id, changes); err != nil {
+			return fmt.Errorf("failed to share resource with ID %q: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.recordSuccessfulCall()
+	return nil
+}
+
+// shareResolver resolves the names of users and groups to their IDs.
+type shareResolver struct {
+	users  map[string]string
+	groups map[string]string
+}
+
+// principalCacheTTL is the duration the users and groups used to resolve shares are cached.
+// They are retrieved again before, if a share refers to a user or group that is not cached.
+const principalCacheTTL = 5 * time.Minute
+
+// principalCache caches the IDs of the passbolt users and groups, so that shares can be resolved
+// without retrieving all users and groups during every sync.
+type principalCache struct {
+	mu     sync.Mutex
+	users  principalIDs
+	groups principalIDs
+}
+
+// reset forgets the cached users and groups, e.g. because the credentials changed.
+func (p *principalCache) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users = principalIDs{}
+	p.groups = principalIDs{}
+}
+
+// principalIDs maps the names of users or groups to their IDs.
+type principalIDs struct {
+	ids      map[string]string
+	loadedAt time.Time
+}
+
+// valid reports whether the IDs were loaded within the TTL and contain all given names.
+func (p principalIDs) valid(now time.Time, names []string) bool {
+	if p.ids == nil || now.Sub(p.loadedAt) > principalCacheTTL {
+		return false
+	}
+	for _, name := range names {
+		if _, ok := p.ids[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newShareResolver returns a resolver for the users and groups of the given shares and the previous shares.
+// The users and groups are retrieved if they are not cached yet, the previous shares do not cause a retrieval
+// on their own, since their users and groups may have been deleted.
+func (c *Client) newShareResolver(ctx context.Context, shares, previous []Share) (*shareResolver, error) {
+	var users, groups []string
+	var needUsers, needGroups bool
+	for _, share := range shares {
+		if share.User != "" {
+			users = append(users, share.User)
+		}
+		if share.Group != "" {
+			groups = append(groups, share.Group)
+		}
+	}
+	for _, share := range append(slices.Clone(shares), previous...) {
+		needUsers = needUsers || share.User != ""
+		needGroups = needGroups || share.Group != ""
+	}
+
+	// the lock is held while the users and groups are retrieved, so that concurrent syncs retrieve them only once
+	c.principals.mu.Lock()
+	defer c.principals.mu.Unlock()
+	now := time.Now()
+	if needUsers && !c.principals.users.valid(now, users) {
+		var list []api.User
+		err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+			list, err = clnt.GetUsers(ctx, nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users: %w", err)
+		}
+		ids := make(map[string]string, len(list))
+		for _, user := range list {
+			ids[user.Username] = user.ID
+		}
+		c.principals.users = principalIDs{ids: ids, loadedAt: now}
+	}
+	if needGroups && !c.principals.groups.valid(now, groups) {
+		var list []api.Group
+		err := c.withSession(ctx, func(ctx context.Context, clnt *api.Client) (err error) {
+			list, err = clnt.GetGroups(ctx, nil)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get groups: %w", err)
+		}
+		ids := make(map[string]string, len(list))
+		for _, group := range list {
+			ids[group.Name] = group.ID
+		}
+		c.principals.groups = principalIDs{ids: ids, loadedAt: now}
+	}
+	return &shareResolver{
+		users:  c.principals.users.ids,
+		groups: c.principals.groups.ids,
+	}, nil
+}
+
+// resolve returns the share operation granting the permission of the given share.
+func (r *shareResolver) resolve(share Share) (helper.ShareOperation, error) {
+	switch {
+	case share.User != "" && share.Group != "":
+		return helper.ShareOperation{}, fmt.Errorf("share must not define both user %q and group %q", share.User, share.Group)
+	case share.User != "":
+		id, ok := r.users[share.User]
+		if !ok {
+			return helper.ShareOperation{}, fmt.Errorf("unable to find user with username %q", share.User)
+		}
+		return helper.ShareOperation{Type: share.Permission, ARO: aroUser, AROID: id}, nil
+	case share.Group != "":
+		id, ok := r.groups[share.Group]
+		if !ok {
+			return helper.ShareOperation{}, fmt.Errorf("unable to find group with name %q", share.Group)
+		}
+		return helper.ShareOperation{Type: share.Permission, ARO: aroGroup, AROID: id}, nil
+	default:
+		return helper.ShareOperation{}, fmt.Errorf("share must define either a user or a group")
+	}
+}
+
+// shareOperations returns the operations required to get from the current permissions to the desired ones.
+// Revoked permissions are deleted if they are not desired anymore. The permission of self is never changed.
+func shareOperations(current []api.Permission, desired, revoked []helper.ShareOperation, self string) ([]helper.ShareOperation, error) {
+	key := func(aro, aroID string) string {
+		return aro + "/" + aroID
+	}
+	existing := map[string]int{}
+	for _, permission := range current {
+		existing[key(permission.ARO, permission.AROForeignKey)] = permission.Type
+	}
+
+	wanted := map[string]bool{}
+	changes := []helper.ShareOperation{}
+	for _, op := range desired {
+		k := key(op.ARO, op.AROID)
+		if wanted[k] {
+			return nil, fmt.Errorf("%s %q is shared more than once", op.ARO, op.AROID)
+		}
+		wanted[k] = true
+		if op.ARO == aroUser && op.AROID == self {
+			continue
+		}
+		if existing[k] == op.Type {
+			continue
+		}
+		changes = append(changes, op)
+	}
+	for _, op := range revoked {
+		k := key(op.ARO, op.AROID)
+		if wanted[k] || (op.ARO == aroUser && op.AROID == self) {
+			continue
+		}
+		if _, ok := existing[k]; !ok {
+			continue
+		}
+		wanted[k] = true
+		changes = append(changes, helper.ShareOperation{
+			Type:  permissionDelete,
+			ARO:   op.ARO,
+			AROID: op.AROID,
+		})
+	}
+	// sort the operations to get a deterministic share request
+	slices.SortFunc(changes, func(a, b helper.ShareOperation) int {
+		return strings.Compare(key(a.ARO, a.AROID), key(b.ARO, b.AROID))
+	})
+	return changes, nil
+}
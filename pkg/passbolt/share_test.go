@@ -0,0 +1,266 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/passbolt/go-passbolt/api"
+	"github.com/passbolt/go-passbolt/helper"
+)
+
+func Test_shareOperations(t *testing.T) {
+	current := []api.Permission{
+		{ARO: "User", AROForeignKey: "operator", Type: PermissionOwner},
+		{ARO: "User", AROForeignKey: "alice", Type: PermissionRead},
+		{ARO: "Group", AROForeignKey: "developers", Type: PermissionRead},
+		{ARO: "Group", AROForeignKey: "admins", Type: PermissionOwner},
+	}
+	type args struct {
+		desired []helper.ShareOperation
+		revoked []helper.ShareOperation
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []helper.ShareOperation
+		wantErr bool
+	}{
+		{
+			name: "nothing to do",
+			args: args{
+				desired: []helper.ShareOperation{
+					{Type: PermissionRead, ARO: "Group", AROID: "developers"},
+					{Type: PermissionRead, ARO: "User", AROID: "alice"},
+				},
+			},
+			want: []helper.ShareOperation{},
+		},
+		{
+			name: "new and changed permissions",
+			args: args{
+				desired: []helper.ShareOperation{
+					{Type: PermissionUpdate, ARO: "Group", AROID: "developers"},
+					{Type: PermissionOwner, ARO: "Group", AROID: "admins"},
+					{Type: PermissionRead, ARO: "Group", AROID: "operations"},
+					{Type: PermissionUpdate, ARO: "User", AROID: "bob"},
+				},
+			},
+			want: []helper.ShareOperation{
+				{Type: PermissionUpdate, ARO: "Group", AROID: "developers"},
+				{Type: PermissionRead, ARO: "Group", AROID: "operations"},
+				{Type: PermissionUpdate, ARO: "User", AROID: "bob"},
+			},
+		},
+		{
+			name: "revoke permissions that are no longer desired",
+			args: args{
+				desired: []helper.ShareOperation{
+					{Type: PermissionRead, ARO: "Group", AROID: "developers"},
+				},
+				revoked: []helper.ShareOperation{
+					{Type: PermissionRead, ARO: "Group", AROID: "developers"},
+					{Type: PermissionRead, ARO: "User", AROID: "alice"},
+					{Type: PermissionRead, ARO: "User", AROID: "carol"},
+				},
+			},
+			want: []helper.ShareOperation{
+				{Type: -1, ARO: "User", AROID: "alice"},
+			},
+		},
+		{
+			name: "permission of the operator is never changed",
+			args: args{
+				desired: []helper.ShareOperation{
+					{Type: PermissionRead, ARO: "User", AROID: "operator"},
+				},
+				revoked: []helper.ShareOperation{
+					{Type: PermissionOwner, ARO: "User", AROID: "operator"},
+				},
+			},
+			want: []helper.ShareOperation{},
+		},
+		{
+			name: "duplicate shares",
+			args: args{
+				desired: []helper.ShareOperation{
+					{Type: PermissionRead, ARO: "Group", AROID: "developers"},
+					{Type: PermissionOwner, ARO: "Group", AROID: "developers"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := shareOperations(current, tt.args.desired, tt.args.revoked, "operator")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("shareOperations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); !tt.wantErr && diff != "" {
+				t.Errorf("shareOperations() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_shareResolver_resolve(t *testing.T) {
+	resolver := &shareResolver{
+		users:  map[string]string{"alice@example.com": "alice"},
+		groups: map[string]string{"developers": "developers"},
+	}
+	tests := []struct {
+		name    string
+		share   Share
+		want    helper.ShareOperation
+		wantErr bool
+	}{
+		{
+			name:  "user",
+			share: Share{User: "alice@example.com", Permission: PermissionUpdate},
+			want:  helper.ShareOperation{Type: PermissionUpdate, ARO: "User", AROID: "alice"},
+		},
+		{
+			name:  "group",
+			share: Share{Group: "developers", Permission: PermissionRead},
+			want:  helper.ShareOperation{Type: PermissionRead, ARO: "Group", AROID: "developers"},
+		},
+		{
+			name:    "unknown user",
+			share:   Share{User: "bob@example.com", Permission: PermissionRead},
+			wantErr: true,
+		},
+		{
+			name:    "user and group",
+			share:   Share{User: "alice@example.com", Group: "developers", Permission: PermissionRead},
+			wantErr: true,
+		},
+		{
+			name:    "neither user nor group",
+			share:   Share{Permission: PermissionRead},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.resolve(tt.share)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("shareResolver.resolve() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); !tt.wantErr && diff != "" {
+				t.Errorf("shareResolver.resolve() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_principalIDs_valid(t *testing.T) {
+	now := time.Now()
+	ids := principalIDs{ids: map[string]string{"alice@example.com": "alice"}, loadedAt: now}
+	tests := []struct {
+		name  string
+		ids   principalIDs
+		now   time.Time
+		names []string
+		want  bool
+	}{
+		{
+			name: "not loaded",
+			now:  now,
+			want: false,
+		},
+		{
+			name:  "cached",
+			ids:   ids,
+			now:   now.Add(time.Minute),
+			names: []string{"alice@example.com"},
+			want:  true,
+		},
+		{
+			name:  "expired",
+			ids:   ids,
+			now:   now.Add(principalCacheTTL + time.Second),
+			names: []string{"alice@example.com"},
+			want:  false,
+		},
+		{
+			name:  "unknown name",
+			ids:   ids,
+			now:   now,
+			names: []string{"bob@example.com"},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ids.valid(tt.now, tt.names); got != tt.want {
+				t.Errorf("principalIDs.valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_newShareResolver(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/users.json") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"header":{"status":"error","code":404},"body":{}}`)
+			return
+		}
+		requests.Add(1)
+		fmt.Fprint(w, `{"header":{"status":"success","code":200},"body":[{"id":"alice","username":"alice@example.com"}]}`)
+	}))
+	defer server.Close()
+	clnt, err := api.NewClient(newHTTPClient(), "", server.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{passboltClient: clnt, calls: &sync.WaitGroup{}}
+
+	// the users are retrieved once and served from the cache afterwards
+	for i := 0; i < 2; i++ {
+		resolver, err := c.newShareResolver(context.Background(), []Share{{User: "alice@example.com"}}, nil)
+		if err != nil {
+			t.Fatalf("newShareResolver() error = %v", err)
+		}
+		if got := resolver.users["alice@example.com"]; got != "alice" {
+			t.Errorf("newShareResolver() user ID = %q, want alice", got)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("newShareResolver() retrieved the users %d times, want 1", got)
+	}
+	// unknown users are retrieved again
+	if _, err := c.newShareResolver(context.Background(), []Share{{User: "bob@example.com"}}, nil); err != nil {
+		t.Fatalf("newShareResolver() error = %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("newShareResolver() retrieved the users %d times, want 2", got)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// totpDefaultDigits is the number of digits of a TOTP code if not defined otherwise.
+	totpDefaultDigits = 6
+	// totpDefaultPeriod is the period in seconds of a TOTP code if not defined otherwise.
+	totpDefaultPeriod = 30
+	// totpMaxDigits is the maximum number of digits of a TOTP code.
+	// The truncated HMAC value has 31 bits, so additional digits would always be zero.
+	totpMaxDigits = 9
+)
+
+// TOTP is the TOTP configuration stored in a passbolt resource.
+type TOTP struct {
+	// SecretKey is the base32 encoded seed.
+	SecretKey string `json:"secret_key"`
+	// Algorithm is the HMAC algorithm, one of SHA1, SHA256 or SHA512.
+	Algorithm string `json:"algorithm"`
+	// Digits is the number of digits of a code.
+	Digits int `json:"digits"`
+	// Period is the number of seconds a code is valid.
+	Period int `json:"period"`
+}
+
+// validate normalizes the TOTP configuration and checks that codes can be generated.
+func (t *TOTP) validate() error {
+	t.SecretKey = strings.ToUpper(strings.ReplaceAll(t.SecretKey, " ", ""))
+	if _, err := t.key(); err != nil {
+		return fmt.Errorf("invalid TOTP secret key: %w", err)
+	}
+	if t.Algorithm == "" {
+		t.Algorithm = "SHA1"
+	}
+	t.Algorithm = strings.ToUpper(t.Algorithm)
+	if _, err := t.hash(); err != nil {
+		return err
+	}
+	if t.Digits <= 0 {
+		t.Digits = totpDefaultDigits
+	}
+	if t.Digits > totpMaxDigits {
+		return fmt.Errorf("unsupported number of TOTP digits %d, at most %d digits are supported", t.Digits, totpMaxDigits)
+	}
+	if t.Period <= 0 {
+		t.Period = totpDefaultPeriod
+	}
+	return nil
+}
+
+func (t TOTP) key() ([]byte, error) {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(t.SecretKey, "="))
+}
+
+func (t TOTP) hash() (func() hash.Hash, error) {
+	switch t.Algorithm {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported TOTP algorithm %q", t.Algorithm)
+	}
+}
+
+// Code generates the TOTP code (RFC 6238) for the given point in time.
+// The configuration must have been validated before.
+func (t TOTP) Code(now time.Time) string {
+	key, _ := t.key()
+	hashFunc, _ := t.hash()
+
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(now.Unix())/uint64(t.Period))
+	mac := hmac.New(hashFunc, key)
+	_, _ = mac.Write(buf)
+	sum := mac.Sum(nil)
+
+	// https://www.rfc-editor.org/rfc/rfc4226#section-5.4
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod)
+}
+
+// ValidFor returns the remaining duration the code generated at the given point in time is valid.
+func (t TOTP) ValidFor(now time.Time) time.Duration {
+	period := time.Duration(t.Period) * time.Second
+	return period - time.Duration(now.UnixNano())%period
+}
+
+// URI returns the otpauth URI of the TOTP configuration, as understood by authenticator apps.
+func (t TOTP) URI(issuer, account string) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	query := url.Values{}
+	query.Set("secret", t.SecretKey)
+	query.Set("algorithm", t.Algorithm)
+	query.Set("digits", strconv.Itoa(t.Digits))
+	query.Set("period", strconv.Itoa(t.Period))
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024 Verlag der Tagesspiegel GmbH.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package passbolt
+
+import (
+	"testing"
+	"time"
+)
+
+// test vectors from https://www.rfc-editor.org/rfc/rfc6238#appendix-B
+func TestTOTP_Code(t *testing.T) {
+	type fields struct {
+		SecretKey string
+		Algorithm string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		now    time.Time
+		want   string
+	}{
+		{
+			name: "SHA1 59",
+			fields: fields{
+				SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				Algorithm: "SHA1",
+			},
+			now:  time.Unix(59, 0),
+			want: "94287082",
+		},
+		{
+			name: "SHA1 1111111109",
+			fields: fields{
+				SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+				Algorithm: "SHA1",
+			},
+			now:  time.Unix(1111111109, 0),
+			want: "07081804",
+		},
+		{
+			name: "SHA256 1234567890",
+			fields: fields{
+				SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA",
+				Algorithm: "sha256",
+			},
+			now:  time.Unix(1234567890, 0),
+			want: "91819424",
+		},
+		{
+			name: "SHA512 20000000000",
+			fields: fields{
+				SecretKey: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA",
+				Algorithm: "SHA512",
+			},
+			now:  time.Unix(20000000000, 0),
+			want: "47863826",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totp := &TOTP{
+				SecretKey: tt.fields.SecretKey,
+				Algorithm: tt.fields.Algorithm,
+				Digits:    8,
+			}
+			if err := totp.validate(); err != nil {
+				t.Fatalf("TOTP.validate() error = %v", err)
+			}
+			if got := totp.Code(tt.now); got != tt.want {
+				t.Errorf("TOTP.Code() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOTP_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		totp    TOTP
+		want    TOTP
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			totp: TOTP{SecretKey: "jbsw y3dp ehpk 3pxp"},
+			want: TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: 6, Period: 30},
+		},
+		{
+			name:    "invalid secret key",
+			totp:    TOTP{SecretKey: "not base32!"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported algorithm",
+			totp:    TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Algorithm: "MD5"},
+			wantErr: true,
+		},
+		{
+			name: "maximum digits",
+			totp: TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Digits: 9},
+			want: TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: 9, Period: 30},
+		},
+		{
+			name:    "too many digits",
+			totp:    TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Digits: 10},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.totp.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TOTP.validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && tt.totp != tt.want {
+				t.Errorf("TOTP.validate() = %v, want %v", tt.totp, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOTP_ValidFor(t *testing.T) {
+	totp := TOTP{Period: 30}
+	if got := totp.ValidFor(time.Unix(59, 0)); got != time.Second {
+		t.Errorf("TOTP.ValidFor() = %v, want %v", got, time.Second)
+	}
+	if got := totp.ValidFor(time.Unix(60, 0)); got != 30*time.Second {
+		t.Errorf("TOTP.ValidFor() = %v, want %v", got, 30*time.Second)
+	}
+}
+
+func TestTOTP_URI(t *testing.T) {
+	totp := TOTP{SecretKey: "JBSWY3DPEHPK3PXP", Algorithm: "SHA1", Digits: 6, Period: 30}
+	want := "otpauth://totp/Example:alice@example.com?algorithm=SHA1&digits=6&issuer=Example&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got := totp.URI("Example", "alice@example.com"); got != want {
+		t.Errorf("TOTP.URI() = %v, want %v", got, want)
+	}
+}
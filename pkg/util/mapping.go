@@ -0,0 +1,127 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+	"github.com/urbanmedia/passbolt-operator/pkg/passbolt"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// resourceKeyData is the data passed to the key template of a PassboltResourceMapping.
+type resourceKeyData struct {
+	// ID is the ID of the passbolt resource.
+	ID string
+	// Name is the name of the passbolt resource.
+	Name string
+	// Field is the name of the synced field.
+	Field passboltv1.FieldName
+}
+
+// updateFolderData adds the fields of all resources inside the referenced passbolt folder to data.
+// The thrown error is of type SyncError
+func updateFolderData(ctx context.Context, clnt *passbolt.Client, ref passboltv1.PassboltFolderRef, data map[string][]byte, report *SyncReport) error {
+	folderID, err := clnt.ResolveFolderID(ref.ID, ref.Path)
+	if err != nil {
+		return passboltv1.SyncError{
+			Message:          err.Error(),
+			PassboltSecretID: ref.Selector(),
+			Time:             v1.Now(),
+		}
+	}
+	return updateMappedData(ctx, clnt, clnt.FolderResources(folderID), ref.PassboltResourceMapping, ref.Selector(), data, report)
+}
+
+// updateTaggedData adds the fields of all resources carrying the selected tags to data.
+// The thrown error is of type SyncError
+func updateTaggedData(ctx context.Context, clnt *passbolt.Client, selector passboltv1.PassboltTagSelector, data map[string][]byte, report *SyncReport) error {
+	return updateMappedData(ctx, clnt, clnt.TaggedResources(selector.Tags), selector.PassboltResourceMapping, "tags:"+strings.Join(selector.Tags, ","), data, report)
+}
+
+// updateMappedData adds the fields of the given resources to data as defined by the mapping.
+// Keys that are already defined in data are not overwritten, instead an error is returned.
+// source describes the selection of the resources in errors.
+// The thrown error is of type SyncError
+func updateMappedData(ctx context.Context, clnt *passbolt.Client, ids []string, mapping passboltv1.PassboltResourceMapping, source string, data map[string][]byte, report *SyncReport) error {
+	keyTemplate := mapping.KeyTemplate
+	if keyTemplate == "" {
+		keyTemplate = passboltv1.DefaultKeyTemplate
+	}
+	tmpl, err := template.New("key").Funcs(sprig.FuncMap()).Parse(keyTemplate)
+	if err != nil {
+		return passboltv1.SyncError{
+			Message:          fmt.Sprintf("invalid key template: %s", err),
+			PassboltSecretID: source,
+			Time:             v1.Now(),
+		}
+	}
+	fields := mapping.Fields
+	if len(fields) == 0 {
+		fields = passboltv1.DefaultMappedFields
+	}
+
+	for _, id := range ids {
+		secretData, err := clnt.GetSecret(ctx, id)
+		// the resource was deleted since the last cache sync, so it is no longer part of the selection
+		if errors.Is(err, passbolt.ErrResourceNotFound) {
+			continue
+		}
+		if err != nil {
+			report.callFailed(err)
+			return passboltv1.SyncError{
+				Message:          err.Error(),
+				PassboltSecretID: id,
+				Time:             v1.Now(),
+			}
+		}
+		for _, field := range fields {
+			// the selected resources are not required to have the same fields
+			value, ok := secretData.LookupField(field)
+			if !ok {
+				continue
+			}
+			key, err := renderResourceKey(tmpl, resourceKeyData{ID: id, Name: secretData.Name, Field: field})
+			if err != nil {
+				return passboltv1.SyncError{
+					Message:          err.Error(),
+					PassboltSecretID: id,
+					Time:             v1.Now(),
+				}
+			}
+			if _, ok := data[key]; ok {
+				return passboltv1.SyncError{
+					Message:          fmt.Sprintf("key %q of field %q is defined more than once", key, field),
+					PassboltSecretID: id,
+					SecretKey:        key,
+					Time:             v1.Now(),
+				}
+			}
+			if field == passboltv1.FieldNameTOTP {
+				report.expiresAfter(key, secretData.TOTP.ValidFor(time.Now()))
+			}
+			data[key] = []byte(value)
+		}
+	}
+	return nil
+}
+
+// renderResourceKey renders the key template and checks that the result is a valid key of a Kubernetes secret.
+func renderResourceKey(tmpl *template.Template, data resourceKeyData) (string, error) {
+	target := bytes.NewBuffer([]byte{})
+	if err := tmpl.Execute(target, data); err != nil {
+		return "", fmt.Errorf("failed to render key template: %w", err)
+	}
+	key := target.String()
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		return "", fmt.Errorf("invalid key %q for field %q: %s", key, data.Field, strings.Join(errs, ", "))
+	}
+	return key, nil
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+func Test_renderResourceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     resourceKeyData
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default template",
+			template: passboltv1.DefaultKeyTemplate,
+			data:     resourceKeyData{ID: "184734ea-8be3-4f5a-ba6c-5f4b3c0603e8", Name: "PostgresAdmin", Field: passboltv1.FieldNamePassword},
+			want:     "postgres_admin_password",
+		},
+		{
+			name:     "custom template",
+			template: `{{ .Name | upper }}.{{ .Field }}`,
+			data:     resourceKeyData{Name: "redis", Field: passboltv1.FieldNameUri},
+			want:     "REDIS.uri",
+		},
+		{
+			name:     "invalid key",
+			template: `{{ .Name }}/{{ .Field }}`,
+			data:     resourceKeyData{Name: "redis", Field: passboltv1.FieldNameUri},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown variable",
+			template: `{{ .Description }}`,
+			data:     resourceKeyData{Name: "redis", Field: passboltv1.FieldNameUri},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New("key").Funcs(sprig.FuncMap()).Parse(tt.template))
+			got, err := renderResourceKey(tmpl, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("renderResourceKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("renderResourceKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+const (
+	// defaultPasswordLength is the length of a generated password if the policy does not define one.
+	defaultPasswordLength = 32
+	// defaultPasswordCharset is used if the policy does not define a charset.
+	defaultPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// passwordSymbols are added to the charset if symbols are enabled.
+	// Quotes, backslashes and backticks are left out, because they often need to be escaped.
+	passwordSymbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// GeneratePassword generates a random password according to the given policy.
+// Every character is chosen uniformly from the charset using crypto/rand.
+func GeneratePassword(policy passboltv1.PasswordPolicy) (string, error) {
+	length := policy.Length
+	if length <= 0 {
+		length = defaultPasswordLength
+	}
+	charset := policy.Charset
+	if charset == "" {
+		charset = defaultPasswordCharset
+	}
+	if policy.Symbols {
+		charset += passwordSymbols
+	}
+	// deduplicate the characters, otherwise they would be more likely to be chosen
+	chars := []rune{}
+	seen := map[rune]bool{}
+	for _, c := range charset {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		chars = append(chars, c)
+	}
+	if len(chars) < 2 {
+		return "", fmt.Errorf("charset must contain at least 2 different characters")
+	}
+
+	password := make([]rune, length)
+	max := big.NewInt(int64(len(chars)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = chars[n.Int64()]
+	}
+	return string(password), nil
+}
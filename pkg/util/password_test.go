@@ -0,0 +1,72 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     passboltv1.PasswordPolicy
+		wantLength int
+		wantChars  string
+		wantErr    bool
+	}{
+		{
+			name:       "defaults",
+			policy:     passboltv1.PasswordPolicy{},
+			wantLength: defaultPasswordLength,
+			wantChars:  defaultPasswordCharset,
+		},
+		{
+			name: "custom charset",
+			policy: passboltv1.PasswordPolicy{
+				Length:  64,
+				Charset: "0123456789abcdef",
+			},
+			wantLength: 64,
+			wantChars:  "0123456789abcdef",
+		},
+		{
+			name: "symbols",
+			policy: passboltv1.PasswordPolicy{
+				Length:  16,
+				Charset: "äöü",
+				Symbols: true,
+			},
+			wantLength: 16,
+			wantChars:  "äöü" + passwordSymbols,
+		},
+		{
+			name: "charset too small",
+			policy: passboltv1.PasswordPolicy{
+				Charset: "aaaa",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GeneratePassword(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GeneratePassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if utf8.RuneCountInString(got) != tt.wantLength {
+				t.Errorf("GeneratePassword() length = %d, want %d", utf8.RuneCountInString(got), tt.wantLength)
+			}
+			for _, c := range got {
+				if !strings.ContainsRune(tt.wantChars, c) {
+					t.Errorf("GeneratePassword() contains unexpected character %q", c)
+				}
+			}
+		})
+	}
+}
@@ -3,10 +3,17 @@ package util
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
@@ -17,64 +24,203 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+// ErrDeleteSecret is thrown by UpdateSecret if a referenced passbolt resource does not exist
+// and the OnMissing policy requests the deletion of the secret.
+var ErrDeleteSecret = errors.New("referenced passbolt resource does not exist, the secret must be deleted")
+
+// SyncReport collects information about a sync that is relevant for the caller of UpdateSecret.
+type SyncReport struct {
+	// RequeueAfter is the duration after which the synced data becomes stale, e.g. because it contains a TOTP code.
+	// 0 means the data does not expire.
+	RequeueAfter time.Duration
+	// MissingResources are the sorted IDs (or selectors, if they could not be resolved) of the referenced passbolt resources
+	// that do not exist.
+	MissingResources []string
+	// PassboltError is the first error of a call to passbolt that was not caused by a missing resource.
+	// It is nil if passbolt answered all calls.
+	PassboltError error
+	// ChangedKeys are the sorted keys of the secret that were added, changed or removed by the sync.
+	ChangedKeys []string
+	// ExpiringKeys are the sorted keys of the secret whose values expire, e.g. because they contain a TOTP code.
+	ExpiringKeys []string
+}
+
+// expiresAfter records that the value of the given key of the secret becomes stale after the given duration.
+func (r *SyncReport) expiresAfter(key string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	if r.RequeueAfter == 0 || d < r.RequeueAfter {
+		r.RequeueAfter = d
+	}
+	if i, found := slices.BinarySearch(r.ExpiringKeys, key); !found {
+		r.ExpiringKeys = slices.Insert(r.ExpiringKeys, i, key)
+	}
+}
+
+// resourceMissing records that the referenced passbolt resource does not exist.
+func (r *SyncReport) resourceMissing(id string) {
+	if r == nil {
+		return
+	}
+	if i, found := slices.BinarySearch(r.MissingResources, id); !found {
+		r.MissingResources = slices.Insert(r.MissingResources, i, id)
+	}
+}
+
+// callFailed records that a call to passbolt failed. Errors of missing resources are ignored,
+// since passbolt answered the call.
+func (r *SyncReport) callFailed(err error) {
+	if r == nil || r.PassboltError != nil || errors.Is(err, passbolt.ErrResourceNotFound) {
+		return
+	}
+	r.PassboltError = err
+}
+
+// dataChanged records the keys that differ between the previous and the current data of the secret.
+func (r *SyncReport) dataChanged(previous, current map[string][]byte) {
+	if r == nil {
+		return
+	}
+	r.ChangedKeys = nil
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !bytes.Equal(old, value) {
+			r.ChangedKeys = append(r.ChangedKeys, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			r.ChangedKeys = append(r.ChangedKeys, key)
+		}
+	}
+	slices.Sort(r.ChangedKeys)
+}
+
+// DataHash returns the hex encoded HMAC-SHA256 of the given secret data with the given key.
+// The hash does not depend on the order of the keys, so it only changes if the data changes.
+// The values of the given expiring keys are left out, so that e.g. TOTP codes do not change the hash.
+// The HMAC key is held by the operator, so that the published hash does not allow to guess the values of the secret.
+func DataHash(hashKey []byte, data map[string][]byte, expiringKeys []string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	h := hmac.New(sha256.New, hashKey)
+	// the lengths are hashed as well, so that the boundaries between keys and values are unambiguous
+	length := make([]byte, 8)
+	for _, key := range keys {
+		value := data[key]
+		if slices.Contains(expiringKeys, key) {
+			value = nil
+		}
+		binary.BigEndian.PutUint64(length, uint64(len(key)))
+		h.Write(length)
+		h.Write([]byte(key))
+		binary.BigEndian.PutUint64(length, uint64(len(value)))
+		h.Write(length)
+		h.Write(value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // UpdateSecret updates the kubernetes secret with the data from passbolt
-// The thrown error is of type SyncError
-func UpdateSecret(ctx context.Context, clnt *passbolt.Client, scheme *runtime.Scheme, pbscrt *passboltv1.PassboltSecret, secret *corev1.Secret) func() error {
-	secret.Data = make(map[string][]byte)
+// The thrown error is of type SyncError, or ErrDeleteSecret if the OnMissing policy requests the deletion of the secret.
+// If report is not nil, it is filled with additional information about the sync.
+func UpdateSecret(ctx context.Context, clnt *passbolt.Client, scheme *runtime.Scheme, pbscrt *passboltv1.PassboltSecret, secret *corev1.Secret, report *SyncReport) func() error {
+	return UpdateSecretForOwner(ctx, clnt, scheme, pbscrt, pbscrt.Spec, secret, report)
+}
+
+// UpdateSecretForOwner updates the kubernetes secret with the data from passbolt as defined by the given spec.
+// If LeaveOnDelete is false, the owner is set as controller of the kubernetes secret.
+// Referenced passbolt resources that do not exist are handled as defined by OnMissing.
+// The thrown error is of type SyncError, or ErrDeleteSecret if the OnMissing policy requests the deletion of the secret.
+func UpdateSecretForOwner(ctx context.Context, clnt *passbolt.Client, scheme *runtime.Scheme, owner v1.Object, spec passboltv1.PassboltSecretSpec, secret *corev1.Secret, report *SyncReport) func() error {
 	return func() error {
-		switch pbscrt.Spec.SecretType {
+		// the existing data is discarded, so that keys that are no longer defined are removed from the secret.
+		// The previous data is kept for the OnMissing policy Keep.
+		previous := secret.Data
+		secret.Data = make(map[string][]byte)
+		switch spec.SecretType {
 		case corev1.SecretTypeDockerConfigJson:
 			// get secret from passbolt
-			secretData, err := clnt.GetSecret(ctx, *pbscrt.Spec.PassboltSecretID)
+			secretData, err := clnt.GetSecret(ctx, *spec.PassboltSecretID)
 			if err != nil {
-				return passboltv1.SyncError{
-					Message:          err.Error(),
-					PassboltSecretID: *pbscrt.Spec.PassboltSecretID,
-					Time:             v1.Now(),
+				report.callFailed(err)
+				policy := spec.OnMissing
+				// the secret consists of a single key, so that it cannot exist without the key
+				if policy == passboltv1.OnMissingDeleteKey {
+					policy = passboltv1.OnMissingDeleteSecret
+				}
+				if _, ok := previous[corev1.DockerConfigJsonKey]; policy == passboltv1.OnMissingKeep && !ok {
+					policy = passboltv1.OnMissingFail
 				}
+				if err := handleMissing(policy, err, *spec.PassboltSecretID, corev1.DockerConfigJsonKey, previous, secret.Data, report); err != nil {
+					return err
+				}
+				break
 			}
 			dockerConfigJson, err := getSecretDockerConfigJson(secretData)
 			if err != nil {
 				return passboltv1.SyncError{
 					Message:          err.Error(),
-					PassboltSecretID: *pbscrt.Spec.PassboltSecretID,
+					PassboltSecretID: *spec.PassboltSecretID,
 					Time:             v1.Now(),
 				}
 			}
 			secret.Data = dockerConfigJson
 		case corev1.SecretTypeOpaque:
-			for key, value := range pbscrt.Spec.PlainTextFields {
+			for key, value := range spec.PlainTextFields {
 				secret.Data[key] = []byte(value)
 			}
 
 			// iterate over all secrets and get secret from passbolt
-			for secretKeyName, pbSecret := range pbscrt.Spec.PassboltSecrets {
+			for secretKeyName, pbSecret := range spec.PassboltSecrets {
+				// resolve name and folder path references to the ID of the secret
+				if pbSecret.ID == "" {
+					id, err := clnt.ResolveResourceID(pbSecret.Name, pbSecret.FolderPath)
+					if err != nil {
+						// the OnMissing policy is not applied, since a resource missing from the cache may have been created since the last cache sync
+						return passboltv1.SyncError{
+							Message:          err.Error(),
+							PassboltSecretID: pbSecret.Selector(),
+							SecretKey:        secretKeyName,
+							Time:             v1.Now(),
+						}
+					}
+					pbSecret.ID = id
+				}
 				secretData, err := clnt.GetSecret(ctx, pbSecret.ID)
 				if err != nil {
-					return passboltv1.SyncError{
-						Message:          err.Error(),
-						PassboltSecretID: pbSecret.ID,
-						SecretKey:        secretKeyName,
-						Time:             v1.Now(),
+					report.callFailed(err)
+					if err := handleMissing(spec.OnMissing, err, pbSecret.ID, secretKeyName, previous, secret.Data, report); err != nil {
+						return err
 					}
-				}
-
-				// TODO: normally this should not be necessary because we already assigned an empty map
-				// TODO: for some reason, it is necessary to check if the map is nil at this point
-				if secret.Data == nil {
-					secret.Data = make(map[string][]byte)
+					continue
 				}
 
 				switch {
 				// check if field is set
 				// if field is set, get field value from passbolt secret and set it as kubernetes secret value
 				case pbSecret.Field != "":
-					secret.Data[secretKeyName] = []byte(secretData.FieldValue(pbSecret.Field))
+					value, ok := secretData.LookupField(pbSecret.Field)
+					if !ok {
+						return passboltv1.SyncError{
+							Message:          fmt.Sprintf("field %q does not exist in passbolt secret", pbSecret.Field),
+							PassboltSecretID: pbSecret.ID,
+							SecretKey:        secretKeyName,
+							Time:             v1.Now(),
+						}
+					}
+					if pbSecret.Field == passboltv1.FieldNameTOTP {
+						report.expiresAfter(secretKeyName, secretData.TOTP.ValidFor(time.Now()))
+					}
+					secret.Data[secretKeyName] = []byte(value)
 					continue
 				// check if value is set
 				// if value is set, parse value as template and set it as kubernetes secret value
 				case pbSecret.Value != nil:
-					bts, err := getSecretTemplateValueData(*pbSecret.Value, secretData)
+					bts, err := getSecretTemplateValueData(secretKeyName, *pbSecret.Value, secretData, report)
 					if err != nil {
 						return passboltv1.SyncError{
 							Message:          err.Error(),
@@ -95,17 +241,29 @@ func UpdateSecret(ctx context.Context, clnt *passbolt.Client, scheme *runtime.Sc
 					}
 				}
 			}
+			// sync all resources of the folder
+			if spec.PassboltFolder != nil {
+				if err := updateFolderData(ctx, clnt, *spec.PassboltFolder, secret.Data, report); err != nil {
+					return err
+				}
+			}
+			// sync all resources carrying the selected tags
+			if spec.TagSelector != nil {
+				if err := updateTaggedData(ctx, clnt, *spec.TagSelector, secret.Data, report); err != nil {
+					return err
+				}
+			}
 		// secret type is not supported
 		default:
 			return passboltv1.SyncError{
-				Message: fmt.Sprintf("secret type %s is not supported", pbscrt.Spec.SecretType),
+				Message: fmt.Sprintf("secret type %s is not supported", spec.SecretType),
 				Time:    v1.Now(),
 			}
 		}
 		// set owner reference if LeaveOnDelete was set to false
-		if !pbscrt.Spec.LeaveOnDelete {
+		if !spec.LeaveOnDelete {
 			// set owner reference
-			err := ctrl.SetControllerReference(pbscrt, secret, scheme)
+			err := ctrl.SetControllerReference(owner, secret, scheme)
 			if err != nil {
 				return passboltv1.SyncError{
 					Message: err.Error(),
@@ -113,7 +271,39 @@ func UpdateSecret(ctx context.Context, clnt *passbolt.Client, scheme *runtime.Sc
 				}
 			}
 		}
+		report.dataChanged(previous, secret.Data)
+		return nil
+	}
+}
+
+// handleMissing applies the OnMissing policy if the given error was caused by a passbolt resource that does not exist,
+// i.e. passbolt answered with HTTP status 404 and a fresh lookup did not find the resource either.
+// id is the ID or selector of the resource and key the key of the secret the resource is synced to.
+// It returns nil if the sync continues without the resource, otherwise the error to be thrown.
+func handleMissing(policy passboltv1.OnMissingPolicy, err error, id, key string, previous, data map[string][]byte, report *SyncReport) error {
+	syncErr := passboltv1.SyncError{
+		Message:          err.Error(),
+		PassboltSecretID: id,
+		SecretKey:        key,
+		Time:             v1.Now(),
+	}
+	if !errors.Is(err, passbolt.ErrResourceNotFound) {
+		return syncErr
+	}
+	report.resourceMissing(id)
+	switch policy {
+	case passboltv1.OnMissingKeep:
+		// there is nothing to keep if the resource was missing since the first sync
+		if value, ok := previous[key]; ok {
+			data[key] = value
+		}
 		return nil
+	case passboltv1.OnMissingDeleteKey:
+		return nil
+	case passboltv1.OnMissingDeleteSecret:
+		return ErrDeleteSecret
+	default:
+		return syncErr
 	}
 }
 
@@ -135,15 +325,32 @@ func getSecretDockerConfigJson(secret *passbolt.PassboltSecretDefinition) (map[s
 	}, nil
 }
 
-func getSecretTemplateValueData(templateStr string, secret *passbolt.PassboltSecretDefinition) ([]byte, error) {
+// templateData is the data passed to value templates.
+// It records whether the template rendered a TOTP code, which expires after the TOTP period.
+type templateData struct {
+	*passbolt.PassboltSecretDefinition
+	totpCodeUsed bool
+}
+
+// TOTPCode returns the current TOTP code and marks the rendered value as expiring.
+func (d *templateData) TOTPCode() string {
+	d.totpCodeUsed = true
+	return d.PassboltSecretDefinition.TOTPCode()
+}
+
+func getSecretTemplateValueData(key, templateStr string, secret *passbolt.PassboltSecretDefinition, report *SyncReport) ([]byte, error) {
 	tmpl, err := template.New("value").Funcs(sprig.FuncMap()).Parse(templateStr)
 	if err != nil {
 		return nil, err
 	}
 	target := bytes.NewBuffer([]byte{})
-	err = tmpl.Execute(target, *secret)
+	data := &templateData{PassboltSecretDefinition: secret}
+	err = tmpl.Execute(target, data)
 	if err != nil {
 		return nil, err
 	}
+	if data.totpCodeUsed && secret.TOTP != nil {
+		report.expiresAfter(key, secret.TOTP.ValidFor(time.Now()))
+	}
 	return target.Bytes(), nil
 }
@@ -2,8 +2,11 @@ package util
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	passboltv1 "github.com/urbanmedia/passbolt-operator/api/v1"
@@ -583,7 +586,7 @@ func TestUpdateSecret(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := UpdateSecret(tt.args.ctx, tt.args.clnt, tt.args.scheme, tt.args.pbscrt, tt.args.secret)()
+			err := UpdateSecret(tt.args.ctx, tt.args.clnt, tt.args.scheme, tt.args.pbscrt, tt.args.secret, nil)()
 			if (err != nil) != tt.wantErr {
 				t.Errorf("UpdateSecret() error = %v != %v", err, tt.wantErr)
 				return
@@ -693,7 +696,7 @@ func Test_getSecretTemplateValueData(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getSecretTemplateValueData(tt.args.templateStr, tt.args.secret)
+			got, err := getSecretTemplateValueData("value", tt.args.templateStr, tt.args.secret, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getSecretTemplateValueData() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -705,3 +708,141 @@ func Test_getSecretTemplateValueData(t *testing.T) {
 		})
 	}
 }
+
+func Test_handleMissing(t *testing.T) {
+	missing := fmt.Errorf("failed to get secret: %w", passbolt.ErrResourceNotFound)
+	previous := map[string][]byte{"password": []byte("old")}
+	tests := []struct {
+		name        string
+		policy      passboltv1.OnMissingPolicy
+		err         error
+		want        map[string][]byte
+		wantErr     error
+		wantSyncErr bool
+		wantMissing []string
+	}{
+		{
+			name:        "fail by default",
+			err:         missing,
+			want:        map[string][]byte{},
+			wantSyncErr: true,
+			wantMissing: []string{"id"},
+		},
+		{
+			name:        "keep last value",
+			policy:      passboltv1.OnMissingKeep,
+			err:         missing,
+			want:        map[string][]byte{"password": []byte("old")},
+			wantMissing: []string{"id"},
+		},
+		{
+			name:        "delete key",
+			policy:      passboltv1.OnMissingDeleteKey,
+			err:         missing,
+			want:        map[string][]byte{},
+			wantMissing: []string{"id"},
+		},
+		{
+			name:        "delete secret",
+			policy:      passboltv1.OnMissingDeleteSecret,
+			err:         missing,
+			want:        map[string][]byte{},
+			wantErr:     ErrDeleteSecret,
+			wantMissing: []string{"id"},
+		},
+		{
+			name:        "other errors are not affected by the policy",
+			policy:      passboltv1.OnMissingKeep,
+			err:         errors.New("connection refused"),
+			want:        map[string][]byte{},
+			wantSyncErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string][]byte{}
+			report := &SyncReport{}
+			err := handleMissing(tt.policy, tt.err, "id", "password", previous, data, report)
+			if _, ok := err.(passboltv1.SyncError); ok != tt.wantSyncErr {
+				t.Errorf("handleMissing() error = %v, wantSyncErr %v", err, tt.wantSyncErr)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("handleMissing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, data); diff != "" {
+				t.Errorf("handleMissing() data mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantMissing, report.MissingResources); diff != "" {
+				t.Errorf("handleMissing() missing resources mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncReport_expiresAfter(t *testing.T) {
+	report := &SyncReport{}
+	report.expiresAfter("totp", 20*time.Second)
+	report.expiresAfter("code", 10*time.Second)
+	report.expiresAfter("totp", 30*time.Second)
+	if report.RequeueAfter != 10*time.Second {
+		t.Errorf("SyncReport.RequeueAfter = %s, want 10s", report.RequeueAfter)
+	}
+	if diff := cmp.Diff([]string{"code", "totp"}, report.ExpiringKeys); diff != "" {
+		t.Errorf("SyncReport.ExpiringKeys mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSyncReport_dataChanged(t *testing.T) {
+	report := &SyncReport{}
+	report.dataChanged(map[string][]byte{
+		"password": []byte("old"),
+		"username": []byte("admin"),
+		"removed":  []byte("value"),
+	}, map[string][]byte{
+		"password": []byte("new"),
+		"username": []byte("admin"),
+		"added":    []byte("value"),
+	})
+	if diff := cmp.Diff([]string{"added", "password", "removed"}, report.ChangedKeys); diff != "" {
+		t.Errorf("SyncReport.dataChanged() mismatch (-want +got):\n%s", diff)
+	}
+
+	report.dataChanged(map[string][]byte{"password": []byte("new")}, map[string][]byte{"password": []byte("new")})
+	if len(report.ChangedKeys) != 0 {
+		t.Errorf("SyncReport.dataChanged() = %v, want no keys", report.ChangedKeys)
+	}
+}
+
+func TestDataHash(t *testing.T) {
+	hashKey := []byte("operator-key")
+	data := map[string][]byte{"password": []byte("secret"), "username": []byte("admin")}
+	hash := DataHash(hashKey, data, nil)
+	if len(hash) != 64 {
+		t.Fatalf("DataHash() = %q, want a hex encoded HMAC-SHA256", hash)
+	}
+	if got := DataHash(hashKey, map[string][]byte{"username": []byte("admin"), "password": []byte("secret")}, nil); got != hash {
+		t.Errorf("DataHash() = %q for equal data, want %q", got, hash)
+	}
+	// moving bytes between keys and values changes the hash
+	if got := DataHash(hashKey, map[string][]byte{"passwords": []byte("ecret"), "username": []byte("admin")}, nil); got == hash {
+		t.Error("DataHash() did not change for different data")
+	}
+	if got := DataHash(hashKey, map[string][]byte{"password": []byte("rotated"), "username": []byte("admin")}, nil); got == hash {
+		t.Error("DataHash() did not change for a changed value")
+	}
+	// the hash depends on the key of the operator
+	if got := DataHash([]byte("other-key"), data, nil); got == hash {
+		t.Error("DataHash() did not change for a different key")
+	}
+	// expiring values do not change the hash
+	withCode := func(code string) map[string][]byte {
+		return map[string][]byte{"password": []byte("secret"), "username": []byte("admin"), "totp": []byte(code)}
+	}
+	expiring := []string{"totp"}
+	if DataHash(hashKey, withCode("123456"), expiring) != DataHash(hashKey, withCode("654321"), expiring) {
+		t.Error("DataHash() changed for a changed expiring value")
+	}
+	if DataHash(hashKey, withCode("123456"), expiring) == hash {
+		t.Error("DataHash() did not change for an added expiring key")
+	}
+}